@@ -0,0 +1,75 @@
+package main
+
+import (
+    "fmt"
+    "log"
+    "os"
+    "time"
+    "virtual_ethiopia_dap/internal/blockchain"
+    "virtual_ethiopia_dap/internal/consensus"
+)
+
+// devGenesisTimestamp is the genesis time of single-node development chains
+const devGenesisTimestamp = 1704067200 // 2024-01-01T00:00:00Z
+
+// loadGenesis reads GENESIS_FILE. Without one, a single-validator development
+// genesis is built around the node's own validator key, which also acts as
+// the admin key, generating that key if VALIDATOR_KEY is not set either.
+func loadGenesis(validatorKey *string) (*blockchain.Genesis, error) {
+    if path := os.Getenv("GENESIS_FILE"); path != "" {
+        return blockchain.LoadGenesis(path)
+    }
+
+    if *validatorKey == "" {
+        _, privateKey, err := blockchain.GenerateKeyPair()
+        if err != nil {
+            return nil, err
+        }
+        *validatorKey = privateKey
+        log.Println("GENESIS_FILE and VALIDATOR_KEY not set, using a throwaway single-validator development chain")
+    }
+
+    publicKey, err := blockchain.PublicKeyFromPrivate(*validatorKey)
+    if err != nil {
+        return nil, fmt.Errorf("invalid VALIDATOR_KEY: %v", err)
+    }
+    return &blockchain.Genesis{
+        Timestamp:  devGenesisTimestamp,
+        Validators: []string{publicKey},
+        Admins:     []string{publicKey},
+    }, nil
+}
+
+// newEngine creates the consensus engine selected by CONSENSUS ("poa" or "bft")
+func newEngine(chain *blockchain.Chain, config consensus.Config) (consensus.Engine, error) {
+    switch mode := os.Getenv("CONSENSUS"); mode {
+    case "", "poa":
+        return consensus.NewPoA(chain, config)
+    case "bft":
+        return consensus.NewBFT(chain, config)
+    default:
+        return nil, fmt.Errorf("unknown CONSENSUS mode %q", mode)
+    }
+}
+
+// consensusConfig reads the block timing settings from the environment
+func consensusConfig(validatorKey string) (consensus.Config, error) {
+    config := consensus.DefaultConfig()
+    config.PrivateKey = validatorKey
+
+    if value := os.Getenv("BLOCK_INTERVAL"); value != "" {
+        interval, err := time.ParseDuration(value)
+        if err != nil {
+            return config, fmt.Errorf("invalid BLOCK_INTERVAL: %v", err)
+        }
+        config.BlockInterval = interval
+    }
+    if value := os.Getenv("SLOT_TIMEOUT"); value != "" {
+        timeout, err := time.ParseDuration(value)
+        if err != nil {
+            return config, fmt.Errorf("invalid SLOT_TIMEOUT: %v", err)
+        }
+        config.SlotTimeout = timeout
+    }
+    return config, nil
+}
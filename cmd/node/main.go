@@ -1,19 +1,26 @@
 package main
 
 import (
+    "encoding/json"
     "fmt"
     "log"
     "os"
     "os/signal"
+    "strings"
     "syscall"
     "virtual_ethiopia_dap/internal/api"
     "virtual_ethiopia_dap/internal/blockchain"
+    "virtual_ethiopia_dap/internal/consensus"
     "virtual_ethiopia_dap/internal/p2p"
+    "virtual_ethiopia_dap/internal/storage"
 )
 
 type Node struct {
     chain     *blockchain.Chain
+    engine    consensus.Engine
     network   *p2p.Network
+    syncer    *p2p.Syncer
+    gossip    *p2p.TxGossip
     api       *api.Server
     nodeID    string
     p2pPort   string
@@ -21,16 +28,59 @@ type Node struct {
     isRunning bool
 }
 
-func NewNode() *Node {
-    chain := blockchain.NewChain()
+func NewNode() (*Node, error) {
+    validatorKey := os.Getenv("VALIDATOR_KEY")
+    genesis, err := loadGenesis(&validatorKey)
+    if err != nil {
+        return nil, err
+    }
+
+    chain, err := openChain(os.Getenv("DATA_DIR"), genesis)
+    if err != nil {
+        return nil, err
+    }
+
+    config, err := consensusConfig(validatorKey)
+    if err != nil {
+        return nil, err
+    }
+    engine, err := newEngine(chain, config)
+    if err != nil {
+        return nil, err
+    }
+
+    network := p2p.NewNetwork()
     return &Node{
         nodeID:  os.Getenv("NODE_ID"),
         p2pPort: os.Getenv("P2P_PORT"),
         apiPort: os.Getenv("API_PORT"),
         chain:   chain,
-        network: p2p.NewNetwork(),
+        engine:  engine,
+        network: network,
+        syncer:  p2p.NewSyncer(network, chain, engine.HandleBlock),
+        gossip:  p2p.NewTxGossip(network, chain),
         api:     api.NewServer(chain),
+    }, nil
+}
+
+// openChain loads the chain from dataDir, or keeps it in memory when no data
+// directory is configured
+func openChain(dataDir string, genesis *blockchain.Genesis) (*blockchain.Chain, error) {
+    if dataDir == "" {
+        log.Println("DATA_DIR not set, chain will be kept in memory only")
+        return blockchain.NewChain(genesis), nil
+    }
+
+    store, err := storage.OpenFileStore(dataDir)
+    if err != nil {
+        return nil, err
+    }
+    chain, err := blockchain.OpenChain(store, genesis)
+    if err != nil {
+        store.Close()
+        return nil, fmt.Errorf("failed to open chain in %s: %v", dataDir, err)
     }
+    return chain, nil
 }
 
 // Start initializes and starts all node services
@@ -45,6 +95,29 @@ func (n *Node) Start() error {
         return fmt.Errorf("failed to start P2P network: %v", err)
     }
 
+    // Keep the chain in sync with peers
+    n.syncer.Start()
+    for _, address := range initialPeers() {
+        n.network.Maintain(address)
+    }
+
+    // Start block production
+    n.engine.SetBroadcaster(n.syncer.Announce)
+    if bft, ok := n.engine.(*consensus.BFT); ok {
+        bft.SetTransport(consensusTransport{network: n.network})
+        n.network.Handle("consensus", func(peer *p2p.Peer, data json.RawMessage) {
+            var msg consensus.Message
+            if err := json.Unmarshal(data, &msg); err != nil {
+                log.Printf("Invalid consensus message: %v", err)
+                return
+            }
+            bft.HandleMessage(&msg)
+        })
+    }
+    if err := n.engine.Start(); err != nil {
+        return fmt.Errorf("failed to start consensus: %v", err)
+    }
+
     // Start API server
     go func() {
         if err := n.api.Start(n.apiPort); err != nil {
@@ -64,20 +137,54 @@ func (n *Node) Stop() error {
         return nil
     }
 
+    // Stop block production before the network it broadcasts on
+    n.engine.Stop()
+    n.syncer.Stop()
+
     // Stop P2P network
     if err := n.network.Stop(); err != nil {
         log.Printf("Error stopping P2P network: %v", err)
     }
 
+    if err := n.chain.Close(); err != nil {
+        log.Printf("Error closing chain store: %v", err)
+    }
+
     // Stop API server (TODO)
     n.isRunning = false
     log.Println("Node stopped successfully")
     return nil
 }
 
+// initialPeers returns the comma-separated host:port addresses from
+// INITIAL_PEERS
+func initialPeers() []string {
+    var peers []string
+    for _, address := range strings.Split(os.Getenv("INITIAL_PEERS"), ",") {
+        if address = strings.TrimSpace(address); address != "" {
+            peers = append(peers, address)
+        }
+    }
+    return peers
+}
+
+// consensusTransport carries BFT consensus messages over the P2P network
+type consensusTransport struct {
+    network *p2p.Network
+}
+
+func (t consensusTransport) Broadcast(msg *consensus.Message) {
+    if err := t.network.Broadcast("consensus", msg); err != nil {
+        log.Printf("Failed to broadcast consensus message: %v", err)
+    }
+}
+
 func main() {
     // Create and start the node
-    node := NewNode()
+    node, err := NewNode()
+    if err != nil {
+        log.Fatal(err)
+    }
     if err := node.Start(); err != nil {
         log.Fatal(err)
     }
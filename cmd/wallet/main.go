@@ -0,0 +1,292 @@
+// Command wallet manages citizen keys and sends signed requests to a node.
+//
+//	wallet keygen
+//	wallet pubkey
+//	wallet post /citizens/register '{"name":"John Doe","dateOfBirth":"1990-01-01"}'
+//	wallet ballot ELECTION_ID CHOICE
+//	wallet encrypt ELECTION_ID CANDIDATE_ID
+//	wallet deal ELECTION_ID
+//	wallet decrypt ELECTION_ID '{"TRUSTEE_KEY":"SHARE",...}'
+//
+// ballot, encrypt, deal and decrypt print request bodies to send with post.
+// The private key is read from -key or WALLET_KEY, the node URL from -node or
+// NODE_URL (default http://localhost:3001).
+package main
+
+import (
+    "bytes"
+    "crypto/rand"
+    "encoding/hex"
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "os"
+    "strconv"
+    "time"
+    "virtual_ethiopia_dap/internal/api"
+    "virtual_ethiopia_dap/internal/blockchain"
+)
+
+func main() {
+    key := flag.String("key", os.Getenv("WALLET_KEY"), "hex-encoded private key")
+    node := flag.String("node", envOr("NODE_URL", "http://localhost:3001"), "node API URL")
+    flag.Usage = usage
+    flag.Parse()
+
+    var err error
+    switch args := flag.Args(); {
+    case len(args) == 1 && args[0] == "keygen":
+        err = keygen()
+    case len(args) == 1 && args[0] == "pubkey":
+        err = pubkey(*key)
+    case len(args) == 3 && args[0] == "post":
+        err = post(*node, *key, args[1], args[2])
+    case len(args) == 3 && args[0] == "ballot":
+        err = ballot(*node, *key, args[1], args[2])
+    case len(args) == 3 && args[0] == "encrypt":
+        err = encrypt(*node, *key, args[1], args[2])
+    case len(args) == 2 && args[0] == "deal":
+        err = deal(*node, *key, args[1])
+    case len(args) == 3 && args[0] == "decrypt":
+        err = decrypt(*node, *key, args[1], args[2])
+    default:
+        usage()
+        os.Exit(2)
+    }
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "wallet:", err)
+        os.Exit(1)
+    }
+}
+
+func usage() {
+    fmt.Fprintln(os.Stderr, "usage: wallet [-key KEY] [-node URL] keygen | pubkey | post PATH JSON | ballot ELECTION CHOICE | encrypt ELECTION CANDIDATE | deal ELECTION | decrypt ELECTION SHARES")
+    flag.PrintDefaults()
+}
+
+func envOr(name, fallback string) string {
+    if value := os.Getenv(name); value != "" {
+        return value
+    }
+    return fallback
+}
+
+// keygen prints a new key pair
+func keygen() error {
+    publicKey, privateKey, err := blockchain.GenerateKeyPair()
+    if err != nil {
+        return err
+    }
+    fmt.Printf("public key:  %s\nprivate key: %s\n", publicKey, privateKey)
+    return nil
+}
+
+// pubkey prints the public key of the configured private key
+func pubkey(privateKey string) error {
+    publicKey, err := blockchain.PublicKeyFromPrivate(privateKey)
+    if err != nil {
+        return err
+    }
+    fmt.Println(publicKey)
+    return nil
+}
+
+// ballot seals a secret ballot for choice and prints the request bodies
+// that commit it now and reveal it in the reveal phase. Choice is a
+// candidate ID; comma-separated candidate IDs, in order of preference in
+// ranked elections; or comma-separated CANDIDATE_ID=SCORE pairs in score
+// elections.
+func ballot(node, privateKey, electionID, choice string) error {
+    publicKey, err := blockchain.PublicKeyFromPrivate(privateKey)
+    if err != nil {
+        return err
+    }
+    election, err := fetchElection(node, electionID)
+    if err != nil {
+        return err
+    }
+
+    sealed, err := blockchain.ParseBallot(election.Method, choice)
+    if err != nil {
+        return err
+    }
+    commitment, salt, err := blockchain.NewBallot(electionID, publicKey, election.Method, sealed)
+    if err != nil {
+        return err
+    }
+
+    request := api.VoteRevealRequest{
+        VoteRequest: api.VoteRequest{
+            ElectionID:  electionID,
+            CandidateID: sealed.CandidateID,
+            Ranking:     sealed.Ranking,
+            Approvals:   sealed.Approvals,
+            Scores:      sealed.Scores,
+        },
+        Salt: salt,
+    }
+
+    commit, err := json.Marshal(api.VoteCommitRequest{ElectionID: electionID, Commitment: commitment})
+    if err != nil {
+        return err
+    }
+    reveal, err := json.Marshal(request)
+    if err != nil {
+        return err
+    }
+    fmt.Printf("commit now:  %s\nreveal later (keep this secret until then): %s\n", commit, reveal)
+    return nil
+}
+
+// encrypt prints the request body casting an encrypted ballot for
+// candidateID in an encrypted election
+func encrypt(node, privateKey, electionID, candidateID string) error {
+    publicKey, err := blockchain.PublicKeyFromPrivate(privateKey)
+    if err != nil {
+        return err
+    }
+    election, err := fetchElection(node, electionID)
+    if err != nil {
+        return err
+    }
+
+    ballot, err := blockchain.EncryptBallot(election, publicKey, candidateID)
+    if err != nil {
+        return err
+    }
+    return printJSON(api.EncryptedVoteRequest{ElectionID: electionID, Ballot: *ballot})
+}
+
+// deal prints the request body publishing the trustee's dealing for an
+// encrypted election, followed by the share for every trustee
+func deal(node, privateKey, electionID string) error {
+    publicKey, err := blockchain.PublicKeyFromPrivate(privateKey)
+    if err != nil {
+        return err
+    }
+    election, err := fetchElection(node, electionID)
+    if err != nil {
+        return err
+    }
+
+    dealing, shares, err := blockchain.DealTrusteeShares(election, publicKey)
+    if err != nil {
+        return err
+    }
+    if err := printJSON(api.TrusteeDealingRequest{ElectionID: electionID, Dealing: *dealing}); err != nil {
+        return err
+    }
+    fmt.Println("send each trustee its share privately:")
+    for i, trustee := range election.Trustees {
+        fmt.Printf("%s %s\n", trustee, shares[i])
+    }
+    return nil
+}
+
+// decrypt checks the shares the trustee received, keyed by dealer, and
+// prints the request body publishing its partial decryption of the tally
+func decrypt(node, privateKey, electionID, sharesJSON string) error {
+    publicKey, err := blockchain.PublicKeyFromPrivate(privateKey)
+    if err != nil {
+        return err
+    }
+    var shares map[string]string
+    if err := json.Unmarshal([]byte(sharesJSON), &shares); err != nil {
+        return fmt.Errorf("invalid shares: %v", err)
+    }
+    election, err := fetchElection(node, electionID)
+    if err != nil {
+        return err
+    }
+
+    keyShare, err := blockchain.CombineTrusteeShares(election, publicKey, shares)
+    if err != nil {
+        return err
+    }
+    share, err := blockchain.DecryptTallyShare(election, publicKey, keyShare)
+    if err != nil {
+        return err
+    }
+    return printJSON(api.TallyDecryptionRequest{ElectionID: electionID, Share: *share})
+}
+
+// fetchElection reads an election from the node
+func fetchElection(node, electionID string) (*blockchain.Election, error) {
+    resp, err := http.Get(node + "/elections/" + url.PathEscape(electionID))
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+
+    var response struct {
+        Data  *blockchain.Election `json:"data"`
+        Error string               `json:"error"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+        return nil, err
+    }
+    if response.Data == nil {
+        return nil, fmt.Errorf("election %s: %s", electionID, response.Error)
+    }
+    return response.Data, nil
+}
+
+func printJSON(v interface{}) error {
+    raw, err := json.Marshal(v)
+    if err != nil {
+        return err
+    }
+    fmt.Println(string(raw))
+    return nil
+}
+
+// post signs the transaction that body submits to path and sends the request
+func post(node, privateKey, path, body string) error {
+    publicKey, err := blockchain.PublicKeyFromPrivate(privateKey)
+    if err != nil {
+        return err
+    }
+
+    nonce := make([]byte, 16)
+    if _, err := rand.Read(nonce); err != nil {
+        return err
+    }
+
+    timestamp := time.Now().Unix()
+    tx, err := api.BuildTransaction(path, []byte(body), publicKey, timestamp, hex.EncodeToString(nonce))
+    if err != nil {
+        return err
+    }
+    if err := tx.Sign(privateKey); err != nil {
+        return err
+    }
+
+    req, err := http.NewRequest(http.MethodPost, node+path, bytes.NewBufferString(body))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set(api.HeaderPublicKey, publicKey)
+    req.Header.Set(api.HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+    req.Header.Set(api.HeaderNonce, tx.Nonce)
+    req.Header.Set(api.HeaderSignature, tx.Signature)
+
+    resp, err := http.DefaultClient.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+
+    response, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return err
+    }
+    fmt.Print(string(response))
+    if resp.StatusCode != http.StatusOK {
+        return fmt.Errorf("request failed: %s", resp.Status)
+    }
+    return nil
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+    "fmt"
+    "net/http"
+    "strconv"
+    "sync"
+    "time"
+    "virtual_ethiopia_dap/internal/blockchain"
+)
+
+const (
+    // requestWindow is how far a signed request's timestamp may be from the
+    // server clock; older requests are rejected as possible replays
+    requestWindow = 5 * time.Minute
+    // maxNonceLength bounds the X-Nonce header
+    maxNonceLength = 64
+)
+
+// nonceCache remembers the nonce of every accepted request for as long as its
+// timestamp is inside the request window. It only turns away a resent request
+// early: the cache lives in one node's memory, so after a restart or on
+// another node a replay gets past it. Replays are stopped by the chain, since
+// the request rebuilds the same transaction, whose ID the pool refuses while
+// pending and the state refuses once applied.
+type nonceCache struct {
+    seen map[string]int64 // public key + nonce -> request timestamp
+    mu   sync.Mutex
+}
+
+func newNonceCache() *nonceCache {
+    return &nonceCache{seen: make(map[string]int64)}
+}
+
+// use records a nonce and reports whether it had not been used before
+func (c *nonceCache) use(publicKey, nonce string, timestamp int64, now time.Time) bool {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    oldest := now.Add(-requestWindow).Unix()
+    for key, seenAt := range c.seen {
+        if seenAt < oldest {
+            delete(c.seen, key)
+        }
+    }
+
+    key := publicKey + "/" + nonce
+    if _, used := c.seen[key]; used {
+        return false
+    }
+    c.seen[key] = timestamp
+    return true
+}
+
+// authenticate rebuilds the transaction a state-changing request submits and
+// checks it against the signing headers. It returns the HTTP status to use
+// when the request is rejected: 401 when the signature, timestamp or nonce
+// does not check out, 400 when the body itself is invalid. A request that
+// passes may still be a replay, which SubmitTransaction rejects.
+func (s *Server) authenticate(r *http.Request, body []byte) (*blockchain.Transaction, int, error) {
+    publicKey := r.Header.Get(HeaderPublicKey)
+    nonce := r.Header.Get(HeaderNonce)
+    signature := r.Header.Get(HeaderSignature)
+    timestamp, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+    if publicKey == "" || nonce == "" || signature == "" || err != nil {
+        return nil, http.StatusUnauthorized, fmt.Errorf("request must be signed with the %s, %s, %s and %s headers",
+            HeaderPublicKey, HeaderTimestamp, HeaderNonce, HeaderSignature)
+    }
+    if len(nonce) > maxNonceLength {
+        return nil, http.StatusUnauthorized, fmt.Errorf("nonce is longer than %d characters", maxNonceLength)
+    }
+
+    now := time.Now()
+    if skew := now.Sub(time.Unix(timestamp, 0)); skew > requestWindow || skew < -requestWindow {
+        return nil, http.StatusUnauthorized, fmt.Errorf("request timestamp is outside the accepted window of %v", requestWindow)
+    }
+
+    tx, err := BuildTransaction(r.URL.Path, body, publicKey, timestamp, nonce)
+    if err != nil {
+        return nil, http.StatusBadRequest, err
+    }
+    tx.Signature = signature
+    if !tx.VerifySignature() {
+        return nil, http.StatusUnauthorized, fmt.Errorf("invalid request signature")
+    }
+
+    if !s.nonces.use(publicKey, nonce, timestamp, now) {
+        return nil, http.StatusUnauthorized, fmt.Errorf("nonce has already been used")
+    }
+    return tx, 0, nil
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+    "virtual_ethiopia_dap/internal/blockchain"
+)
+
+const registrationBody = `{"name":"Mulu Alemu","dateOfBirth":"1990-01-01"}`
+
+// signedRequest returns a registration request signed by privateKey with the
+// given timestamp and nonce
+func signedRequest(t *testing.T, publicKey, privateKey string, timestamp int64, nonce string) *http.Request {
+    t.Helper()
+    tx, err := BuildTransaction("/citizens/register", []byte(registrationBody), publicKey, timestamp, nonce)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := tx.Sign(privateKey); err != nil {
+        t.Fatal(err)
+    }
+    r := httptest.NewRequest(http.MethodPost, "/citizens/register", strings.NewReader(registrationBody))
+    r.Header.Set(HeaderPublicKey, publicKey)
+    r.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+    r.Header.Set(HeaderNonce, nonce)
+    r.Header.Set(HeaderSignature, tx.Signature)
+    return r
+}
+
+// serve sends r to server and returns the response status
+func serve(server *Server, r *http.Request) int {
+    w := httptest.NewRecorder()
+    server.router.ServeHTTP(w, r)
+    return w.Code
+}
+
+func newTestChain() *blockchain.Chain {
+    return blockchain.NewChain(&blockchain.Genesis{Timestamp: time.Now().Add(-time.Hour).Unix()})
+}
+
+func TestAuthenticate(t *testing.T) {
+    now := time.Now().Unix()
+    tests := []struct {
+        name   string
+        change func(r *http.Request)
+        time   int64
+        want   int
+    }{
+        {"signed", nil, now, http.StatusOK},
+        {"unsigned", func(r *http.Request) { r.Header.Del(HeaderSignature) }, now, http.StatusUnauthorized},
+        {"other key", func(r *http.Request) { r.Header.Set(HeaderPublicKey, otherKey(t)) }, now, http.StatusUnauthorized},
+        {"altered nonce", func(r *http.Request) { r.Header.Set(HeaderNonce, "other") }, now, http.StatusUnauthorized},
+        {"long nonce", func(r *http.Request) { r.Header.Set(HeaderNonce, strings.Repeat("n", maxNonceLength+1)) }, now, http.StatusUnauthorized},
+        {"stale", nil, now - int64(2*requestWindow/time.Second), http.StatusUnauthorized},
+        {"from the future", nil, now + int64(2*requestWindow/time.Second), http.StatusUnauthorized},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            server := NewServer(newTestChain())
+            publicKey, privateKey, err := blockchain.GenerateKeyPair()
+            if err != nil {
+                t.Fatal(err)
+            }
+            r := signedRequest(t, publicKey, privateKey, tt.time, "nonce-1")
+            if tt.change != nil {
+                tt.change(r)
+            }
+            if got := serve(server, r); got != tt.want {
+                t.Errorf("status = %d, want %d", got, tt.want)
+            }
+        })
+    }
+}
+
+func otherKey(t *testing.T) string {
+    t.Helper()
+    publicKey, _, err := blockchain.GenerateKeyPair()
+    if err != nil {
+        t.Fatal(err)
+    }
+    return publicKey
+}
+
+func TestReplayedRequest(t *testing.T) {
+    chain := newTestChain()
+    server := NewServer(chain)
+    publicKey, privateKey, err := blockchain.GenerateKeyPair()
+    if err != nil {
+        t.Fatal(err)
+    }
+    now := time.Now().Unix()
+    request := func() *http.Request { return signedRequest(t, publicKey, privateKey, now, "nonce-1") }
+
+    if got := serve(server, request()); got != http.StatusOK {
+        t.Fatalf("first request status = %d", got)
+    }
+    // The nonce cache turns the copy away first
+    if got := serve(server, request()); got != http.StatusUnauthorized {
+        t.Errorf("resent request status = %d, want %d", got, http.StatusUnauthorized)
+    }
+
+    // A node without the nonce in its cache defers to the chain, which
+    // refuses the transaction both while pending and once committed
+    if got := serve(NewServer(chain), request()); got != http.StatusBadRequest {
+        t.Errorf("pending replay status = %d, want %d", got, http.StatusBadRequest)
+    }
+    block, err := chain.ProposeBlock(publicKey, 0, now)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := chain.AppendBlock(block); err != nil || len(block.Transactions) != 1 {
+        t.Fatalf("committing the request: %v, %d transactions", err, len(block.Transactions))
+    }
+    if got := serve(NewServer(chain), request()); got != http.StatusBadRequest {
+        t.Errorf("committed replay status = %d, want %d", got, http.StatusBadRequest)
+    }
+}
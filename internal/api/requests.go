@@ -0,0 +1,397 @@
+package api
+
+import (
+    "encoding/json"
+    "fmt"
+    "virtual_ethiopia_dap/internal/blockchain"
+)
+
+// Headers identifying the signer of a state-changing request. The signature
+// is the Ed25519 signature of the transaction the request submits, so clients
+// build that transaction with BuildTransaction and sign it themselves. The
+// timestamp and a random nonce are part of the signed transaction, which
+// keeps a request from being replayed.
+const (
+    HeaderPublicKey = "X-Public-Key"
+    HeaderTimestamp = "X-Timestamp"
+    HeaderNonce     = "X-Nonce"
+    HeaderSignature = "X-Signature"
+)
+
+// Request structures. The acting public key is never part of the body; it is
+// the key that signed the request.
+type TransactionRequest struct {
+    To     string  `json:"to"`
+    Amount float64 `json:"amount"`
+}
+
+// CitizenRegistrationRequest applies for citizenship. DocumentNumber, the
+// number of an identity document, is optional and only its hash is kept.
+type CitizenRegistrationRequest struct {
+    Name           string `json:"name"`
+    DateOfBirth    string `json:"dateOfBirth"`
+    DocumentNumber string `json:"documentNumber"`
+}
+
+type CitizenApprovalRequest struct {
+    CitizenID string `json:"citizenId"`
+}
+
+// CitizenStatusRequest rejects, suspends, reinstates or revokes a citizen,
+// depending on the route. Only a reinstatement may omit the reason, and
+// only a revocation may be for fraud.
+type CitizenStatusRequest struct {
+    CitizenID string `json:"citizenId"`
+    Reason    string `json:"reason"`
+    Fraud     bool   `json:"fraud"`
+}
+
+type VouchRequest struct {
+    CitizenID string `json:"citizenId"`
+}
+
+// GuardiansRequest names the citizens, by citizen ID, who may together
+// recover the signer's identity; Threshold of them must agree
+type GuardiansRequest struct {
+    Guardians []string `json:"guardians"`
+    Threshold int      `json:"threshold"`
+}
+
+// KeyRotationRequest moves the signer's identity to NewKey. It is signed
+// with the key being retired.
+type KeyRotationRequest struct {
+    NewKey string `json:"newKey"`
+}
+
+// KeyRecoveryRequest is a guardian's support for moving a citizen who lost
+// their key to NewKey
+type KeyRecoveryRequest struct {
+    CitizenID string `json:"citizenId"`
+    NewKey    string `json:"newKey"`
+}
+
+type AppealRequest struct {
+    Statement string `json:"statement"`
+}
+
+type AppealDecisionRequest struct {
+    CitizenID string `json:"citizenId"`
+    Grant     bool   `json:"grant"`
+}
+
+// AdminProposalRequest proposes to add or remove an admin; Action is
+// "add" or "remove"
+type AdminProposalRequest struct {
+    Action    string `json:"action"`
+    PublicKey string `json:"publicKey"`
+}
+
+type AdminApprovalRequest struct {
+    ProposalID string `json:"proposalId"`
+}
+
+type ElectionRequest struct {
+    Name         string `json:"name"`
+    DurationDays int    `json:"durationDays"`
+    blockchain.ElectionOptions
+}
+
+type CandidateRequest struct {
+    ElectionID string `json:"electionId"`
+    Name       string `json:"name"`
+    Platform   string `json:"platform"`
+}
+
+type EndorsementRequest struct {
+    ElectionID  string `json:"electionId"`
+    CandidateID string `json:"candidateId"`
+}
+
+type WithdrawalRequest struct {
+    ElectionID string `json:"electionId"`
+}
+
+// DelegationRequest names the citizen ID of the delegate and at most one of
+// an election and a category; with neither the delegation applies to every
+// election
+type DelegationRequest struct {
+    Delegate   string `json:"delegate"`
+    ElectionID string `json:"electionId,omitempty"`
+    Category   string `json:"category,omitempty"`
+}
+
+type DelegationRevocationRequest struct {
+    ElectionID string `json:"electionId,omitempty"`
+    Category   string `json:"category,omitempty"`
+}
+
+// VoteRequest carries the one ballot field the election's voting method
+// takes: a CandidateID, a Ranking of candidate IDs, most preferred first,
+// the Approvals of candidate IDs, or Scores by candidate ID
+type VoteRequest struct {
+    ElectionID  string         `json:"electionId"`
+    CandidateID string         `json:"candidateId,omitempty"`
+    Ranking     []string       `json:"ranking,omitempty"`
+    Approvals   []string       `json:"approvals,omitempty"`
+    Scores      map[string]int `json:"scores,omitempty"`
+}
+
+func (r *VoteRequest) ballot() blockchain.Ballot {
+    return blockchain.Ballot{
+        CandidateID: r.CandidateID,
+        Ranking:     r.Ranking,
+        Approvals:   r.Approvals,
+        Scores:      r.Scores,
+    }
+}
+
+type VoteCommitRequest struct {
+    ElectionID string `json:"electionId"`
+    Commitment string `json:"commitment"`
+}
+
+// VoteRevealRequest carries the revealed ballot as a VoteRequest does
+type VoteRevealRequest struct {
+    VoteRequest
+    Salt string `json:"salt"`
+}
+
+type TrusteeDealingRequest struct {
+    ElectionID string                    `json:"electionId"`
+    Dealing    blockchain.TrusteeDealing `json:"dealing"`
+}
+
+type EncryptedVoteRequest struct {
+    ElectionID string                     `json:"electionId"`
+    Ballot     blockchain.EncryptedBallot `json:"ballot"`
+}
+
+type TallyDecryptionRequest struct {
+    ElectionID string                     `json:"electionId"`
+    Share      blockchain.DecryptionShare `json:"share"`
+}
+
+type EndElectionRequest struct {
+    ElectionID string `json:"electionId"`
+}
+
+// transactionBuilder turns a decoded request body into the transaction sent
+// by signer
+type transactionBuilder func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error)
+
+// transactionBuilders maps every state-changing route to its builder
+var transactionBuilders = map[string]transactionBuilder{
+    "/transactions": func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req TransactionRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewTransactionAt(signer, req.To, req.Amount, timestamp), nil
+    },
+    "/citizens/register": func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req CitizenRegistrationRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewCitizenRegistrationTx(req.Name, req.DateOfBirth, req.DocumentNumber, signer, timestamp)
+    },
+    "/citizens/approve": func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req CitizenApprovalRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewCitizenApprovalTx(signer, req.CitizenID, timestamp)
+    },
+    "/citizens/reject":    citizenStatusBuilder(blockchain.TxCitizenRejection),
+    "/citizens/suspend":   citizenStatusBuilder(blockchain.TxCitizenSuspension),
+    "/citizens/reinstate": citizenStatusBuilder(blockchain.TxCitizenReinstatement),
+    "/citizens/revoke":    citizenStatusBuilder(blockchain.TxCitizenRevocation),
+    "/citizens/vouch": func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req VouchRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewCitizenVouchTx(signer, req.CitizenID, timestamp)
+    },
+    "/citizens/guardians": func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req GuardiansRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewGuardianDesignationTx(signer, req.Guardians, req.Threshold, timestamp)
+    },
+    "/citizens/keys/rotate": func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req KeyRotationRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewKeyRotationTx(signer, req.NewKey, timestamp)
+    },
+    "/citizens/keys/recover": func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req KeyRecoveryRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewKeyRecoveryTx(signer, req.CitizenID, req.NewKey, timestamp)
+    },
+    "/citizens/appeal": func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req AppealRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewCitizenAppealTx(signer, req.Statement, timestamp)
+    },
+    "/citizens/appeals/decide": func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req AppealDecisionRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewAppealDecisionTx(signer, req.CitizenID, req.Grant, timestamp)
+    },
+    "/admins/propose": func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req AdminProposalRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewAdminProposalTx(signer, req.Action, req.PublicKey, timestamp)
+    },
+    "/admins/approve": func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req AdminApprovalRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewAdminApprovalTx(signer, req.ProposalID, timestamp)
+    },
+    "/elections/start": func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req ElectionRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewElectionStartTx(signer, req.Name, req.DurationDays, req.ElectionOptions, timestamp)
+    },
+    "/elections/candidates": func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req CandidateRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewCandidateRegistrationTx(req.ElectionID, req.Name, signer, req.Platform, timestamp)
+    },
+    "/elections/candidates/endorse": func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req EndorsementRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewCandidateEndorsementTx(req.ElectionID, req.CandidateID, signer, timestamp)
+    },
+    "/elections/candidates/withdraw": func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req WithdrawalRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewCandidateWithdrawalTx(req.ElectionID, signer, timestamp)
+    },
+    "/delegations": func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req DelegationRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewDelegationTx(signer, req.Delegate, req.ElectionID, req.Category, timestamp)
+    },
+    "/delegations/revoke": func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req DelegationRevocationRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewDelegationRevocationTx(signer, req.ElectionID, req.Category, timestamp)
+    },
+    "/elections/vote": func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req VoteRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewBallotTx(req.ElectionID, signer, req.ballot(), timestamp)
+    },
+    "/elections/commit": func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req VoteCommitRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewVoteCommitTx(req.ElectionID, signer, req.Commitment, timestamp)
+    },
+    "/elections/close": func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req EndElectionRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewVotingCloseTx(signer, req.ElectionID, timestamp)
+    },
+    "/elections/reveal": func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req VoteRevealRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewVoteRevealTx(req.ElectionID, signer, req.ballot(), req.Salt, timestamp)
+    },
+    "/elections/trustees/deal": func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req TrusteeDealingRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewTrusteeDealingTx(req.ElectionID, signer, req.Dealing, timestamp)
+    },
+    "/elections/ballot": func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req EncryptedVoteRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewEncryptedVoteTx(req.ElectionID, signer, req.Ballot, timestamp)
+    },
+    "/elections/trustees/decrypt": func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req TallyDecryptionRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewTallyDecryptionTx(req.ElectionID, signer, req.Share, timestamp)
+    },
+    "/elections/end": func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req EndElectionRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewElectionEndTx(signer, req.ElectionID, timestamp)
+    },
+}
+
+// citizenStatusBuilder returns the builder of the admin decision txType
+func citizenStatusBuilder(txType string) transactionBuilder {
+    return func(body []byte, signer string, timestamp int64) (*blockchain.Transaction, error) {
+        var req CitizenStatusRequest
+        if err := decodeRequest(body, &req); err != nil {
+            return nil, err
+        }
+        return blockchain.NewCitizenStatusChangeTx(signer, txType, req.CitizenID, req.Reason, req.Fraud, timestamp)
+    }
+}
+
+// BuildTransaction returns the unsigned transaction that a POST of body to
+// path submits on behalf of signer. The server and clients both use it, so
+// a client signs exactly the transaction the server will verify.
+func BuildTransaction(path string, body []byte, signer string, timestamp int64, nonce string) (*blockchain.Transaction, error) {
+    build, ok := transactionBuilders[path]
+    if !ok {
+        return nil, fmt.Errorf("no transaction is submitted by %s", path)
+    }
+    tx, err := build(body, signer, timestamp)
+    if err != nil {
+        return nil, err
+    }
+    tx.SetNonce(nonce)
+    return tx, nil
+}
+
+func decodeRequest(body []byte, req interface{}) error {
+    if err := json.Unmarshal(body, req); err != nil {
+        return fmt.Errorf("invalid request data")
+    }
+    return nil
+}
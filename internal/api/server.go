@@ -2,8 +2,10 @@ package api
 
 import (
     "encoding/json"
+    "io"
     "log"
     "net/http"
+    "strings"
     "github.com/gorilla/mux"
     "virtual_ethiopia_dap/internal/blockchain"
 )
@@ -11,6 +13,7 @@ import (
 type Server struct {
     chain  *blockchain.Chain
     router *mux.Router
+    nonces *nonceCache
 }
 
 // Response structure for all API responses
@@ -20,44 +23,18 @@ type Response struct {
     Error   string      `json:"error,omitempty"`
 }
 
-// Request structures
-type TransactionRequest struct {
-    From   string  `json:"from"`
-    To     string  `json:"to"`
-    Amount float64 `json:"amount"`
-}
-
-type CitizenRegistrationRequest struct {
-    Name        string `json:"name"`
-    DateOfBirth string `json:"dateOfBirth"`
-    PublicKey   string `json:"publicKey"`
-}
-
-type CitizenApprovalRequest struct {
-    CitizenID   string `json:"citizenId"`
-    ApproverKey string `json:"approverKey"`
-}
-
-type ElectionRequest struct {
-    Name         string `json:"name"`
-    DurationDays int    `json:"durationDays"`
-}
-
-type CandidateRequest struct {
-    Name      string `json:"name"`
-    PublicKey string `json:"publicKey"`
-    Platform  string `json:"platform"`
-}
-
-type VoteRequest struct {
-    CitizenPublicKey string `json:"citizenPublicKey"`
-    CandidateID      string `json:"candidateId"`
+// TransactionProofResponse is returned by /transactions/{id}/proof
+type TransactionProofResponse struct {
+    Transaction *blockchain.Transaction `json:"transaction"`
+    Proof       *blockchain.MerkleProof `json:"proof"`
+    BlockHash   string                  `json:"blockHash"`
 }
 
 func NewServer(chain *blockchain.Chain) *Server {
     server := &Server{
         chain:  chain,
         router: mux.NewRouter(),
+        nonces: newNonceCache(),
     }
     server.setupRoutes()
     return server
@@ -70,18 +47,55 @@ func (s *Server) setupRoutes() {
 
     // Blockchain endpoints
     s.router.HandleFunc("/blocks", s.handleGetBlocks).Methods("GET")
-    s.router.HandleFunc("/transactions", s.handleAddTransaction).Methods("POST")
+    s.router.HandleFunc("/transactions", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/transactions/{id}/proof", s.handleGetTransactionProof).Methods("GET")
     
     // Citizen registry endpoints
-    s.router.HandleFunc("/citizens/register", s.handleRegisterCitizen).Methods("POST")
-    s.router.HandleFunc("/citizens/approve", s.handleApproveCitizen).Methods("POST")
+    s.router.HandleFunc("/citizens/register", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/citizens/approve", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/citizens/reject", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/citizens/suspend", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/citizens/reinstate", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/citizens/revoke", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/citizens/vouch", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/citizens/guardians", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/citizens/keys/rotate", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/citizens/keys/recover", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/citizens/appeal", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/citizens/appeals/decide", s.handleSignedTransaction).Methods("POST")
     s.router.HandleFunc("/citizens", s.handleGetAllCitizens).Methods("GET")
+    s.router.HandleFunc("/citizens/appeals", s.handleGetOpenAppeals).Methods("GET")
+    s.router.HandleFunc("/citizens/duplicates", s.handleGetDuplicateClusters).Methods("GET")
+    s.router.HandleFunc("/citizens/{id}", s.handleGetCitizen).Methods("GET")
     
+    // Admin governance endpoints
+    s.router.HandleFunc("/admins/propose", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/admins/approve", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/admins", s.handleGetAdmins).Methods("GET")
+    s.router.HandleFunc("/admins/proposals", s.handleGetAdminProposals).Methods("GET")
+
     // Election endpoints
-    s.router.HandleFunc("/elections/start", s.handleStartElection).Methods("POST")
-    s.router.HandleFunc("/elections/candidates", s.handleRegisterCandidate).Methods("POST")
-    s.router.HandleFunc("/elections/vote", s.handleCastVote).Methods("POST")
+    s.router.HandleFunc("/elections/start", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/elections/candidates", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/elections/candidates/endorse", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/elections/candidates/withdraw", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/elections/vote", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/elections/commit", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/elections/close", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/elections/reveal", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/elections/trustees/deal", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/elections/ballot", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/elections/trustees/decrypt", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/elections/end", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/elections", s.handleGetElections).Methods("GET")
     s.router.HandleFunc("/elections/current", s.handleGetCurrentElection).Methods("GET")
+    s.router.HandleFunc("/elections/{id}", s.handleGetElection).Methods("GET")
+    s.router.HandleFunc("/elections/{id}/candidates", s.handleGetElectionCandidates).Methods("GET")
+
+    // Delegation endpoints
+    s.router.HandleFunc("/delegations", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/delegations/revoke", s.handleSignedTransaction).Methods("POST")
+    s.router.HandleFunc("/delegations", s.handleGetDelegations).Methods("GET")
 
     // Health check
     s.router.HandleFunc("/health", s.handleHealth).Methods("GET")
@@ -98,47 +112,23 @@ func (s *Server) handleGetBlocks(w http.ResponseWriter, r *http.Request) {
     sendSuccess(w, blocks)
 }
 
-func (s *Server) handleAddTransaction(w http.ResponseWriter, r *http.Request) {
-    var req TransactionRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        sendError(w, "Invalid transaction data", http.StatusBadRequest)
-        return
-    }
-
-    tx, err := s.chain.AddTransaction(req.From, req.To, req.Amount)
+// handleSignedTransaction builds the transaction for a state-changing route
+// from the request body and the signing headers, and submits it once the
+// signature has been verified
+func (s *Server) handleSignedTransaction(w http.ResponseWriter, r *http.Request) {
+    body, err := io.ReadAll(r.Body)
     if err != nil {
-        sendError(w, err.Error(), http.StatusBadRequest)
-        return
-    }
-
-    sendSuccess(w, tx)
-}
-
-func (s *Server) handleRegisterCitizen(w http.ResponseWriter, r *http.Request) {
-    var req CitizenRegistrationRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
         sendError(w, "Invalid request data", http.StatusBadRequest)
         return
     }
 
-    tx, err := s.chain.AddCitizenRegistration(req.Name, req.DateOfBirth, req.PublicKey)
+    tx, status, err := s.authenticate(r, body)
     if err != nil {
-        sendError(w, err.Error(), http.StatusBadRequest)
-        return
-    }
-
-    sendSuccess(w, tx)
-}
-
-func (s *Server) handleApproveCitizen(w http.ResponseWriter, r *http.Request) {
-    var req CitizenApprovalRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        sendError(w, "Invalid request data", http.StatusBadRequest)
+        sendError(w, err.Error(), status)
         return
     }
 
-    tx, err := s.chain.ApproveCitizen(req.CitizenID, req.ApproverKey)
-    if err != nil {
+    if err := s.chain.SubmitTransaction(tx); err != nil {
         sendError(w, err.Error(), http.StatusBadRequest)
         return
     }
@@ -146,57 +136,56 @@ func (s *Server) handleApproveCitizen(w http.ResponseWriter, r *http.Request) {
     sendSuccess(w, tx)
 }
 
-func (s *Server) handleStartElection(w http.ResponseWriter, r *http.Request) {
-    var req ElectionRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        sendError(w, "Invalid request data", http.StatusBadRequest)
-        return
-    }
-
-    tx, err := s.chain.StartElection(req.Name, req.DurationDays)
+// handleGetTransactionProof returns a committed transaction with the Merkle
+// proof of its inclusion, which blockchain.VerifyMerkleProof checks against
+// the block hash
+func (s *Server) handleGetTransactionProof(w http.ResponseWriter, r *http.Request) {
+    tx, proof, found, err := s.chain.ProveTransaction(mux.Vars(r)["id"])
     if err != nil {
         sendError(w, err.Error(), http.StatusBadRequest)
         return
     }
-
-    sendSuccess(w, tx)
-}
-
-func (s *Server) handleRegisterCandidate(w http.ResponseWriter, r *http.Request) {
-    var req CandidateRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        sendError(w, "Invalid request data", http.StatusBadRequest)
+    if !found {
+        sendError(w, "Transaction not found in any committed block", http.StatusNotFound)
         return
     }
 
-    tx, err := s.chain.RegisterCandidate(req.Name, req.PublicKey, req.Platform)
-    if err != nil {
-        sendError(w, err.Error(), http.StatusBadRequest)
-        return
-    }
+    block, _ := s.chain.GetBlock(proof.Header.Index)
+    sendSuccess(w, TransactionProofResponse{
+        Transaction: tx,
+        Proof:       proof,
+        BlockHash:   block.HashHex(),
+    })
+}
 
-    sendSuccess(w, tx)
+func (s *Server) handleGetAllCitizens(w http.ResponseWriter, r *http.Request) {
+    citizens := s.chain.GetAllCitizens()
+    sendSuccess(w, citizens)
 }
 
-func (s *Server) handleCastVote(w http.ResponseWriter, r *http.Request) {
-    var req VoteRequest
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-        sendError(w, "Invalid request data", http.StatusBadRequest)
+func (s *Server) handleGetCitizen(w http.ResponseWriter, r *http.Request) {
+    citizen, found := s.chain.GetCitizenByID(mux.Vars(r)["id"])
+    if !found {
+        sendError(w, "Citizen not found", http.StatusNotFound)
         return
     }
+    sendSuccess(w, citizen)
+}
 
-    tx, err := s.chain.CastVote(req.CitizenPublicKey, req.CandidateID)
-    if err != nil {
-        sendError(w, err.Error(), http.StatusBadRequest)
-        return
-    }
+func (s *Server) handleGetOpenAppeals(w http.ResponseWriter, r *http.Request) {
+    sendSuccess(w, s.chain.GetOpenAppeals())
+}
 
-    sendSuccess(w, tx)
+func (s *Server) handleGetDuplicateClusters(w http.ResponseWriter, r *http.Request) {
+    sendSuccess(w, s.chain.GetDuplicateClusters())
 }
 
-func (s *Server) handleGetAllCitizens(w http.ResponseWriter, r *http.Request) {
-    citizens := s.chain.GetAllCitizens()
-    sendSuccess(w, citizens)
+func (s *Server) handleGetAdmins(w http.ResponseWriter, r *http.Request) {
+    sendSuccess(w, s.chain.GetAdmins())
+}
+
+func (s *Server) handleGetAdminProposals(w http.ResponseWriter, r *http.Request) {
+    sendSuccess(w, s.chain.GetAdminProposals())
 }
 
 func (s *Server) handleGetCurrentElection(w http.ResponseWriter, r *http.Request) {
@@ -208,6 +197,45 @@ func (s *Server) handleGetCurrentElection(w http.ResponseWriter, r *http.Request
     sendSuccess(w, election)
 }
 
+// handleGetElections lists elections in the order they started, optionally
+// only those whose status is in the comma-separated status parameter
+func (s *Server) handleGetElections(w http.ResponseWriter, r *http.Request) {
+    var statuses []blockchain.ElectionStatus
+    if filter := r.URL.Query().Get("status"); filter != "" {
+        for _, name := range strings.Split(filter, ",") {
+            status, err := blockchain.ParseElectionStatus(name)
+            if err != nil {
+                sendError(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+            statuses = append(statuses, status)
+        }
+    }
+    sendSuccess(w, s.chain.GetElections(statuses...))
+}
+
+func (s *Server) handleGetElection(w http.ResponseWriter, r *http.Request) {
+    election, found := s.chain.GetElection(mux.Vars(r)["id"])
+    if !found {
+        sendError(w, "Election not found", http.StatusNotFound)
+        return
+    }
+    sendSuccess(w, election)
+}
+
+func (s *Server) handleGetElectionCandidates(w http.ResponseWriter, r *http.Request) {
+    election, found := s.chain.GetElection(mux.Vars(r)["id"])
+    if !found {
+        sendError(w, "Election not found", http.StatusNotFound)
+        return
+    }
+    sendSuccess(w, election.Candidates)
+}
+
+func (s *Server) handleGetDelegations(w http.ResponseWriter, r *http.Request) {
+    sendSuccess(w, s.chain.GetDelegations())
+}
+
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
     sendSuccess(w, map[string]string{"status": "ok"})
 }
@@ -242,7 +270,7 @@ func corsMiddleware(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
         w.Header().Set("Access-Control-Allow-Origin", "*")
         w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-        w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+        w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+HeaderPublicKey+", "+HeaderTimestamp+", "+HeaderNonce+", "+HeaderSignature)
         
         if r.Method == "OPTIONS" {
             w.WriteHeader(http.StatusOK)
@@ -0,0 +1,105 @@
+package api
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "slices"
+    "testing"
+    "time"
+    "virtual_ethiopia_dap/internal/blockchain"
+)
+
+// electionChain returns a chain with two elections, the first of them
+// ended, and their IDs
+func electionChain(t *testing.T) (*blockchain.Chain, string, string) {
+    t.Helper()
+    admin, adminKey, err := blockchain.GenerateKeyPair()
+    if err != nil {
+        t.Fatal(err)
+    }
+    now := time.Now().Unix()
+    chain := blockchain.NewChain(&blockchain.Genesis{Timestamp: now - 60, Admins: []string{admin}})
+
+    commit := func(tx *blockchain.Transaction, err error) string {
+        t.Helper()
+        if err != nil {
+            t.Fatal(err)
+        }
+        tx.SetNonce(tx.ID)
+        if err := tx.Sign(adminKey); err != nil {
+            t.Fatal(err)
+        }
+        if err := chain.SubmitTransaction(tx); err != nil {
+            t.Fatal(err)
+        }
+        head, _ := chain.GetLatestBlock()
+        block, err := chain.ProposeBlock(admin, 0, head.Timestamp+1)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if err := chain.AppendBlock(block); err != nil {
+            t.Fatal(err)
+        }
+        return tx.ID
+    }
+    first := commit(blockchain.NewElectionStartTx(admin, "First", 7, blockchain.ElectionOptions{}, now))
+    second := commit(blockchain.NewElectionStartTx(admin, "Second", 7, blockchain.ElectionOptions{}, now))
+    commit(blockchain.NewElectionEndTx(admin, first, now))
+    return chain, first, second
+}
+
+func TestElectionHistory(t *testing.T) {
+    chain, first, second := electionChain(t)
+    server := NewServer(chain)
+    tests := []struct {
+        path   string
+        status int
+        ids    []string // elections listed, if the response is a list
+    }{
+        {"/elections", http.StatusOK, []string{first, second}},
+        {"/elections?status=completed", http.StatusOK, []string{first}},
+        {"/elections?status=in-progress,completed", http.StatusOK, []string{first, second}},
+        {"/elections?status=cancelled", http.StatusOK, []string{}},
+        {"/elections?status=over", http.StatusBadRequest, nil},
+        {"/elections/current", http.StatusOK, []string{second}},
+        {"/elections/" + first, http.StatusOK, []string{first}},
+        {"/elections/unknown", http.StatusNotFound, nil},
+        {"/elections/" + second + "/candidates", http.StatusOK, nil},
+        {"/elections/unknown/candidates", http.StatusNotFound, nil},
+    }
+    for _, tt := range tests {
+        t.Run(tt.path, func(t *testing.T) {
+            w := httptest.NewRecorder()
+            server.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+            if w.Code != tt.status {
+                t.Fatalf("status = %d, want %d", w.Code, tt.status)
+            }
+            if tt.ids == nil {
+                return
+            }
+
+            var response struct {
+                Data json.RawMessage `json:"data"`
+            }
+            if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+                t.Fatal(err)
+            }
+            var elections []blockchain.Election
+            if err := json.Unmarshal(response.Data, &elections); err != nil {
+                var election blockchain.Election
+                if err := json.Unmarshal(response.Data, &election); err != nil {
+                    t.Fatal(err)
+                }
+                elections = []blockchain.Election{election}
+            }
+            ids := []string{}
+            for _, election := range elections {
+                ids = append(ids, election.ID)
+            }
+            if !slices.Equal(ids, tt.ids) {
+                t.Errorf("elections = %v, want %v", ids, tt.ids)
+            }
+        })
+    }
+}
@@ -0,0 +1,170 @@
+package blockchain
+
+import (
+    "errors"
+    "slices"
+    "sort"
+)
+
+// Admin set changes a proposal can make
+const (
+    AdminAdd    = "add"
+    AdminRemove = "remove"
+)
+
+// AdminProposal is a pending change to the admin set. The admin who
+// proposes it approves it too.
+type AdminProposal struct {
+    ID           string   `json:"id"` // ID of the proposing transaction
+    Action       string   `json:"action"`
+    PublicKey    string   `json:"publicKey"` // admin added or removed
+    Proposer     string   `json:"proposer"`
+    ProposedDate int64    `json:"proposedDate"`
+    Approvals    []string `json:"approvals"`
+}
+
+// ProposeAdminChange opens a proposal by an admin to add or remove an
+// admin. It applies at once if the proposer's approval is enough.
+func (cr *CitizenRegistry) ProposeAdminChange(id, proposerKey, action, publicKey string, ctx BlockContext) error {
+    cr.mu.Lock()
+    defer cr.mu.Unlock()
+
+    if !cr.admins[proposerKey] {
+        return errors.New("only admins may propose admin changes")
+    }
+    if err := ValidatePublicKey(publicKey); err != nil {
+        return err
+    }
+    if _, exists := cr.proposals[id]; exists {
+        return errors.New("proposal already exists")
+    }
+    for _, proposal := range cr.proposals {
+        if proposal.Action == action && proposal.PublicKey == publicKey {
+            return errors.New("the same change is already proposed")
+        }
+    }
+
+    proposal := &AdminProposal{
+        ID:           id,
+        Action:       action,
+        PublicKey:    publicKey,
+        Proposer:     proposerKey,
+        ProposedDate: ctx.Timestamp,
+        Approvals:    []string{proposerKey},
+    }
+    if err := cr.checkAdminChange(proposal); err != nil {
+        return err
+    }
+    cr.proposals[id] = proposal
+    cr.applyIfApproved(proposal, proposerKey, ctx)
+    return nil
+}
+
+// ApproveAdminChange records an admin's approval of a proposal, and makes
+// the change once enough admins have approved it
+func (cr *CitizenRegistry) ApproveAdminChange(id, adminKey string, ctx BlockContext) error {
+    cr.mu.Lock()
+    defer cr.mu.Unlock()
+
+    if !cr.admins[adminKey] {
+        return errors.New("only admins may approve admin changes")
+    }
+    proposal, exists := cr.proposals[id]
+    if !exists {
+        return errors.New("proposal not found")
+    }
+    if slices.Contains(proposal.Approvals, adminKey) {
+        return errors.New("admin has already approved this proposal")
+    }
+    if err := cr.checkAdminChange(proposal); err != nil {
+        return err
+    }
+
+    proposal.Approvals = append(proposal.Approvals, adminKey)
+    cr.applyIfApproved(proposal, adminKey, ctx)
+    return nil
+}
+
+// checkAdminChange verifies that a proposal still makes sense for the
+// current admin set
+func (cr *CitizenRegistry) checkAdminChange(proposal *AdminProposal) error {
+    switch proposal.Action {
+    case AdminAdd:
+        if cr.admins[proposal.PublicKey] {
+            return errors.New("already an admin")
+        }
+    case AdminRemove:
+        if !cr.admins[proposal.PublicKey] {
+            return errors.New("not an admin")
+        }
+        if len(cr.admins) == 1 {
+            return errors.New("the last admin cannot be removed")
+        }
+    default:
+        return errors.New("admin change must be add or remove")
+    }
+    return nil
+}
+
+// applyIfApproved makes the change of a proposal approved by enough of the
+// current admins, and settles the open appeals the change decides.
+// adminKey is the admin whose approval completed the proposal.
+func (cr *CitizenRegistry) applyIfApproved(proposal *AdminProposal, adminKey string, ctx BlockContext) {
+    if cr.countAdmins(proposal.Approvals) < cr.adminQuorum() {
+        return
+    }
+    if proposal.Action == AdminAdd {
+        cr.admins[proposal.PublicKey] = true
+    } else {
+        delete(cr.admins, proposal.PublicKey)
+    }
+    delete(cr.proposals, proposal.ID)
+    cr.settleAppeals(adminKey, ctx)
+}
+
+// GetAdmins returns the public keys of the admins, sorted
+func (cr *CitizenRegistry) GetAdmins() []string {
+    cr.mu.RLock()
+    defer cr.mu.RUnlock()
+    return sortedKeys(cr.admins)
+}
+
+// GetAdminProposals returns the pending admin changes, oldest first
+func (cr *CitizenRegistry) GetAdminProposals() []*AdminProposal {
+    cr.mu.RLock()
+    defer cr.mu.RUnlock()
+
+    proposals := make([]*AdminProposal, 0, len(cr.proposals))
+    for _, id := range sortedKeys(cr.proposals) {
+        proposals = append(proposals, cr.proposals[id])
+    }
+    sort.SliceStable(proposals, func(i, j int) bool {
+        return proposals[i].ProposedDate < proposals[j].ProposedDate
+    })
+    return proposals
+}
+
+// countAdmins returns how many of keys belong to current admins
+func (cr *CitizenRegistry) countAdmins(keys []string) int {
+    count := 0
+    for _, key := range keys {
+        if cr.admins[key] {
+            count++
+        }
+    }
+    return count
+}
+
+// adminQuorum returns the number of admins who must approve a change to
+// the admin set
+func (cr *CitizenRegistry) adminQuorum() int {
+    if cr.policy.AdminThreshold == 0 {
+        return len(cr.admins)/2 + 1
+    }
+    return min(cr.policy.AdminThreshold, len(cr.admins))
+}
+
+// citizenQuorum returns the number of admins who must approve a citizen
+func (cr *CitizenRegistry) citizenQuorum() int {
+    return max(1, min(cr.policy.ApprovalThreshold, len(cr.admins)))
+}
@@ -0,0 +1,189 @@
+package blockchain
+
+import (
+    "slices"
+    "testing"
+)
+
+// proposeAdminChange has the admin propose a change and returns the
+// proposal ID
+func (s *testState) proposeAdminChange(admin testKey, action, publicKey string) (string, error) {
+    s.t.Helper()
+    tx := signedBy(s.t, admin)(NewAdminProposalTx(admin.Public, action, publicKey, s.ctx.Timestamp))
+    return tx.ID, s.apply(tx)
+}
+
+// approveAdminChange has the admin approve a proposal
+func (s *testState) approveAdminChange(admin testKey, proposalID string) error {
+    s.t.Helper()
+    return s.apply(signedBy(s.t, admin)(NewAdminApprovalTx(admin.Public, proposalID, s.ctx.Timestamp)))
+}
+
+func TestAdminQuorum(t *testing.T) {
+    tests := []struct {
+        name      string
+        admins    int
+        threshold int // policy AdminThreshold
+        action    string
+        approvers []int // admins approving, the first of them proposing
+        applied   bool
+    }{
+        {"sole admin", 1, 0, AdminAdd, []int{0}, true},
+        {"one of three", 3, 0, AdminAdd, []int{0}, false},
+        {"majority of three", 3, 0, AdminAdd, []int{0, 1}, true},
+        {"half of four", 4, 0, AdminAdd, []int{0, 3}, false},
+        {"majority of four", 4, 0, AdminAdd, []int{0, 3, 1}, true},
+        {"two of three required", 3, 3, AdminAdd, []int{0, 1}, false},
+        {"three of three", 3, 3, AdminAdd, []int{2, 1, 0}, true},
+        {"threshold above the admins", 2, 5, AdminAdd, []int{0, 1}, true},
+        {"remove by majority", 3, 0, AdminRemove, []int{0, 1}, true},
+        {"remove without majority", 3, 0, AdminRemove, []int{0}, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s, admins := newTestStateWithAdmins(t, RegistryPolicy{AdminThreshold: tt.threshold}, tt.admins)
+            subject := newTestKey(t).Public
+            if tt.action == AdminRemove {
+                subject = admins[len(admins)-1].Public
+            }
+
+            proposer := admins[tt.approvers[0]]
+            id, err := s.proposeAdminChange(proposer, tt.action, subject)
+            if err != nil {
+                t.Fatal(err)
+            }
+            for _, i := range tt.approvers[1:] {
+                if err := s.approveAdminChange(admins[i], id); err != nil {
+                    t.Fatal(err)
+                }
+            }
+
+            isAdmin := slices.Contains(s.citizenRegistry.GetAdmins(), subject)
+            if applied := isAdmin == (tt.action == AdminAdd); applied != tt.applied {
+                t.Errorf("applied = %v, want %v", applied, tt.applied)
+            }
+            if pending := len(s.citizenRegistry.GetAdminProposals()) == 1; pending == tt.applied {
+                t.Errorf("proposal pending = %v, want %v", pending, !tt.applied)
+            }
+        })
+    }
+}
+
+func TestAdminChangeRules(t *testing.T) {
+    tests := []struct {
+        name string
+        act  func(s *testState, admins []testKey) error
+    }{
+        {"proposal by a citizen", func(s *testState, admins []testKey) error {
+            citizen, _ := s.citizen("Citizen")
+            _, err := s.proposeAdminChange(citizen, AdminAdd, citizen.Public)
+            return err
+        }},
+        {"approval by a citizen", func(s *testState, admins []testKey) error {
+            citizen, _ := s.citizen("Citizen")
+            id, _ := s.proposeAdminChange(admins[0], AdminAdd, citizen.Public)
+            return s.approveAdminChange(citizen, id)
+        }},
+        {"approval twice", func(s *testState, admins []testKey) error {
+            id, _ := s.proposeAdminChange(admins[0], AdminAdd, newTestKey(s.t).Public)
+            return s.approveAdminChange(admins[0], id)
+        }},
+        {"unknown proposal", func(s *testState, admins []testKey) error {
+            return s.approveAdminChange(admins[1], "unknown")
+        }},
+        {"same change twice", func(s *testState, admins []testKey) error {
+            key := newTestKey(s.t).Public
+            s.proposeAdminChange(admins[0], AdminAdd, key)
+            _, err := s.proposeAdminChange(admins[1], AdminAdd, key)
+            return err
+        }},
+        {"add an admin", func(s *testState, admins []testKey) error {
+            _, err := s.proposeAdminChange(admins[0], AdminAdd, admins[1].Public)
+            return err
+        }},
+        {"remove a non-admin", func(s *testState, admins []testKey) error {
+            _, err := s.proposeAdminChange(admins[0], AdminRemove, newTestKey(s.t).Public)
+            return err
+        }},
+        {"malformed key", func(s *testState, admins []testKey) error {
+            _, err := s.proposeAdminChange(admins[0], AdminAdd, "abcd")
+            return err
+        }},
+        {"unknown action", func(s *testState, admins []testKey) error {
+            _, err := s.proposeAdminChange(admins[0], "promote", newTestKey(s.t).Public)
+            return err
+        }},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s, admins := newTestStateWithAdmins(t, RegistryPolicy{}, 3)
+            if err := tt.act(s, admins); err == nil {
+                t.Error("change was accepted")
+            }
+        })
+    }
+}
+
+func TestLastAdminStays(t *testing.T) {
+    s, admins := newTestStateWithAdmins(t, RegistryPolicy{}, 2)
+    id, err := s.proposeAdminChange(admins[0], AdminRemove, admins[1].Public)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := s.approveAdminChange(admins[1], id); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.proposeAdminChange(admins[0], AdminRemove, admins[0].Public); err == nil {
+        t.Error("the last admin was removed")
+    }
+}
+
+func TestRemovedAdminApprovalsLapse(t *testing.T) {
+    s, admins := newTestStateWithAdmins(t, RegistryPolicy{}, 4)
+    candidate := newTestKey(t).Public
+    addition, err := s.proposeAdminChange(admins[0], AdminAdd, candidate)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := s.approveAdminChange(admins[1], addition); err != nil {
+        t.Fatal(err)
+    }
+
+    removal, err := s.proposeAdminChange(admins[0], AdminRemove, admins[1].Public)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, admin := range admins[2:] {
+        if err := s.approveAdminChange(admin, removal); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    // Two of the three remaining admins are a majority, but only one of
+    // the addition's approvals is still an admin's
+    if slices.Contains(s.citizenRegistry.GetAdmins(), candidate) {
+        t.Fatal("addition applied with the approval of a removed admin")
+    }
+    if err := s.approveAdminChange(admins[2], addition); err != nil {
+        t.Fatal(err)
+    }
+    if !slices.Contains(s.citizenRegistry.GetAdmins(), candidate) {
+        t.Error("addition was not applied once a current majority approved")
+    }
+}
+
+func TestCitizenApprovalThreshold(t *testing.T) {
+    s, admins := newTestStateWithAdmins(t, RegistryPolicy{ApprovalThreshold: 2}, 3)
+    _, id := s.register("Applicant")
+    s.mustApply(signedBy(t, admins[0])(NewCitizenApprovalTx(admins[0].Public, id, s.ctx.Timestamp)))
+    if err := s.apply(signedBy(t, admins[0])(NewCitizenApprovalTx(admins[0].Public, id, s.ctx.Timestamp))); err == nil {
+        t.Error("admin approved the same citizen twice")
+    }
+    if got := s.status(id); got != Pending {
+        t.Fatalf("status after one approval = %v, want pending", got)
+    }
+    s.mustApply(signedBy(t, admins[2])(NewCitizenApprovalTx(admins[2].Public, id, s.ctx.Timestamp)))
+    if got := s.status(id); got != Approved {
+        t.Errorf("status after two approvals = %v, want approved", got)
+    }
+}
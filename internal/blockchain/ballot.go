@@ -0,0 +1,197 @@
+package blockchain
+
+import (
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// Ballot is an open ballot, or the content of a revealed secret one. The
+// election's voting method decides which single field it fills.
+type Ballot struct {
+    CandidateID string         `json:"candidateID,omitempty"` // plurality
+    Ranking     []string       `json:"ranking,omitempty"`     // instant-runoff and stv, most preferred first
+    Approvals   []string       `json:"approvals,omitempty"`   // approval
+    Scores      map[string]int `json:"scores,omitempty"`      // score: candidate ID -> score
+}
+
+// ballotDomain separates ballot commitments from every other hash
+const ballotDomain = "virtual-ethiopia/ballot"
+
+// minSaltSize is the smallest salt, in bytes, that keeps a commitment from
+// being opened by trying every candidate
+const minSaltSize = 16
+
+// BallotCommitment returns the commitment to a secret ballot whose choice
+// string is choice; see ParseBallot. It binds the election and the voter,
+// so a commitment copied from another voter or election cannot be revealed
+// by anyone else.
+func BallotCommitment(electionID, voterKey, choice, salt string) string {
+    var e encoder
+    e.string(ballotDomain)
+    e.string(electionID)
+    e.string(voterKey)
+    e.string(choice)
+    e.string(salt)
+    hash := sha256.Sum256(e.buf.Bytes())
+    return hex.EncodeToString(hash[:])
+}
+
+// NewBallot picks a random salt and returns it with the commitment to
+// ballot in an election counted by method. The voter must keep the salt to
+// reveal the ballot.
+func NewBallot(electionID, voterKey, method string, ballot *Ballot) (commitment, salt string, err error) {
+    raw := make([]byte, 32)
+    if _, err := rand.Read(raw); err != nil {
+        return "", "", err
+    }
+    salt = hex.EncodeToString(raw)
+    return BallotCommitment(electionID, voterKey, ballot.choice(method), salt), salt, nil
+}
+
+// choice returns the string a secret ballot commits to: the candidate ID
+// for plurality, comma-separated candidate IDs for rankings and approvals,
+// and comma-separated ID=score pairs, sorted by ID, for scores
+func (b *Ballot) choice(method string) string {
+    switch method {
+    case MethodInstantRunoff, MethodSTV:
+        return strings.Join(b.Ranking, ",")
+    case MethodApproval:
+        return strings.Join(b.Approvals, ",")
+    case MethodScore:
+        pairs := make([]string, 0, len(b.Scores))
+        for _, candidateID := range sortedKeys(b.Scores) {
+            pairs = append(pairs, candidateID+"="+strconv.Itoa(b.Scores[candidateID]))
+        }
+        return strings.Join(pairs, ",")
+    }
+    return b.CandidateID
+}
+
+// ParseBallot reads a ballot for an election counted by method from its
+// choice string
+func ParseBallot(method, choice string) (*Ballot, error) {
+    switch method {
+    case MethodInstantRunoff, MethodSTV:
+        return &Ballot{Ranking: strings.Split(choice, ",")}, nil
+    case MethodApproval:
+        return &Ballot{Approvals: strings.Split(choice, ",")}, nil
+    case MethodScore:
+        ballot := &Ballot{Scores: make(map[string]int)}
+        for _, pair := range strings.Split(choice, ",") {
+            candidateID, value, found := strings.Cut(pair, "=")
+            score, err := strconv.Atoi(value)
+            if !found || err != nil {
+                return nil, fmt.Errorf("score %q is not CANDIDATE_ID=SCORE", pair)
+            }
+            ballot.Scores[candidateID] = score
+        }
+        return ballot, nil
+    }
+    return &Ballot{CandidateID: choice}, nil
+}
+
+// validateBallot checks a ballot against the election's voting method
+func (e *Election) validateBallot(b *Ballot) error {
+    filled := 0
+    for _, set := range []bool{b.CandidateID != "", b.Ranking != nil, b.Approvals != nil, b.Scores != nil} {
+        if set {
+            filled++
+        }
+    }
+
+    switch method := e.method(); method {
+    case MethodInstantRunoff, MethodSTV:
+        if filled != 1 || b.Ranking == nil {
+            return fmt.Errorf("%s ballots carry only a ranking", method)
+        }
+        return e.validateCandidateList(b.Ranking, "ranking")
+
+    case MethodApproval:
+        if filled != 1 || b.Approvals == nil {
+            return errors.New("approval ballots carry only approvals")
+        }
+        return e.validateCandidateList(b.Approvals, "approvals")
+
+    case MethodScore:
+        if filled != 1 || b.Scores == nil {
+            return errors.New("score ballots carry only scores")
+        }
+        if len(b.Scores) == 0 {
+            return errors.New("ballot must score at least one candidate")
+        }
+        for candidateID, score := range b.Scores {
+            if !e.hasCandidate(candidateID) {
+                return errors.New("invalid candidate")
+            }
+            if score < 0 || score > e.MaxScore {
+                return fmt.Errorf("scores must be between 0 and %d", e.MaxScore)
+            }
+        }
+        return nil
+    }
+
+    if filled != 1 || b.CandidateID == "" {
+        return errors.New("plurality ballots carry only a candidate")
+    }
+    if !e.hasCandidate(b.CandidateID) {
+        return errors.New("invalid candidate")
+    }
+    return nil
+}
+
+// validateCandidateList checks that a ranking or approval list names
+// registered candidates, each at most once. A ranking may stop after any
+// number of preferences.
+func (e *Election) validateCandidateList(candidateIDs []string, what string) error {
+    if len(candidateIDs) == 0 {
+        return fmt.Errorf("%s must list at least one candidate", what)
+    }
+    seen := make(map[string]bool, len(candidateIDs))
+    for _, candidateID := range candidateIDs {
+        if !e.hasCandidate(candidateID) {
+            return errors.New("invalid candidate")
+        }
+        if seen[candidateID] {
+            return fmt.Errorf("%s lists a candidate twice", what)
+        }
+        seen[candidateID] = true
+    }
+    return nil
+}
+
+// recordBallot stores a validated ballot
+func (e *Election) recordBallot(citizenPublicKey string, b *Ballot) {
+    switch e.method() {
+    case MethodInstantRunoff, MethodSTV:
+        e.Rankings[citizenPublicKey] = b.Ranking
+    case MethodApproval:
+        e.Approvals[citizenPublicKey] = b.Approvals
+    case MethodScore:
+        e.Scores[citizenPublicKey] = b.Scores
+    default:
+        e.Votes[citizenPublicKey] = b.CandidateID
+    }
+}
+
+// validateSalt rejects salts too short to hide the choice
+func validateSalt(salt string) error {
+    raw, err := hex.DecodeString(salt)
+    if err != nil || len(raw) < minSaltSize {
+        return fmt.Errorf("ballot salt must be at least %d hex-encoded bytes", minSaltSize)
+    }
+    return nil
+}
+
+// validateCommitment rejects anything that is not a SHA-256 hash
+func validateCommitment(commitment string) error {
+    raw, err := hex.DecodeString(commitment)
+    if err != nil || len(raw) != sha256.Size {
+        return fmt.Errorf("ballot commitment must be a hex-encoded SHA-256 hash")
+    }
+    return nil
+}
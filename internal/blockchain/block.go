@@ -1,34 +1,140 @@
 package blockchain
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"time"
-	"crypto/sha256"
-	// Removed unused import: encoding/hex
 )
 
+// BlockHeader is the part of a block its hash covers. Transactions are
+// committed to through TxRoot, the Merkle root of the block body, so a header
+// alone is enough to check a transaction's inclusion proof.
+type BlockHeader struct {
+	Version   int    `json:"version"`
+	Index     int64  `json:"index"`
+	Timestamp int64  `json:"timestamp"`
+	PrevHash  []byte `json:"prevHash"`
+	TxRoot    []byte `json:"txRoot,omitempty"`
+	Proposer  string `json:"proposer,omitempty"` // public key of the validator that produced the block
+	Round     int64  `json:"round"`              // proposer round at this height, bumped on every missed slot
+}
+
 type Block struct {
-	Index        int64         `json:"index"`
-	Timestamp    int64         `json:"timestamp"`
-	Transactions []Transaction `json:"transactions"`
-	Hash         []byte        `json:"hash"`
-	PrevHash     []byte        `json:"prevHash"`
+	BlockHeader  `json:"header"`
+	Transactions []Transaction      `json:"transactions"` // the block body
+	Hash         []byte             `json:"hash"`
+	Signature    string             `json:"signature,omitempty"` // proposer's signature over Hash
+	Commit       *CommitCertificate `json:"commit,omitempty"`    // BFT finality proof; not covered by Hash
+}
+
+// CommitSig is one validator's precommit signature for a block
+type CommitSig struct {
+	Validator string `json:"validator"`
+	Signature string `json:"signature"`
+}
+
+// CommitCertificate proves that more than two thirds of the validators
+// precommitted a block in the given round, which makes the block final
+type CommitCertificate struct {
+	Round      int64       `json:"round"`
+	Signatures []CommitSig `json:"signatures"`
 }
 
 func NewBlock(index int64, transactions []Transaction, prevHash []byte) *Block {
+	return newBlockAt(index, time.Now().Unix(), transactions, prevHash)
+}
+
+// newBlockAt creates a block with an explicit timestamp, used when the
+// transactions were already applied against that timestamp
+func newBlockAt(index, timestamp int64, transactions []Transaction, prevHash []byte) *Block {
 	block := &Block{
-		Index:        index,
-		Timestamp:    time.Now().Unix(),
+		BlockHeader: BlockHeader{
+			Version:   BlockVersion,
+			Index:     index,
+			Timestamp: timestamp,
+			PrevHash:  prevHash,
+		},
 		Transactions: transactions,
-		PrevHash:     prevHash,
 	}
-	block.Hash = block.calculateHash()
+	block.seal()
 	return block
 }
 
+// seal sets the transaction root and the hash after the header or body changed
+func (b *Block) seal() {
+	b.TxRoot, _ = computeTxRoot(b.Transactions)
+	b.Hash = b.calculateHash()
+}
+
+// verifyContents checks that the hash matches the header and, for blocks
+// with a transaction root, that the root matches the body
+func (b *Block) verifyContents() error {
+	hash, err := hashBlock(b)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(b.Hash, hash) {
+		return fmt.Errorf("invalid hash")
+	}
+	if b.Version >= 2 {
+		root, err := computeTxRoot(b.Transactions)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(b.TxRoot, root) {
+			return fmt.Errorf("transaction root does not match the transactions")
+		}
+	}
+	return nil
+}
+
+// calculateHash returns the SHA-256 of the block's canonical encoding, or nil
+// if the block cannot be encoded
 func (b *Block) calculateHash() []byte {
-	data := fmt.Sprintf("%d%d%v%s", b.Index, b.Timestamp, b.Transactions, b.PrevHash)
-	hash := sha256.Sum256([]byte(data))
-	return hash[:]
+	hash, _ := hashBlock(b)
+	return hash
+}
+
+func hashBlock(b *Block) ([]byte, error) {
+	if b.Version >= 2 {
+		return hashBlockHeader(&b.BlockHeader)
+	}
+	encoded, err := encodeLegacyBlock(b)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(encoded)
+	return hash[:], nil
 }
 
+// hashBlockHeader returns the hash of a block with a transaction root
+func hashBlockHeader(h *BlockHeader) ([]byte, error) {
+	encoded, err := encodeBlockHeader(h)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(encoded)
+	return hash[:], nil
+}
+
+// HashHex returns the block hash as a hex string
+func (b *Block) HashHex() string {
+	return hex.EncodeToString(b.Hash)
+}
+
+// Sign sets the proposer signature over the block hash
+func (b *Block) Sign(privateKey string) error {
+	signature, err := Sign(privateKey, b.Hash)
+	if err != nil {
+		return err
+	}
+	b.Signature = signature
+	return nil
+}
+
+// VerifySignature checks that the block was signed by its proposer
+func (b *Block) VerifySignature() bool {
+	return VerifySignature(b.Proposer, b.Hash, b.Signature)
+}
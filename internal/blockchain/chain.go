@@ -1,71 +1,410 @@
 package blockchain
 
 import (
+    "bytes"
     "fmt"
+    "log"
     "sync"
+    "time"
 )
 
 // Chain represents the blockchain
 type Chain struct {
-    blocks          []*Block
-    mu              sync.RWMutex
-    txPool          *TransactionPool
-    citizenRegistry *CitizenRegistry
-    electionSystem  *ElectionSystem
+    blocks   []*Block
+    mu       sync.RWMutex
+    txPool   *TransactionPool
+    state    *State // state after the last committed block
+    pending  *State // committed state plus every pooled transaction
+    store    Store
+    genesis  *Genesis
+    txBlocks map[string]int64 // committed transaction ID -> block index
+    verifier BlockVerifier
+    onSubmit func(tx *Transaction)
 }
 
-// NewChain creates a new blockchain
-func NewChain() *Chain {
-    registry := NewCitizenRegistry()
-    chain := &Chain{
-        blocks:          make([]*Block, 0),
-        txPool:          NewTransactionPool(),
-        citizenRegistry: registry,
-        electionSystem:  NewElectionSystem(registry),
+// BlockVerifier checks consensus rules (proposer, signatures, timing) for a
+// block before the chain accepts it
+type BlockVerifier interface {
+    VerifyBlock(prev, block *Block) error
+}
+
+// NewChain creates a new in-memory blockchain
+func NewChain(genesis *Genesis) *Chain {
+    chain, err := OpenChain(NewMemoryStore(), genesis)
+    if err != nil {
+        // The in-memory store cannot fail to load or append
+        panic(err)
     }
-    chain.addGenesisBlock()
     return chain
 }
 
+// OpenChain loads a blockchain from the given store, creating the genesis
+// block if the store is empty. Citizen and election state is rebuilt by
+// replaying the transactions of every stored block.
+func OpenChain(store Store, genesis *Genesis) (*Chain, error) {
+    chain := &Chain{
+        blocks:   make([]*Block, 0),
+        txPool:   NewTransactionPool(),
+        state:    NewState(genesis),
+        store:    store,
+        genesis:  genesis,
+        txBlocks: make(map[string]int64),
+    }
+
+    blocks, err := store.LoadBlocks()
+    if err != nil {
+        return nil, fmt.Errorf("failed to load blocks: %v", err)
+    }
+
+    if len(blocks) == 0 {
+        if err := chain.addGenesisBlock(); err != nil {
+            return nil, err
+        }
+        chain.pending = chain.state.Clone()
+        return chain, nil
+    }
+
+    if !bytes.Equal(blocks[0].Hash, genesis.Block().Hash) {
+        return nil, fmt.Errorf("stored chain was created from a different genesis configuration")
+    }
+
+    for i, block := range blocks {
+        // The checksum only catches damage to the record; a block stored
+        // with the wrong hash must not be replayed under it
+        if err := block.verifyContents(); err != nil {
+            return nil, fmt.Errorf("stored block %d is corrupt: %v", block.Index, err)
+        }
+        if i > 0 {
+            prevBlock := blocks[i-1]
+            if block.Index != prevBlock.Index+1 || !bytes.Equal(block.PrevHash, prevBlock.Hash) {
+                return nil, fmt.Errorf("stored block %d does not link to block %d", block.Index, prevBlock.Index)
+            }
+        }
+        if err := chain.state.ApplyBlock(block); err != nil {
+            return nil, fmt.Errorf("failed to replay block %d: %v", block.Index, err)
+        }
+        chain.appendCommitted(block)
+    }
+    chain.pending = chain.state.Clone()
+    return chain, nil
+}
+
 // addGenesisBlock creates and adds the genesis block
-func (c *Chain) addGenesisBlock() {
-    genesisBlock := NewBlock(0, []Transaction{}, []byte("0"))
-    c.blocks = append(c.blocks, genesisBlock)
+func (c *Chain) addGenesisBlock() error {
+    genesisBlock := c.genesis.Block()
+    if err := c.store.AppendBlock(genesisBlock); err != nil {
+        return fmt.Errorf("failed to store genesis block: %v", err)
+    }
+    c.appendCommitted(genesisBlock)
+    return nil
 }
 
-// AddBlock creates and adds a new block
-func (c *Chain) AddBlock() error {
+// Close releases the chain's underlying store
+func (c *Chain) Close() error {
     c.mu.Lock()
     defer c.mu.Unlock()
+    return c.store.Close()
+}
 
-    if len(c.blocks) == 0 {
-        return fmt.Errorf("blockchain not initialized")
-    }
+// Genesis returns the genesis configuration of the chain
+func (c *Chain) Genesis() *Genesis {
+    return c.genesis
+}
+
+// SetBlockVerifier installs the consensus rules applied by AppendBlock
+func (c *Chain) SetBlockVerifier(verifier BlockVerifier) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.verifier = verifier
+}
+
+// SetTransactionListener registers a function called with every transaction
+// accepted into the pool, for example to gossip it to peers
+func (c *Chain) SetTransactionListener(listener func(tx *Transaction)) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.onSubmit = listener
+}
+
+// ProposeBlock builds the next block from the pooled transactions without
+// committing it. Transactions that no longer apply on top of the committed
+// state are dropped from the pool instead of being included.
+func (c *Chain) ProposeBlock(proposer string, round, timestamp int64) (*Block, error) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
 
     prevBlock := c.blocks[len(c.blocks)-1]
+    ctx := BlockContext{Height: prevBlock.Index + 1, Timestamp: timestamp}
+    next := c.state.Clone()
+    next.beginBlock(ctx)
     transactions := make([]Transaction, 0)
     for _, tx := range c.txPool.GetAllTransactions() {
+        if err := next.ApplyTransaction(tx, ctx); err != nil {
+            log.Printf("Dropping transaction %s from pool: %v", tx.ID, err)
+            c.txPool.RemoveTransaction(tx.ID)
+            continue
+        }
         transactions = append(transactions, *tx)
     }
 
-    newBlock := NewBlock(
-        prevBlock.Index+1,
-        transactions,
-        prevBlock.Hash,
-    )
+    block := &Block{
+        BlockHeader: BlockHeader{
+            Version:   BlockVersion,
+            Index:     ctx.Height,
+            Timestamp: ctx.Timestamp,
+            PrevHash:  prevBlock.Hash,
+            Proposer:  proposer,
+            Round:     round,
+        },
+        Transactions: transactions,
+    }
+    block.seal()
+    return block, nil
+}
+
+// AppendBlock validates block against the current head, the consensus rules
+// and the state machine, then commits it
+func (c *Chain) AppendBlock(block *Block) error {
+    c.mu.Lock()
+    defer c.mu.Unlock()
 
-    c.blocks = append(c.blocks, newBlock)
-    c.txPool.Clear() // Clear the transaction pool
+    prevBlock := c.blocks[len(c.blocks)-1]
+    next, err := checkBlock(c.state, prevBlock, block)
+    if err != nil {
+        return err
+    }
+    if c.verifier != nil {
+        if err := c.verifier.VerifyBlock(prevBlock, block); err != nil {
+            return fmt.Errorf("block %d rejected: %v", block.Index, err)
+        }
+    }
+    return c.commitBlock(block, next)
+}
+
+// ValidateBlock checks that block extends the current head and that all of
+// its transactions apply, without consensus rules and without committing it
+func (c *Chain) ValidateBlock(block *Block) error {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+
+    _, err := checkBlock(c.state, c.blocks[len(c.blocks)-1], block)
+    return err
+}
+
+// checkBlock validates block on top of prevBlock, whose resulting state is
+// state, and returns the state after block. Callers must hold c.mu.
+func checkBlock(state *State, prevBlock, block *Block) (*State, error) {
+    if block.Index != prevBlock.Index+1 {
+        return nil, fmt.Errorf("block height %d does not follow head %d", block.Index, prevBlock.Index)
+    }
+    if !bytes.Equal(block.PrevHash, prevBlock.Hash) {
+        return nil, fmt.Errorf("block %d does not link to the current head", block.Index)
+    }
+    if err := block.verifyContents(); err != nil {
+        return nil, fmt.Errorf("block %d: %v", block.Index, err)
+    }
+    if block.Timestamp < prevBlock.Timestamp {
+        return nil, fmt.Errorf("block %d is older than its parent", block.Index)
+    }
+
+    next := state.Clone()
+    if err := next.ApplyBlock(block); err != nil {
+        return nil, fmt.Errorf("block %d rejected: %v", block.Index, err)
+    }
+    return next, nil
+}
+
+// Reorganize switches the chain to branch, a contiguous run of blocks that
+// extends one of the chain's blocks, the common ancestor. Leading blocks the
+// chain already holds are skipped. The branch must end above the current
+// head, and every block is checked against the state machine and the
+// consensus rules before the blocks above the ancestor are replaced.
+// Transactions of the replaced blocks that the branch leaves out return to
+// the pool.
+func (c *Chain) Reorganize(branch []*Block) error {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    for len(branch) > 0 {
+        index := branch[0].Index
+        if index < 0 || index >= int64(len(c.blocks)) || !bytes.Equal(c.blocks[index].Hash, branch[0].Hash) {
+            break
+        }
+        branch = branch[1:]
+    }
+    if len(branch) == 0 {
+        return nil
+    }
+
+    first, last := branch[0], branch[len(branch)-1]
+    head := c.blocks[len(c.blocks)-1]
+    if first.Index < 1 || first.Index > head.Index+1 {
+        return fmt.Errorf("branch starting at %d does not extend the chain", first.Index)
+    }
+    if last.Index <= head.Index {
+        return fmt.Errorf("branch ending at %d is not longer than the chain at %d", last.Index, head.Index)
+    }
+
+    ancestor := c.blocks[first.Index-1]
+    state := c.state
+    if ancestor != head {
+        var err error
+        if state, err = c.stateAt(ancestor.Index); err != nil {
+            return err
+        }
+    }
+    prevBlock := ancestor
+    states := make([]*State, len(branch))
+    for i, block := range branch {
+        next, err := checkBlock(state, prevBlock, block)
+        if err != nil {
+            return err
+        }
+        if c.verifier != nil {
+            if err := c.verifier.VerifyBlock(prevBlock, block); err != nil {
+                return fmt.Errorf("block %d rejected: %v", block.Index, err)
+            }
+        }
+        states[i] = next
+        state, prevBlock = next, block
+    }
+
+    if ancestor != head {
+        if err := c.store.TruncateBlocks(ancestor.Index); err != nil {
+            return fmt.Errorf("failed to truncate stored chain: %v", err)
+        }
+        replaced := c.blocks[ancestor.Index+1:]
+        c.blocks = append([]*Block(nil), c.blocks[:ancestor.Index+1]...)
+        for _, block := range replaced {
+            for i := range block.Transactions {
+                tx := &block.Transactions[i]
+                delete(c.txBlocks, tx.ID)
+                c.txPool.AddTransaction(tx)
+            }
+        }
+        log.Printf("Replacing %d blocks above %d with a branch of %d", len(replaced), ancestor.Index, len(branch))
+    }
+    for i, block := range branch {
+        if err := c.commitBlock(block, states[i]); err != nil {
+            return err
+        }
+    }
     return nil
 }
 
-// AddTransaction adds a transaction to the pool
-func (c *Chain) AddTransaction(from, to string, amount float64) (*Transaction, error) {
-    tx := NewTransaction(from, to, amount)
+// stateAt rebuilds the state after the block at height by replaying the
+// chain from genesis. Callers must hold c.mu.
+func (c *Chain) stateAt(height int64) (*State, error) {
+    state := NewState(c.genesis)
+    for _, block := range c.blocks[:height+1] {
+        if err := state.ApplyBlock(block); err != nil {
+            return nil, fmt.Errorf("failed to replay block %d: %v", block.Index, err)
+        }
+    }
+    return state, nil
+}
+
+// commitBlock persists block and makes next, the state after applying it,
+// the committed state. Callers must hold c.mu.
+func (c *Chain) commitBlock(block *Block, next *State) error {
+    if err := c.store.AppendBlock(block); err != nil {
+        return fmt.Errorf("failed to store block: %v", err)
+    }
+    c.appendCommitted(block)
+    c.state = next
+
+    for i := range block.Transactions {
+        c.txPool.RemoveTransaction(block.Transactions[i].ID)
+    }
+    c.rebuildPending()
+    return nil
+}
+
+// appendCommitted adds block to the in-memory chain and indexes its
+// transactions. Callers must hold c.mu.
+func (c *Chain) appendCommitted(block *Block) {
+    c.blocks = append(c.blocks, block)
+    for i := range block.Transactions {
+        c.txBlocks[block.Transactions[i].ID] = block.Index
+    }
+}
+
+// rebuildPending re-applies the remaining pooled transactions on top of the
+// committed state, evicting any that have become invalid. Callers must hold c.mu.
+func (c *Chain) rebuildPending() {
+    c.pending = c.state.Clone()
+    ctx := c.nextBlockContext()
+    c.pending.beginBlock(ctx)
+    for _, tx := range c.txPool.GetAllTransactions() {
+        if err := c.pending.ApplyTransaction(tx, ctx); err != nil {
+            log.Printf("Evicting transaction %s from pool: %v", tx.ID, err)
+            c.txPool.RemoveTransaction(tx.ID)
+        }
+    }
+}
+
+// nextBlockContext approximates the context of the next block for checking
+// transactions before they are included. Callers must hold c.mu.
+func (c *Chain) nextBlockContext() BlockContext {
+    return BlockContext{
+        Height:    c.blocks[len(c.blocks)-1].Index + 1,
+        Timestamp: time.Now().Unix(),
+    }
+}
+
+// SubmitTransaction checks a transaction created elsewhere against the
+// pending state and adds it to the pool
+func (c *Chain) SubmitTransaction(tx *Transaction) error {
+    return c.submitTransaction(tx)
+}
+
+// submitTransaction checks tx against the pending state and adds it to the
+// pool. State itself only changes once the transaction is in a committed block.
+func (c *Chain) submitTransaction(tx *Transaction) error {
+    c.mu.Lock()
+    if err := c.poolTransaction(tx); err != nil {
+        c.mu.Unlock()
+        return err
+    }
+    listener := c.onSubmit
+    c.mu.Unlock()
+
+    if listener != nil {
+        listener(tx)
+    }
+    return nil
+}
+
+// poolTransaction validates tx and adds it to the pool. Callers must hold c.mu.
+func (c *Chain) poolTransaction(tx *Transaction) error {
+    if _, exists := c.txPool.GetTransaction(tx.ID); exists {
+        return fmt.Errorf("transaction already pending")
+    }
+    if _, committed := c.txBlocks[tx.ID]; committed {
+        return fmt.Errorf("transaction already committed")
+    }
+    // Phases that have ended since the pending state was built must be
+    // closed before checking tx, or a late vote would be accepted
+    ctx := c.nextBlockContext()
+    c.pending.beginBlock(ctx)
+    if err := c.pending.ApplyTransaction(tx, ctx); err != nil {
+        return err
+    }
     if !c.txPool.AddTransaction(tx) {
-        return nil, fmt.Errorf("failed to add transaction to pool")
+        return fmt.Errorf("failed to add transaction to pool")
     }
-    return tx, nil
+    return nil
+}
+
+// TransitionDue reports whether a block proposed at timestamp would move an
+// election to its next phase. Proposers produce such a block even with no
+// pending transactions, so deadlines take effect without waiting for one.
+func (c *Chain) TransitionDue(timestamp int64) bool {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+
+    ctx := BlockContext{Height: c.blocks[len(c.blocks)-1].Index + 1, Timestamp: timestamp}
+    return c.state.electionSystem.due(ctx)
 }
 
 // GetLatestBlock returns the most recent block
@@ -79,6 +418,31 @@ func (c *Chain) GetLatestBlock() (*Block, error) {
     return c.blocks[len(c.blocks)-1], nil
 }
 
+// Height returns the index of the latest block
+func (c *Chain) Height() int64 {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    return c.blocks[len(c.blocks)-1].Index
+}
+
+// PendingCount returns the number of transactions waiting for a block
+func (c *Chain) PendingCount() int {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    return c.txPool.Size()
+}
+
+// GetBlock returns the block at index
+func (c *Chain) GetBlock(index int64) (*Block, bool) {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+
+    if index < 0 || index >= int64(len(c.blocks)) {
+        return nil, false
+    }
+    return c.blocks[index], true
+}
+
 // GetBlocks returns all blocks
 func (c *Chain) GetBlocks() []*Block {
     c.mu.RLock()
@@ -99,148 +463,113 @@ func (c *Chain) ValidateChain() bool {
             return false
         }
 
-        if string(currentBlock.Hash) != string(currentBlock.calculateHash()) {
+        if currentBlock.verifyContents() != nil {
             return false
         }
     }
     return true
 }
 
-// GetTransactionPool returns the current transaction pool
-func (c *Chain) GetTransactionPool() *TransactionPool {
-    return c.txPool
+// PendingTransactions returns the pooled transactions in arrival order
+func (c *Chain) PendingTransactions() []*Transaction {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    return c.txPool.GetAllTransactions()
 }
 
-// AddCitizenRegistration adds a new citizen registration transaction
-func (c *Chain) AddCitizenRegistration(name, dateOfBirth, publicKey string) (*Transaction, error) {
-    citizen, err := c.citizenRegistry.RegisterCitizen(name, dateOfBirth, publicKey)
-    if err != nil {
-        return nil, err
-    }
+// ProveTransaction returns a committed transaction together with the proof
+// of its inclusion in its block. It returns false if no committed block
+// contains the transaction.
+func (c *Chain) ProveTransaction(txID string) (*Transaction, *MerkleProof, bool, error) {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
 
-    tx := NewTransaction("SYSTEM", publicKey, 0)
-    tx.Data = map[string]interface{}{
-        "type":     "CITIZEN_REGISTRATION",
-        "citizen":  citizen,
+    index, ok := c.txBlocks[txID]
+    if !ok {
+        return nil, nil, false, nil
     }
-    
-    if !c.txPool.AddTransaction(tx) {
-        return nil, fmt.Errorf("failed to add citizen registration transaction")
+    block := c.blocks[index]
+    proof, ok, err := block.ProveTransaction(txID)
+    if err != nil || !ok {
+        return nil, nil, ok, err
     }
-    return tx, nil
+    return &block.Transactions[proof.TxIndex], proof, true, nil
 }
 
-// ApproveCitizen approves a citizen registration
-func (c *Chain) ApproveCitizen(citizenID, approverKey string) (*Transaction, error) {
-    if err := c.citizenRegistry.ApproveCitizen(citizenID, approverKey); err != nil {
-        return nil, err
-    }
-
-    tx := NewTransaction("SYSTEM", citizenID, 0)
-    tx.Data = map[string]interface{}{
-        "type":        "CITIZEN_APPROVAL",
-        "citizenID":   citizenID,
-        "approverKey": approverKey,
-    }
-    
-    if !c.txPool.AddTransaction(tx) {
-        return nil, fmt.Errorf("failed to add citizen approval transaction")
-    }
-    return tx, nil
+// GetTransactionPool returns the current transaction pool
+func (c *Chain) GetTransactionPool() *TransactionPool {
+    return c.txPool
 }
 
-// StartElection starts a new presidential election
-func (c *Chain) StartElection(name string, durationDays int) (*Transaction, error) {
-    if err := c.electionSystem.StartElection(name, durationDays); err != nil {
-        return nil, err
-    }
+// committedState returns the state as of the latest block
+func (c *Chain) committedState() *State {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    return c.state
+}
 
-    tx := NewTransaction("SYSTEM", "ELECTION", 0)
-    tx.Data = map[string]interface{}{
-        "type":         "ELECTION_START",
-        "name":         name,
-        "durationDays": durationDays,
-    }
-    
-    if !c.txPool.AddTransaction(tx) {
-        return nil, fmt.Errorf("failed to add election start transaction")
-    }
-    return tx, nil
+// GetCitizen returns a citizen by their public key
+func (c *Chain) GetCitizen(publicKey string) (*Citizen, bool) {
+    return c.committedState().citizenRegistry.GetCitizen(publicKey)
 }
 
-// RegisterCandidate registers a new presidential candidate
-func (c *Chain) RegisterCandidate(name, publicKey, platform string) (*Transaction, error) {
-    if err := c.electionSystem.RegisterCandidate(name, publicKey, platform); err != nil {
-        return nil, err
-    }
+// GetAllCitizens returns all registered citizens
+func (c *Chain) GetAllCitizens() []*Citizen {
+    return c.committedState().citizenRegistry.GetAllCitizens()
+}
 
-    tx := NewTransaction("SYSTEM", publicKey, 0)
-    tx.Data = map[string]interface{}{
-        "type":     "CANDIDATE_REGISTRATION",
-        "name":     name,
-        "platform": platform,
-    }
-    
-    if !c.txPool.AddTransaction(tx) {
-        return nil, fmt.Errorf("failed to add candidate registration transaction")
-    }
-    return tx, nil
+// GetCitizenByID returns a citizen, with their history, by citizen ID
+func (c *Chain) GetCitizenByID(citizenID string) (*Citizen, bool) {
+    return c.committedState().citizenRegistry.GetCitizenByID(citizenID)
 }
 
-// CastVote records a citizen's vote
-func (c *Chain) CastVote(citizenPublicKey, candidateID string) (*Transaction, error) {
-    if err := c.electionSystem.CastVote(citizenPublicKey, candidateID); err != nil {
-        return nil, err
-    }
+// GetOpenAppeals returns the citizens whose appeal awaits the admins
+func (c *Chain) GetOpenAppeals() []*Citizen {
+    return c.committedState().citizenRegistry.GetOpenAppeals()
+}
 
-    tx := NewTransaction(citizenPublicKey, "ELECTION", 0)
-    tx.Data = map[string]interface{}{
-        "type":        "VOTE_CAST",
-        "candidateID": candidateID,
-    }
-    
-    if !c.txPool.AddTransaction(tx) {
-        return nil, fmt.Errorf("failed to add vote transaction")
-    }
-    return tx, nil
+// GetDuplicateClusters returns the groups of citizens suspected of being
+// the same person
+func (c *Chain) GetDuplicateClusters() [][]*Citizen {
+    return c.committedState().citizenRegistry.GetDuplicateClusters()
 }
 
-// GetCitizen returns a citizen by their public key
-func (c *Chain) GetCitizen(publicKey string) (*Citizen, bool) {
-    return c.citizenRegistry.GetCitizen(publicKey)
+// GetAdmins returns the public keys of the current admins
+func (c *Chain) GetAdmins() []string {
+    return c.committedState().citizenRegistry.GetAdmins()
 }
 
-// GetAllCitizens returns all registered citizens
-func (c *Chain) GetAllCitizens() []*Citizen {
-    return c.citizenRegistry.GetAllCitizens()
+// GetAdminProposals returns the admin changes awaiting approval
+func (c *Chain) GetAdminProposals() []*AdminProposal {
+    return c.committedState().citizenRegistry.GetAdminProposals()
 }
 
-// GetCurrentElection returns the current active election
+// GetCurrentElection returns the most recently started election that has
+// not finished, or nil
 func (c *Chain) GetCurrentElection() *Election {
-    return c.electionSystem.GetCurrentElection()
+    return c.committedState().electionSystem.GetCurrentElection()
+}
+
+// GetElection returns any election, running or finished, by its ID
+func (c *Chain) GetElection(id string) (*Election, bool) {
+    return c.committedState().electionSystem.GetElection(id)
+}
+
+// GetElections returns the elections with any of the given statuses, or
+// all elections if none are given, in the order they started
+func (c *Chain) GetElections(statuses ...ElectionStatus) []*Election {
+    return c.committedState().electionSystem.GetElections(statuses...)
+}
+
+// GetDelegations returns every delegation in force
+func (c *Chain) GetDelegations() []Delegation {
+    return c.committedState().electionSystem.GetDelegations()
 }
 
 // GetCurrentElectionCandidates returns all candidates in the current election
 func (c *Chain) GetCurrentElectionCandidates() []Candidate {
-    if election := c.electionSystem.GetCurrentElection(); election != nil {
+    if election := c.GetCurrentElection(); election != nil {
         return election.Candidates
     }
     return []Candidate{}
 }
-
-// EndElection ends the current election and determines the winner
-func (c *Chain) EndElection() (*Transaction, error) {
-    if err := c.electionSystem.EndElection(); err != nil {
-        return nil, err
-    }
-
-    tx := NewTransaction("SYSTEM", "ELECTION", 0)
-    tx.Data = map[string]interface{}{
-        "type": "ELECTION_END",
-    }
-    
-    if !c.txPool.AddTransaction(tx) {
-        return nil, fmt.Errorf("failed to add election end transaction")
-    }
-    return tx, nil
-}
\ No newline at end of file
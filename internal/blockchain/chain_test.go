@@ -0,0 +1,117 @@
+package blockchain
+
+import (
+    "bytes"
+    "testing"
+)
+
+// growChain appends n blocks to chain, each registering a new citizen, and
+// returns the registrations
+func growChain(t *testing.T, chain *Chain, n int) []*Transaction {
+    t.Helper()
+    var txs []*Transaction
+    for i := 0; i < n; i++ {
+        head, _ := chain.GetLatestBlock()
+        key := newTestKey(t)
+        tx := signedBy(t, key)(NewCitizenRegistrationTx("Citizen", "1990-01-01", "", key.Public, head.Timestamp))
+        if err := chain.SubmitTransaction(tx); err != nil {
+            t.Fatal(err)
+        }
+        block, err := chain.ProposeBlock("proposer", 0, head.Timestamp+1)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if err := chain.AppendBlock(block); err != nil {
+            t.Fatal(err)
+        }
+        txs = append(txs, tx)
+    }
+    return txs
+}
+
+// forkOf returns a chain holding the first shared blocks of chain
+func forkOf(t *testing.T, chain *Chain, shared int64) *Chain {
+    t.Helper()
+    fork := NewChain(chain.Genesis())
+    for height := int64(1); height <= shared; height++ {
+        block, _ := chain.GetBlock(height)
+        if err := fork.AppendBlock(block); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return fork
+}
+
+func TestReorganize(t *testing.T) {
+    tests := []struct {
+        name    string
+        local   int   // blocks above the ancestor on the local chain
+        remote  int   // blocks above the ancestor on the branch
+        from    int64 // first branch block handed to Reorganize
+        corrupt bool  // whether the last branch block is tampered with
+        ok      bool
+    }{
+        {"longer branch", 2, 3, 3, false, true},
+        {"longer branch with known blocks", 2, 3, 1, false, true},
+        {"extends the head", 0, 2, 3, false, true},
+        {"equal branch", 2, 2, 3, false, false},
+        {"shorter branch", 2, 1, 3, false, false},
+        {"invalid block", 2, 3, 3, true, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            const ancestor = 2
+            chain := NewChain(&Genesis{Timestamp: testStartTime})
+            growChain(t, chain, ancestor)
+            remote := forkOf(t, chain, ancestor)
+            replaced := growChain(t, chain, tt.local)
+            growChain(t, remote, tt.remote)
+            before, _ := chain.GetLatestBlock()
+
+            var branch []*Block
+            for height := tt.from; height <= remote.Height(); height++ {
+                block, _ := remote.GetBlock(height)
+                branch = append(branch, block)
+            }
+            if tt.corrupt {
+                last := *branch[len(branch)-1]
+                last.Transactions = nil
+                branch[len(branch)-1] = &last
+            }
+
+            err := chain.Reorganize(branch)
+            head, _ := chain.GetLatestBlock()
+            if !tt.ok {
+                if err == nil {
+                    t.Fatal("branch was accepted")
+                }
+                if head != before || chain.Height() != before.Index {
+                    t.Fatal("rejected branch changed the chain")
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+            remoteHead, _ := remote.GetLatestBlock()
+            if !bytes.Equal(head.Hash, remoteHead.Hash) {
+                t.Fatalf("head is %d, want the branch head %d", head.Index, remoteHead.Index)
+            }
+            if !chain.ValidateChain() {
+                t.Fatal("chain does not validate after the switch")
+            }
+            for _, tx := range replaced {
+                if _, pending := chain.GetTransactionPool().GetTransaction(tx.ID); !pending {
+                    t.Errorf("transaction %s of a replaced block is not pending", tx.ID)
+                }
+            }
+
+            // The state is the branch's: replaced registrations are gone
+            for _, tx := range replaced {
+                if _, exists := chain.GetCitizen(tx.From); exists {
+                    t.Errorf("citizen from a replaced block is still registered")
+                }
+            }
+        })
+    }
+}
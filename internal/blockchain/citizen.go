@@ -4,8 +4,9 @@ import (
     "crypto/sha256"
     "encoding/hex"
     "errors"
+    "maps"
+    "slices"
     "sync"
-    "time"
 )
 
 type CitizenStatus int
@@ -14,18 +15,31 @@ const (
     Pending CitizenStatus = iota
     Approved
     Rejected
+    Suspended // approved, but without voting or candidacy rights until reinstated
+    Revoked
 )
 
 // Citizen represents a citizen of the virtual nation
 type Citizen struct {
-    ID            string         `json:"id"`
-    PublicKey     string         `json:"publicKey"`
-    Name          string         `json:"name"`
-    DateOfBirth   string         `json:"dateOfBirth"`
-    RegisterDate  int64          `json:"registerDate"`
-    Status        CitizenStatus  `json:"status"`
-    ApprovedBy    string         `json:"approvedBy,omitempty"`
-    ApprovalDate  int64          `json:"approvalDate,omitempty"`
+    ID             string         `json:"id"`
+    PublicKey      string         `json:"publicKey"`
+    Name           string         `json:"name"`
+    DateOfBirth    string         `json:"dateOfBirth"`
+    RegisterDate   int64          `json:"registerDate"`
+    Status         CitizenStatus  `json:"status"`
+    ApprovedBy     string         `json:"approvedBy,omitempty"`
+    ApprovalDate   int64          `json:"approvalDate,omitempty"`
+    ApprovalHeight int64          `json:"approvalHeight,omitempty"`
+    Approvals      []string       `json:"approvals,omitempty"`    // admins who approved a pending application so far
+    Sponsors       []string       `json:"sponsors,omitempty"`     // IDs of the citizens who vouched for the application
+    Sponsored      []Sponsorship  `json:"sponsored,omitempty"`    // the applicants this citizen vouched for
+    BarredUntil    int64          `json:"barredUntil,omitempty"`  // may not vouch for anyone before this date
+    PreviousKeys   []string       `json:"previousKeys,omitempty"` // keys retired by rotation or recovery, oldest first
+    DuplicateOf    []string       `json:"duplicateOf,omitempty"`  // IDs of the citizens this application may duplicate
+    IdentityKeys   []string       `json:"-"`
+    Guardians      *Guardians     `json:"guardians,omitempty"`
+    History        []StatusChange `json:"history"`
+    Appeal         *Appeal        `json:"appeal,omitempty"` // open appeal, if any
 }
 
 // CitizenRegistry manages citizen registration
@@ -33,44 +47,78 @@ type CitizenRegistry struct {
     citizens map[string]*Citizen  // PublicKey -> Citizen
     admins   map[string]bool     // PublicKey -> isAdmin
     mu       sync.RWMutex
+
+    // identities indexes the citizens by identity key, so duplicates are
+    // found without comparing every pair
+    identities map[string][]string // identity key -> citizen IDs, in order of registration
+
+    // ids and retiredKeys find citizens by ID, and tell the keys citizens
+    // moved away from, without visiting every citizen
+    ids         map[string]string // citizen ID -> current public key
+    retiredKeys map[string]string // retired public key -> citizen ID
+
+    // Changes to the admin set wait among the proposals until enough
+    // admins approve them
+    proposals map[string]*AdminProposal // proposal ID -> proposal
+    policy    RegistryPolicy
 }
 
-// NewCitizenRegistry creates a new citizen registry
-func NewCitizenRegistry() *CitizenRegistry {
+// NewCitizenRegistry creates a new citizen registry administered by the
+// given public keys under policy
+func NewCitizenRegistry(admins []string, policy RegistryPolicy) *CitizenRegistry {
     registry := &CitizenRegistry{
-        citizens: make(map[string]*Citizen),
-        admins:   make(map[string]bool),
+        citizens:    make(map[string]*Citizen),
+        admins:      make(map[string]bool),
+        identities:  make(map[string][]string),
+        ids:         make(map[string]string),
+        retiredKeys: make(map[string]string),
+        proposals:   make(map[string]*AdminProposal),
+        policy:      policy.withDefaults(),
+    }
+    for _, admin := range admins {
+        registry.admins[admin] = true
     }
-    // Add initial admin
-    registry.admins["GENESIS_ADMIN"] = true
     return registry
 }
 
-// RegisterCitizen creates a new citizen registration request
-func (cr *CitizenRegistry) RegisterCitizen(name, dateOfBirth, publicKey string) (*Citizen, error) {
+// RegisterCitizen creates a new citizen registration request in the block
+// described by ctx. documentHash is the DocumentHash of the applicant's
+// identity document, if they declared one. An application that shares an
+// identity key with an earlier citizen is flagged for the admins.
+func (cr *CitizenRegistry) RegisterCitizen(name, dateOfBirth, documentHash, publicKey string, ctx BlockContext) (*Citizen, error) {
     cr.mu.Lock()
     defer cr.mu.Unlock()
 
-    if _, exists := cr.citizens[publicKey]; exists {
+    if cr.keyUsed(publicKey) {
         return nil, errors.New("citizen already registered")
     }
 
     id := generateCitizenID(name, publicKey)
+    identityKeys := cr.policy.IdentityCheck.IdentityKeys(name, dateOfBirth, documentHash)
     citizen := &Citizen{
         ID:           id,
         PublicKey:    publicKey,
         Name:         name,
         DateOfBirth:  dateOfBirth,
-        RegisterDate: time.Now().Unix(),
+        RegisterDate: ctx.Timestamp,
         Status:       Pending,
+        DuplicateOf:  cr.duplicatesOf(identityKeys),
+        IdentityKeys: identityKeys,
     }
+    citizen.record(ActionRegistered, publicKey, "", ctx)
 
     cr.citizens[publicKey] = citizen
+    cr.ids[id] = publicKey
+    for _, key := range identityKeys {
+        cr.identities[key] = append(cr.identities[key], id)
+    }
     return citizen, nil
 }
 
-// ApproveCitizen approves a citizen registration
-func (cr *CitizenRegistry) ApproveCitizen(citizenID, approverKey string) error {
+// ApproveCitizen records an admin's approval of a citizen registration in
+// the block described by ctx. The citizen is approved once as many admins
+// as the registry requires have approved them.
+func (cr *CitizenRegistry) ApproveCitizen(citizenID, approverKey string, ctx BlockContext) error {
     cr.mu.Lock()
     defer cr.mu.Unlock()
 
@@ -78,14 +126,7 @@ func (cr *CitizenRegistry) ApproveCitizen(citizenID, approverKey string) error {
         return errors.New("not authorized to approve citizens")
     }
 
-    var targetCitizen *Citizen
-    for _, citizen := range cr.citizens {
-        if citizen.ID == citizenID {
-            targetCitizen = citizen
-            break
-        }
-    }
-
+    targetCitizen := cr.byID(citizenID)
     if targetCitizen == nil {
         return errors.New("citizen not found")
     }
@@ -93,13 +134,26 @@ func (cr *CitizenRegistry) ApproveCitizen(citizenID, approverKey string) error {
     if targetCitizen.Status != Pending {
         return errors.New("citizen already processed")
     }
+    if slices.Contains(targetCitizen.Approvals, approverKey) {
+        return errors.New("admin has already approved this citizen")
+    }
 
-    targetCitizen.Status = Approved
-    targetCitizen.ApprovedBy = approverKey
-    targetCitizen.ApprovalDate = time.Now().Unix()
+    targetCitizen.Approvals = append(targetCitizen.Approvals, approverKey)
+    if cr.countAdmins(targetCitizen.Approvals) >= cr.citizenQuorum() {
+        targetCitizen.approve(approverKey, "", ctx)
+    }
     return nil
 }
 
+// approve makes a citizen approved on the decision of by
+func (c *Citizen) approve(by, reason string, ctx BlockContext) {
+    c.Status = Approved
+    c.ApprovedBy = by
+    c.ApprovalDate = ctx.Timestamp
+    c.ApprovalHeight = ctx.Height
+    c.record(ActionApproved, by, reason, ctx)
+}
+
 // GetCitizen returns a citizen by their public key
 func (cr *CitizenRegistry) GetCitizen(publicKey string) (*Citizen, bool) {
     cr.mu.RLock()
@@ -109,6 +163,24 @@ func (cr *CitizenRegistry) GetCitizen(publicKey string) (*Citizen, bool) {
     return citizen, exists
 }
 
+// GetCitizenByID returns a citizen by their citizen ID
+func (cr *CitizenRegistry) GetCitizenByID(citizenID string) (*Citizen, bool) {
+    cr.mu.RLock()
+    defer cr.mu.RUnlock()
+
+    citizen := cr.byID(citizenID)
+    return citizen, citizen != nil
+}
+
+// byID returns the citizen with the given citizen ID, or nil
+func (cr *CitizenRegistry) byID(citizenID string) *Citizen {
+    publicKey, exists := cr.ids[citizenID]
+    if !exists {
+        return nil
+    }
+    return cr.citizens[publicKey]
+}
+
 // GetAllCitizens returns all registered citizens
 func (cr *CitizenRegistry) GetAllCitizens() []*Citizen {
     cr.mu.RLock()
@@ -121,6 +193,13 @@ func (cr *CitizenRegistry) GetAllCitizens() []*Citizen {
     return citizens
 }
 
+// IsAdmin checks if a public key belongs to a registry admin
+func (cr *CitizenRegistry) IsAdmin(publicKey string) bool {
+    cr.mu.RLock()
+    defer cr.mu.RUnlock()
+    return cr.admins[publicKey]
+}
+
 // IsCitizen checks if a public key belongs to an approved citizen
 func (cr *CitizenRegistry) IsCitizen(publicKey string) bool {
     cr.mu.RLock()
@@ -130,6 +209,57 @@ func (cr *CitizenRegistry) IsCitizen(publicKey string) bool {
     return exists && citizen.Status == Approved
 }
 
+// clone returns a deep copy of the registry
+func (cr *CitizenRegistry) clone() *CitizenRegistry {
+    cr.mu.RLock()
+    defer cr.mu.RUnlock()
+
+    registry := &CitizenRegistry{
+        citizens:    make(map[string]*Citizen, len(cr.citizens)),
+        admins:      make(map[string]bool, len(cr.admins)),
+        identities:  make(map[string][]string, len(cr.identities)),
+        ids:         maps.Clone(cr.ids),
+        retiredKeys: maps.Clone(cr.retiredKeys),
+        proposals:   make(map[string]*AdminProposal, len(cr.proposals)),
+        policy:      cr.policy,
+    }
+    for key, citizen := range cr.citizens {
+        copied := *citizen
+        copied.History = append([]StatusChange(nil), citizen.History...)
+        copied.Approvals = append([]string(nil), citizen.Approvals...)
+        copied.Sponsors = append([]string(nil), citizen.Sponsors...)
+        copied.Sponsored = append([]Sponsorship(nil), citizen.Sponsored...)
+        copied.PreviousKeys = append([]string(nil), citizen.PreviousKeys...)
+        if citizen.Guardians != nil {
+            guardians := *citizen.Guardians
+            guardians.Recoveries = make(map[string][]string, len(guardians.Recoveries))
+            for key, supporters := range citizen.Guardians.Recoveries {
+                guardians.Recoveries[key] = append([]string(nil), supporters...)
+            }
+            copied.Guardians = &guardians
+        }
+        if citizen.Appeal != nil {
+            appeal := *citizen.Appeal
+            appeal.Grants = append([]string(nil), appeal.Grants...)
+            appeal.Denials = append([]string(nil), appeal.Denials...)
+            copied.Appeal = &appeal
+        }
+        registry.citizens[key] = &copied
+    }
+    for key, isAdmin := range cr.admins {
+        registry.admins[key] = isAdmin
+    }
+    for key, ids := range cr.identities {
+        registry.identities[key] = append([]string(nil), ids...)
+    }
+    for id, proposal := range cr.proposals {
+        copied := *proposal
+        copied.Approvals = append([]string(nil), proposal.Approvals...)
+        registry.proposals[id] = &copied
+    }
+    return registry
+}
+
 func generateCitizenID(name, publicKey string) string {
     h := sha256.New()
     h.Write([]byte(name + publicKey))
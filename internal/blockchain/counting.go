@@ -0,0 +1,404 @@
+package blockchain
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "math/bits"
+    "sort"
+    "strings"
+)
+
+// Voting methods an election can be counted with
+const (
+    MethodPlurality     = "plurality"      // one candidate per ballot; most votes wins
+    MethodInstantRunoff = "instant-runoff" // ranked ballots; the last-placed candidate is eliminated until one has a majority
+    MethodApproval      = "approval"       // any number of candidates per ballot; most approvals wins
+    MethodScore         = "score"          // a score for any number of candidates; highest total wins
+    MethodSTV           = "stv"            // ranked ballots filling several seats by single transferable vote
+)
+
+const (
+    defaultMaxScore = 5
+    maxMaxScore     = 100
+)
+
+// transferScale is the fixed-point unit STV counts ballots in, so that
+// fractional transfers come out the same on every node
+const transferScale = 1_000_000_000
+
+// lotDomain separates the lots drawn to break ties from every other hash
+const lotDomain = "virtual-ethiopia/lot"
+
+// RunoffRound is one round of an instant-runoff count
+type RunoffRound struct {
+    Round      int            `json:"round"`
+    Tallies    map[string]int `json:"tallies"`             // continuing candidate ID -> ballots
+    Exhausted  int            `json:"exhausted"`           // ballots ranking no continuing candidate
+    Elected    string         `json:"elected,omitempty"`   // set in the final round
+    Eliminated string         `json:"eliminated,omitempty"`
+    Tied       []string       `json:"tied,omitempty"`      // candidates tied for last place, if a tie had to be broken
+}
+
+// TransferRound is one round of an STV count. Ballot values are fractional
+// once surpluses have been transferred.
+type TransferRound struct {
+    Round      int                `json:"round"`
+    Quota      float64            `json:"quota"`
+    Tallies    map[string]float64 `json:"tallies"`              // continuing candidate ID -> value of ballots
+    Exhausted  float64            `json:"exhausted"`            // value of ballots ranking no continuing candidate
+    Elected    []string           `json:"elected,omitempty"`    // highest tally first
+    Eliminated string             `json:"eliminated,omitempty"`
+    Tied       []string           `json:"tied,omitempty"`       // candidates tied for last place, if a tie had to be broken
+}
+
+// count determines the final counts and the indexes of the elected
+// candidates, in order of election; none if no ballot was counted. Every
+// ballot counts with its voter's weight. Instant-runoff and STV counts
+// also record their rounds.
+func (e *Election) count() (map[string]int, []int) {
+    e.resolveDelegations()
+    voteCounts := make(map[string]int)
+    switch e.method() {
+    case MethodInstantRunoff:
+        return e.countInstantRunoff()
+    case MethodSTV:
+        return e.countSTV()
+    case MethodApproval:
+        for voter, approvals := range e.Approvals {
+            for _, candidateID := range approvals {
+                voteCounts[candidateID] += e.weight(voter)
+            }
+        }
+    case MethodScore:
+        for voter, scores := range e.Scores {
+            for candidateID, score := range scores {
+                voteCounts[candidateID] += score * e.weight(voter)
+            }
+        }
+    default:
+        for voter, candidateID := range e.Votes {
+            voteCounts[candidateID] += e.weight(voter)
+        }
+    }
+    return voteCounts, e.topCandidates(voteCounts)
+}
+
+// topCandidates returns the index of the candidate with the highest count,
+// breaking ties by lot, or nothing if every count is zero. Disqualified
+// candidates are passed over.
+func (e *Election) topCandidates(voteCounts map[string]int) []int {
+    var leaders []string
+    maxVotes := 0
+    for _, candidate := range e.Candidates {
+        if candidate.Disqualified {
+            continue
+        }
+        votes := voteCounts[candidate.ID]
+        switch {
+        case votes > maxVotes:
+            maxVotes = votes
+            leaders = []string{candidate.ID}
+        case votes == maxVotes && votes > 0:
+            leaders = append(leaders, candidate.ID)
+        }
+    }
+    if len(leaders) == 0 {
+        return nil
+    }
+    return []int{e.candidateIndex(e.drawLots(leaders)[0])}
+}
+
+// countInstantRunoff counts ranked ballots round by round. Each ballot
+// counts for its highest-ranked continuing candidate. A candidate with a
+// majority of the ballots still in play is elected; otherwise the candidate
+// with the fewest is eliminated. Ties for last place are broken by the
+// earlier rounds, latest first, and then by lot.
+func (e *Election) countInstantRunoff() (map[string]int, []int) {
+    continuing := make(map[string]bool, len(e.Candidates))
+    for _, candidate := range e.Candidates {
+        if !candidate.Disqualified {
+            continuing[candidate.ID] = true
+        }
+    }
+
+    voteCounts := make(map[string]int)
+    var history []map[string]int64
+    e.Rounds = nil
+    for round := 1; len(continuing) > 0; round++ {
+        result := RunoffRound{Round: round, Tallies: make(map[string]int, len(continuing))}
+        for candidateID := range continuing {
+            result.Tallies[candidateID] = 0
+        }
+        for voter, ranking := range e.Rankings {
+            if top, ok := firstContinuing(ranking, continuing); ok {
+                result.Tallies[top] += e.weight(voter)
+            } else {
+                result.Exhausted += e.weight(voter)
+            }
+        }
+        tallies := make(map[string]int64, len(result.Tallies))
+        for candidateID, votes := range result.Tallies {
+            voteCounts[candidateID] = votes
+            tallies[candidateID] = int64(votes)
+        }
+
+        active := totalWeight(e, e.Rankings) - result.Exhausted
+        if active == 0 {
+            e.Rounds = append(e.Rounds, result)
+            return voteCounts, nil
+        }
+
+        // Candidates are visited in registration order so the report and
+        // the outcome never depend on map iteration
+        var leader string
+        var last []string
+        fewest := -1
+        for _, candidate := range e.Candidates {
+            if !continuing[candidate.ID] {
+                continue
+            }
+            votes := result.Tallies[candidate.ID]
+            if leader == "" || votes > result.Tallies[leader] {
+                leader = candidate.ID
+            }
+            switch {
+            case fewest < 0 || votes < fewest:
+                fewest = votes
+                last = []string{candidate.ID}
+            case votes == fewest:
+                last = append(last, candidate.ID)
+            }
+        }
+
+        if 2*result.Tallies[leader] > active || len(continuing) == 1 {
+            result.Elected = leader
+            e.Rounds = append(e.Rounds, result)
+            return voteCounts, []int{e.candidateIndex(leader)}
+        }
+
+        if len(last) > 1 {
+            result.Tied = last
+        }
+        result.Eliminated = e.breakRunoffTie(last, history)
+        delete(continuing, result.Eliminated)
+        e.Rounds = append(e.Rounds, result)
+        history = append(history, tallies)
+    }
+    return voteCounts, nil
+}
+
+// countSTV fills the election's seats by single transferable vote. Each
+// ballot counts, at its current value, for its highest-ranked continuing
+// candidate. Every candidate reaching the Droop quota is elected and the
+// surplus over the quota is passed on by lowering the value of each of
+// their ballots by the same fraction. When nobody reaches the quota the
+// candidate with the least is eliminated and their ballots pass on at full
+// value. Once no more candidates continue than there are seats left, all
+// of them are elected. Ties for last place are broken as in an
+// instant-runoff count.
+func (e *Election) countSTV() (map[string]int, []int) {
+    e.TransferRounds = nil
+    voteCounts := make(map[string]int)
+    if len(e.Rankings) == 0 {
+        return voteCounts, nil
+    }
+
+    continuing := make(map[string]bool, len(e.Candidates))
+    for _, candidate := range e.Candidates {
+        if !candidate.Disqualified {
+            continuing[candidate.ID] = true
+        }
+    }
+
+    // Ballots are visited in voter order so truncation in the transfers
+    // never depends on map iteration
+    voters := sortedKeys(e.Rankings)
+    values := make([]uint64, len(voters))
+    for i, voter := range voters {
+        values[i] = uint64(e.weight(voter)) * transferScale
+    }
+    quota := (uint64(totalWeight(e, e.Rankings))/uint64(e.Seats+1) + 1) * transferScale
+
+    var elected []int
+    var history []map[string]int64
+    for round := 1; len(elected) < e.Seats && len(continuing) > 0; round++ {
+        tallies := make(map[string]uint64, len(continuing))
+        holders := make([]string, len(voters)) // candidate each ballot counts for
+        var exhausted uint64
+        for i, voter := range voters {
+            if top, ok := firstContinuing(e.Rankings[voter], continuing); ok {
+                tallies[top] += values[i]
+                holders[i] = top
+            } else {
+                exhausted += values[i]
+            }
+        }
+
+        result := TransferRound{
+            Round:     round,
+            Quota:     float64(quota) / transferScale,
+            Tallies:   make(map[string]float64, len(continuing)),
+            Exhausted: float64(exhausted) / transferScale,
+        }
+        recorded := make(map[string]int64, len(continuing))
+        var reached, last []string
+        var fewest uint64
+        for _, candidate := range e.Candidates {
+            if !continuing[candidate.ID] {
+                continue
+            }
+            tally := tallies[candidate.ID]
+            result.Tallies[candidate.ID] = float64(tally) / transferScale
+            recorded[candidate.ID] = int64(tally)
+            voteCounts[candidate.ID] = int(tally / transferScale)
+            if tally >= quota {
+                reached = append(reached, candidate.ID)
+            }
+            switch {
+            case last == nil || tally < fewest:
+                fewest = tally
+                last = []string{candidate.ID}
+            case tally == fewest:
+                last = append(last, candidate.ID)
+            }
+        }
+
+        switch {
+        case len(continuing) <= e.Seats-len(elected):
+            reached = sortedKeys(continuing)
+        case len(reached) > 0:
+            for i, holder := range holders {
+                if tally := tallies[holder]; holder != "" && tally >= quota {
+                    hi, lo := bits.Mul64(values[i], tally-quota)
+                    values[i], _ = bits.Div64(hi, lo, tally)
+                }
+            }
+        default:
+            if len(last) > 1 {
+                result.Tied = last
+            }
+            result.Eliminated = e.breakRunoffTie(last, history)
+            delete(continuing, result.Eliminated)
+            e.TransferRounds = append(e.TransferRounds, result)
+            history = append(history, recorded)
+            continue
+        }
+
+        // Candidates elected together are listed by tally, ties by lot
+        reached = e.drawLots(reached)
+        sort.SliceStable(reached, func(i, j int) bool {
+            return tallies[reached[i]] > tallies[reached[j]]
+        })
+        if open := e.Seats - len(elected); len(reached) > open {
+            reached = reached[:open]
+        }
+        for _, candidateID := range reached {
+            delete(continuing, candidateID)
+            elected = append(elected, e.candidateIndex(candidateID))
+        }
+        result.Elected = reached
+        e.TransferRounds = append(e.TransferRounds, result)
+        history = append(history, recorded)
+    }
+    return voteCounts, elected
+}
+
+// breakRunoffTie picks which of the candidates tied for last place is
+// eliminated: the one with the fewest votes in the latest earlier round
+// that separates them, or failing that the one drawn last by lot
+func (e *Election) breakRunoffTie(tied []string, history []map[string]int64) string {
+    for i := len(history) - 1; i >= 0 && len(tied) > 1; i-- {
+        tallies := history[i]
+        var fewest int64 = -1
+        var remaining []string
+        for _, candidateID := range tied {
+            votes := tallies[candidateID]
+            switch {
+            case fewest < 0 || votes < fewest:
+                fewest = votes
+                remaining = []string{candidateID}
+            case votes == fewest:
+                remaining = append(remaining, candidateID)
+            }
+        }
+        tied = remaining
+    }
+    order := e.drawLots(tied)
+    return order[len(order)-1]
+}
+
+// drawLots orders candidate IDs by lot, first drawn first. The lots are
+// seeded with every counted ballot, so nobody can know them, or grind a
+// candidate ID to favour themselves, before voting has closed.
+func (e *Election) drawLots(candidateIDs []string) []string {
+    seed := e.lotSeed()
+    lots := make(map[string][]byte, len(candidateIDs))
+    for _, candidateID := range candidateIDs {
+        var enc encoder
+        enc.bytes(seed)
+        enc.string(candidateID)
+        lot := sha256.Sum256(enc.buf.Bytes())
+        lots[candidateID] = lot[:]
+    }
+
+    order := append([]string(nil), candidateIDs...)
+    sort.Slice(order, func(i, j int) bool {
+        return bytes.Compare(lots[order[i]], lots[order[j]]) < 0
+    })
+    return order
+}
+
+func (e *Election) lotSeed() []byte {
+    var enc encoder
+    enc.string(lotDomain)
+    enc.string(e.ID)
+    for _, voter := range sortedKeys(e.Votes) {
+        enc.string(voter)
+        enc.string(e.Votes[voter])
+    }
+    for _, voter := range sortedKeys(e.Rankings) {
+        enc.string(voter)
+        enc.string(strings.Join(e.Rankings[voter], ","))
+    }
+    for _, voter := range sortedKeys(e.Approvals) {
+        enc.string(voter)
+        enc.string(strings.Join(e.Approvals[voter], ","))
+    }
+    for _, voter := range sortedKeys(e.Scores) {
+        enc.string(voter)
+        enc.string((&Ballot{Scores: e.Scores[voter]}).choice(MethodScore))
+    }
+    for _, voter := range sortedKeys(e.Ballots) {
+        enc.string(voter)
+        enc.string(e.Ballots[voter])
+    }
+    seed := sha256.Sum256(enc.buf.Bytes())
+    return seed[:]
+}
+
+func firstContinuing(ranking []string, continuing map[string]bool) (string, bool) {
+    for _, candidateID := range ranking {
+        if continuing[candidateID] {
+            return candidateID, true
+        }
+    }
+    return "", false
+}
+
+func (e *Election) candidateIndex(candidateID string) int {
+    for i, candidate := range e.Candidates {
+        if candidate.ID == candidateID {
+            return i
+        }
+    }
+    return -1
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+    keys := make([]string, 0, len(m))
+    for key := range m {
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    return keys
+}
@@ -0,0 +1,287 @@
+package blockchain
+
+import (
+    "fmt"
+    "slices"
+    "testing"
+)
+
+// ballots is a number of identical ballots
+type ballots struct {
+    n      int
+    ballot Ballot
+}
+
+// countingElection returns an election counted by method, filling seats,
+// whose candidates' IDs are the given names, holding the given ballots
+// from distinct voters
+func countingElection(t *testing.T, method string, seats int, candidates []string, cast []ballots) *Election {
+    t.Helper()
+    e := &Election{
+        ID:              "election",
+        ElectionOptions: ElectionOptions{Method: method, Seats: seats, MaxScore: defaultMaxScore},
+        Votes:           make(map[string]string),
+        Rankings:        make(map[string][]string),
+        Approvals:       make(map[string][]string),
+        Scores:          make(map[string]map[string]int),
+    }
+    for _, name := range candidates {
+        e.Candidates = append(e.Candidates, Candidate{ID: name, Name: name})
+    }
+    voter := 0
+    for _, group := range cast {
+        for i := 0; i < group.n; i++ {
+            if err := e.validateBallot(&group.ballot); err != nil {
+                t.Fatal(err)
+            }
+            e.recordBallot(fmt.Sprintf("voter-%03d", voter), &group.ballot)
+            voter++
+        }
+    }
+    return e
+}
+
+// elected returns the IDs of the candidates at the given indexes
+func elected(e *Election, winners []int) []string {
+    ids := []string{}
+    for _, winner := range winners {
+        ids = append(ids, e.Candidates[winner].ID)
+    }
+    return ids
+}
+
+func ranking(candidateIDs ...string) Ballot {
+    return Ballot{Ranking: candidateIDs}
+}
+
+func TestInstantRunoff(t *testing.T) {
+    tests := []struct {
+        name       string
+        candidates []string
+        cast       []ballots
+        winners    []string
+        eliminated []string // in order
+        tied       []string // candidates tied for last place in the final elimination, if any
+    }{
+        {
+            name:       "majority in the first round",
+            candidates: []string{"A", "B", "C"},
+            cast:       []ballots{{3, ranking("A")}, {1, ranking("B")}, {1, ranking("C")}},
+            winners:    []string{"A"},
+        },
+        {
+            name:       "transfers decide",
+            candidates: []string{"A", "B", "C"},
+            cast:       []ballots{{4, ranking("A")}, {3, ranking("B", "C")}, {2, ranking("C", "B")}},
+            winners:    []string{"B"},
+            eliminated: []string{"C"},
+        },
+        {
+            name:       "exhausted ballots leave the count",
+            candidates: []string{"A", "B", "C"},
+            cast:       []ballots{{4, ranking("A")}, {3, ranking("B")}, {2, ranking("C")}},
+            winners:    []string{"A"},
+            eliminated: []string{"C"},
+        },
+        {
+            name:       "tie broken by the earlier round",
+            candidates: []string{"A", "B", "C", "D"},
+            cast: []ballots{
+                {5, ranking("A")}, {4, ranking("B")}, {3, ranking("C", "A")}, {1, ranking("D", "C")},
+            },
+            winners:    []string{"A"},
+            eliminated: []string{"D", "C"},
+            tied:       []string{"B", "C"},
+        },
+        {
+            name:       "no ballots",
+            candidates: []string{"A", "B"},
+            winners:    []string{},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            e := countingElection(t, MethodInstantRunoff, 1, tt.candidates, tt.cast)
+            _, winners := e.count()
+            if got := elected(e, winners); !slices.Equal(got, tt.winners) {
+                t.Errorf("elected %v, want %v", got, tt.winners)
+            }
+
+            var eliminated, tied []string
+            for _, round := range e.Rounds {
+                if round.Eliminated != "" {
+                    eliminated = append(eliminated, round.Eliminated)
+                    tied = round.Tied
+                }
+            }
+            if !slices.Equal(eliminated, tt.eliminated) {
+                t.Errorf("eliminated %v, want %v", eliminated, tt.eliminated)
+            }
+            if !slices.Equal(tied, tt.tied) {
+                t.Errorf("tied %v, want %v", tied, tt.tied)
+            }
+        })
+    }
+}
+
+func TestSingleTransferableVote(t *testing.T) {
+    tests := []struct {
+        name       string
+        seats      int
+        candidates []string
+        cast       []ballots
+        winners    []string // in order of election
+    }{
+        {
+            name:       "surplus transfer",
+            seats:      2,
+            candidates: []string{"A", "B", "C"},
+            cast:       []ballots{{6, ranking("A", "B")}, {2, ranking("B")}, {3, ranking("C")}},
+            winners:    []string{"A", "B"},
+        },
+        {
+            name:       "exhausted surplus",
+            seats:      2,
+            candidates: []string{"A", "B", "C"},
+            cast:       []ballots{{6, ranking("A")}, {2, ranking("B")}, {3, ranking("C")}},
+            winners:    []string{"A", "C"},
+        },
+        {
+            name:       "elimination transfer",
+            seats:      2,
+            candidates: []string{"A", "B", "C", "D"},
+            cast: []ballots{
+                {4, ranking("A")}, {3, ranking("B")}, {3, ranking("C")}, {1, ranking("D", "C")},
+            },
+            winners: []string{"A", "C"},
+        },
+        {
+            name:       "remaining candidates fill the seats",
+            seats:      3,
+            candidates: []string{"A", "B", "C"},
+            cast:       []ballots{{5, ranking("A")}, {1, ranking("B")}},
+            winners:    []string{"A", "B", "C"},
+        },
+        {
+            name:       "single seat",
+            seats:      1,
+            candidates: []string{"A", "B", "C"},
+            cast:       []ballots{{4, ranking("A")}, {3, ranking("B", "C")}, {2, ranking("C", "B")}},
+            winners:    []string{"B"},
+        },
+        {
+            name:       "no ballots",
+            seats:      2,
+            candidates: []string{"A", "B"},
+            winners:    []string{},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            e := countingElection(t, MethodSTV, tt.seats, tt.candidates, tt.cast)
+            _, winners := e.count()
+            if got := elected(e, winners); !slices.Equal(got, tt.winners) {
+                t.Errorf("elected %v, want %v", got, tt.winners)
+            }
+        })
+    }
+}
+
+func TestApprovalAndScore(t *testing.T) {
+    tests := []struct {
+        name    string
+        method  string
+        cast    []ballots
+        counts  map[string]int
+        winners []string
+    }{
+        {
+            name:   "approval",
+            method: MethodApproval,
+            cast: []ballots{
+                {1, Ballot{Approvals: []string{"A", "B"}}},
+                {1, Ballot{Approvals: []string{"B"}}},
+                {1, Ballot{Approvals: []string{"B", "C"}}},
+                {1, Ballot{Approvals: []string{"A"}}},
+            },
+            counts:  map[string]int{"A": 2, "B": 3, "C": 1},
+            winners: []string{"B"},
+        },
+        {
+            name:   "score",
+            method: MethodScore,
+            cast: []ballots{
+                {1, Ballot{Scores: map[string]int{"A": 5, "B": 3}}},
+                {1, Ballot{Scores: map[string]int{"A": 0, "B": 4}}},
+                {1, Ballot{Scores: map[string]int{"B": 2, "C": 5}}},
+            },
+            counts:  map[string]int{"A": 5, "B": 9, "C": 5},
+            winners: []string{"B"},
+        },
+        {
+            name:    "score of zero elects nobody",
+            method:  MethodScore,
+            cast:    []ballots{{2, Ballot{Scores: map[string]int{"A": 0}}}},
+            counts:  map[string]int{},
+            winners: []string{},
+        },
+        {
+            name:    "plurality",
+            method:  MethodPlurality,
+            cast:    []ballots{{2, Ballot{CandidateID: "C"}}, {1, Ballot{CandidateID: "A"}}},
+            counts:  map[string]int{"A": 1, "C": 2},
+            winners: []string{"C"},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            e := countingElection(t, tt.method, 1, []string{"A", "B", "C"}, tt.cast)
+            counts, winners := e.count()
+            for _, candidateID := range []string{"A", "B", "C"} {
+                if counts[candidateID] != tt.counts[candidateID] {
+                    t.Errorf("%s counted %d, want %d", candidateID, counts[candidateID], tt.counts[candidateID])
+                }
+            }
+            if got := elected(e, winners); !slices.Equal(got, tt.winners) {
+                t.Errorf("elected %v, want %v", got, tt.winners)
+            }
+        })
+    }
+}
+
+func TestValidateBallot(t *testing.T) {
+    tests := []struct {
+        name   string
+        method string
+        ballot Ballot
+        ok     bool
+    }{
+        {"ranking", MethodInstantRunoff, ranking("B", "A"), true},
+        {"partial ranking", MethodSTV, ranking("C"), true},
+        {"empty ranking", MethodInstantRunoff, ranking(), false},
+        {"ranking lists a candidate twice", MethodInstantRunoff, ranking("A", "B", "A"), false},
+        {"ranking of an unknown candidate", MethodSTV, ranking("A", "Z"), false},
+        {"ranking in a plurality election", MethodPlurality, ranking("A"), false},
+        {"candidate in a ranked election", MethodInstantRunoff, Ballot{CandidateID: "A"}, false},
+        {"approvals", MethodApproval, Ballot{Approvals: []string{"A", "C"}}, true},
+        {"no approvals", MethodApproval, Ballot{Approvals: []string{}}, false},
+        {"approval twice", MethodApproval, Ballot{Approvals: []string{"A", "A"}}, false},
+        {"approvals and a ranking", MethodApproval, Ballot{Approvals: []string{"A"}, Ranking: []string{"A"}}, false},
+        {"scores", MethodScore, Ballot{Scores: map[string]int{"A": 0, "B": defaultMaxScore}}, true},
+        {"no scores", MethodScore, Ballot{Scores: map[string]int{}}, false},
+        {"score above the maximum", MethodScore, Ballot{Scores: map[string]int{"A": defaultMaxScore + 1}}, false},
+        {"negative score", MethodScore, Ballot{Scores: map[string]int{"A": -1}}, false},
+        {"score of an unknown candidate", MethodScore, Ballot{Scores: map[string]int{"Z": 1}}, false},
+        {"candidate", MethodPlurality, Ballot{CandidateID: "B"}, true},
+        {"unknown candidate", MethodPlurality, Ballot{CandidateID: "Z"}, false},
+        {"empty ballot", MethodPlurality, Ballot{}, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            e := countingElection(t, tt.method, 1, []string{"A", "B", "C"}, nil)
+            if err := e.validateBallot(&tt.ballot); (err == nil) != tt.ok {
+                t.Errorf("validateBallot() = %v, want ok = %v", err, tt.ok)
+            }
+        })
+    }
+}
@@ -0,0 +1,74 @@
+package blockchain
+
+import (
+    "crypto/ed25519"
+    "crypto/rand"
+    "encoding/hex"
+    "fmt"
+)
+
+// Keys are exchanged as hex strings: a public key is the 32-byte Ed25519
+// public key and a private key is the 32-byte Ed25519 seed.
+
+// GenerateKeyPair creates a new Ed25519 key pair
+func GenerateKeyPair() (publicKey, privateKey string, err error) {
+    pub, priv, err := ed25519.GenerateKey(rand.Reader)
+    if err != nil {
+        return "", "", err
+    }
+    return hex.EncodeToString(pub), hex.EncodeToString(priv.Seed()), nil
+}
+
+// PublicKeyFromPrivate derives the public key belonging to a private key
+func PublicKeyFromPrivate(privateKey string) (string, error) {
+    priv, err := decodePrivateKey(privateKey)
+    if err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(priv.Public().(ed25519.PublicKey)), nil
+}
+
+// Sign signs message with a hex-encoded private key
+func Sign(privateKey string, message []byte) (string, error) {
+    priv, err := decodePrivateKey(privateKey)
+    if err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(ed25519.Sign(priv, message)), nil
+}
+
+// VerifySignature checks a hex-encoded signature of message against a
+// hex-encoded public key
+func VerifySignature(publicKey string, message []byte, signature string) bool {
+    pub, err := decodePublicKey(publicKey)
+    if err != nil {
+        return false
+    }
+    sig, err := hex.DecodeString(signature)
+    if err != nil || len(sig) != ed25519.SignatureSize {
+        return false
+    }
+    return ed25519.Verify(pub, message, sig)
+}
+
+// ValidatePublicKey reports whether key is a well-formed public key
+func ValidatePublicKey(key string) error {
+    _, err := decodePublicKey(key)
+    return err
+}
+
+func decodePublicKey(key string) (ed25519.PublicKey, error) {
+    raw, err := hex.DecodeString(key)
+    if err != nil || len(raw) != ed25519.PublicKeySize {
+        return nil, fmt.Errorf("invalid public key: expected %d hex-encoded bytes", ed25519.PublicKeySize)
+    }
+    return ed25519.PublicKey(raw), nil
+}
+
+func decodePrivateKey(key string) (ed25519.PrivateKey, error) {
+    raw, err := hex.DecodeString(key)
+    if err != nil || len(raw) != ed25519.SeedSize {
+        return nil, fmt.Errorf("invalid private key: expected %d hex-encoded bytes", ed25519.SeedSize)
+    }
+    return ed25519.NewKeyFromSeed(raw), nil
+}
@@ -0,0 +1,182 @@
+package blockchain
+
+import "errors"
+
+// Delegation lends a citizen's vote to another citizen, in one election,
+// in the elections of one category, or, with neither, in every election.
+// Citizens are identified by their citizen IDs.
+type Delegation struct {
+    Delegator  string `json:"delegator"`
+    Delegate   string `json:"delegate"`
+    ElectionID string `json:"electionId,omitempty"`
+    Category   string `json:"category,omitempty"`
+    Since      int64  `json:"since"`
+}
+
+// scope identifies the delegation a delegator holds for an election or a
+// category; each delegator holds at most one per scope
+func (d *Delegation) scope() string {
+    switch {
+    case d.ElectionID != "":
+        return d.Delegator + "/election/" + d.ElectionID
+    case d.Category != "":
+        return d.Delegator + "/category/" + d.Category
+    }
+    return d.Delegator + "/all"
+}
+
+// Delegate records that the citizen holding delegatorKey lends their vote
+// to the citizen d.Delegate within the scope of d, replacing any earlier
+// delegation of theirs in that scope
+func (es *ElectionSystem) Delegate(delegatorKey string, d Delegation, ctx BlockContext) error {
+    es.mu.Lock()
+    defer es.mu.Unlock()
+
+    delegator, exists := es.citizenRegistry.GetCitizen(delegatorKey)
+    if !exists || !es.citizenRegistry.IsCitizen(delegatorKey) {
+        return errors.New("delegator must be an approved citizen")
+    }
+    delegate, exists := es.citizenRegistry.GetCitizenByID(d.Delegate)
+    if !exists || !es.citizenRegistry.IsCitizen(delegate.PublicKey) {
+        return errors.New("delegate must be an approved citizen")
+    }
+    if delegate.ID == delegator.ID {
+        return errors.New("citizens cannot delegate to themselves")
+    }
+    if d.ElectionID != "" && d.Category != "" {
+        return errors.New("a delegation is scoped to an election or a category, not both")
+    }
+    if d.ElectionID != "" {
+        election, err := es.get(d.ElectionID)
+        if err != nil {
+            return err
+        }
+        if election.encrypted() {
+            return errors.New("votes cannot be delegated in encrypted elections")
+        }
+        if election.Status != Nominating && election.Status != InProgress {
+            return errors.New("election is no longer taking votes")
+        }
+    }
+
+    d.Delegator = delegator.ID
+    d.Since = ctx.Timestamp
+    es.delegations[d.scope()] = d
+    return nil
+}
+
+// RevokeDelegation withdraws the delegation the citizen holding
+// delegatorKey made in the scope of d
+func (es *ElectionSystem) RevokeDelegation(delegatorKey string, d Delegation) error {
+    es.mu.Lock()
+    defer es.mu.Unlock()
+
+    delegator, exists := es.citizenRegistry.GetCitizen(delegatorKey)
+    if !exists {
+        return errors.New("citizen not found")
+    }
+    d.Delegator = delegator.ID
+    if _, exists := es.delegations[d.scope()]; !exists {
+        return errors.New("no delegation in that scope")
+    }
+    delete(es.delegations, d.scope())
+    return nil
+}
+
+// GetDelegations returns every delegation in force, ordered by delegator
+// and scope
+func (es *ElectionSystem) GetDelegations() []Delegation {
+    es.mu.RLock()
+    defer es.mu.RUnlock()
+
+    delegations := make([]Delegation, 0, len(es.delegations))
+    for _, scope := range sortedKeys(es.delegations) {
+        delegations = append(delegations, es.delegations[scope])
+    }
+    return delegations
+}
+
+// closeVoting fixes the delegations that apply to the election, marks the
+// candidates who lost their candidacy rights, and ends its voting phase
+func (es *ElectionSystem) closeVoting(election *Election) {
+    election.Delegations = es.delegationsFor(election)
+    es.disqualifyIneligible(election)
+    election.closeVoting()
+}
+
+// delegationsFor returns, by public key, the delegate of every citizen on
+// the election's voter roll who delegated in it. A delegation for the
+// election takes precedence over one for its category, and that over one
+// for every election. Encrypted elections take no delegations.
+func (es *ElectionSystem) delegationsFor(election *Election) map[string]string {
+    if election.encrypted() {
+        return nil
+    }
+    delegations := make(map[string]string)
+    for _, citizenID := range election.VoterRoll {
+        d, found := es.delegations[(&Delegation{Delegator: citizenID, ElectionID: election.ID}).scope()]
+        if !found && election.Category != "" {
+            d, found = es.delegations[(&Delegation{Delegator: citizenID, Category: election.Category}).scope()]
+        }
+        if !found {
+            d, found = es.delegations[(&Delegation{Delegator: citizenID}).scope()]
+        }
+        if !found {
+            continue
+        }
+        delegator, _ := es.citizenRegistry.GetCitizenByID(citizenID)
+        delegate, exists := es.citizenRegistry.GetCitizenByID(d.Delegate)
+        if exists && es.citizenRegistry.IsCitizen(delegator.PublicKey) {
+            delegations[delegator.PublicKey] = delegate.PublicKey
+        }
+    }
+    return delegations
+}
+
+// resolveDelegations follows each delegation of a citizen who did not vote
+// through the chain of delegates to the first one who did, and records the
+// weight every voter received that way. A chain that runs into a cycle, or
+// to a delegate who neither voted nor delegated, carries no weight.
+func (e *Election) resolveDelegations() {
+    e.ReceivedWeight = nil
+    e.DelegatedWeight = 0
+
+    delegators := sortedKeys(e.Delegations)
+    for _, delegator := range delegators {
+        // A citizen who cast a ballot, even one never revealed, voted for
+        // themselves
+        if _, committed := e.Commitments[delegator]; committed || e.hasVoted(delegator) {
+            continue
+        }
+        visited := map[string]bool{delegator: true}
+        for delegate := e.Delegations[delegator]; !visited[delegate]; delegate = e.Delegations[delegate] {
+            if e.hasVoted(delegate) {
+                if e.ReceivedWeight == nil {
+                    e.ReceivedWeight = make(map[string]int)
+                }
+                e.ReceivedWeight[delegate]++
+                e.DelegatedWeight++
+                break
+            }
+            if _, delegated := e.Delegations[delegate]; !delegated {
+                break
+            }
+            visited[delegate] = true
+        }
+    }
+}
+
+// weight returns the number of votes a voter's ballot counts for: their
+// own and every one delegated to them
+func (e *Election) weight(voter string) int {
+    return 1 + e.ReceivedWeight[voter]
+}
+
+// totalWeight returns the number of votes the given ballots count for
+func totalWeight[V any](e *Election, ballots map[string]V) int {
+    total := 0
+    for voter := range ballots {
+        total += e.weight(voter)
+    }
+    return total
+}
@@ -0,0 +1,166 @@
+package blockchain
+
+import (
+    "maps"
+    "testing"
+)
+
+func TestResolveDelegations(t *testing.T) {
+    tests := []struct {
+        name        string
+        delegations map[string]string // delegator -> delegate
+        votes       []string          // voters, each voting for candidate X
+        committed   []string          // voters with an unrevealed secret ballot
+        received    map[string]int    // voter -> votes delegated to them
+    }{
+        {"direct", map[string]string{"a": "b"}, []string{"b"}, nil, map[string]int{"b": 1}},
+        {"chain", map[string]string{"a": "b", "b": "c"}, []string{"c"}, nil, map[string]int{"c": 2}},
+        {"stops at the first voter", map[string]string{"a": "b", "b": "c"}, []string{"b", "c"}, nil, map[string]int{"b": 1}},
+        {"delegator voted", map[string]string{"a": "b"}, []string{"a", "b"}, nil, map[string]int{}},
+        {"delegator committed", map[string]string{"a": "b"}, []string{"b"}, []string{"a"}, map[string]int{}},
+        {"dead end", map[string]string{"a": "b"}, []string{"c"}, nil, map[string]int{}},
+        {"cycle", map[string]string{"a": "b", "b": "a"}, []string{"c"}, nil, map[string]int{}},
+        {"into a cycle", map[string]string{"c": "a", "a": "b", "b": "a"}, []string{"d"}, nil, map[string]int{}},
+        {"several delegators", map[string]string{"a": "c", "b": "c", "d": "a"}, []string{"c"}, nil, map[string]int{"c": 3}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            e := countingElection(t, MethodPlurality, 1, []string{"X"}, nil)
+            e.Delegations = tt.delegations
+            e.Commitments = make(map[string]string)
+            for _, voter := range tt.votes {
+                e.Votes[voter] = "X"
+            }
+            for _, voter := range tt.committed {
+                e.Commitments[voter] = "commitment"
+            }
+
+            counts, _ := e.count()
+            received := e.ReceivedWeight
+            if received == nil {
+                received = map[string]int{}
+            }
+            if !maps.Equal(received, tt.received) {
+                t.Errorf("received = %v, want %v", received, tt.received)
+            }
+            delegated := 0
+            for _, weight := range tt.received {
+                delegated += weight
+            }
+            if e.DelegatedWeight != delegated || counts["X"] != len(tt.votes)+delegated {
+                t.Errorf("delegated %d and counted %d, want %d and %d", e.DelegatedWeight, counts["X"], delegated, len(tt.votes)+delegated)
+            }
+        })
+    }
+}
+
+func TestDelegationScope(t *testing.T) {
+    type delegation struct {
+        to       string // "all", "category" or "election": the delegate named after the scope
+        category string
+    }
+    tests := []struct {
+        name        string
+        delegations []delegation
+        want        string // delegate the election uses, if any
+    }{
+        {"every election", []delegation{{"all", ""}}, "all"},
+        {"category", []delegation{{"all", ""}, {"category", "budget"}}, "category"},
+        {"other category", []delegation{{"all", ""}, {"category", "defence"}}, "all"},
+        {"election", []delegation{{"all", ""}, {"category", "budget"}, {"election", ""}}, "election"},
+        {"none", nil, ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestState(t, RegistryPolicy{})
+            delegator, _ := s.citizen("Delegator")
+            delegates := make(map[string]testKey)
+            delegateIDs := make(map[string]string)
+            for _, scope := range []string{"all", "category", "election"} {
+                delegates[scope], delegateIDs[scope] = s.citizen(scope)
+            }
+            id := s.startElection(ElectionOptions{Category: "budget"})
+
+            for _, d := range tt.delegations {
+                electionID := ""
+                if d.to == "election" {
+                    electionID = id
+                }
+                s.mustApply(signedBy(t, delegator)(NewDelegationTx(delegator.Public, delegateIDs[d.to], electionID, d.category, s.ctx.Timestamp)))
+            }
+            s.mustApply(signedBy(t, s.admin)(NewElectionEndTx(s.admin.Public, id, s.ctx.Timestamp)))
+
+            want := map[string]string{}
+            if tt.want != "" {
+                want[delegator.Public] = delegates[tt.want].Public
+            }
+            got := s.election(id).Delegations
+            if got == nil {
+                got = map[string]string{}
+            }
+            if !maps.Equal(got, want) {
+                t.Errorf("delegations = %v, want %v", got, want)
+            }
+        })
+    }
+}
+
+func TestDelegate(t *testing.T) {
+    tests := []struct {
+        name     string
+        delegate func(s *testState, self, other string) string // returns the delegate's citizen ID
+        scope    func(id string) (string, string)             // election ID and category
+        ok       bool
+    }{
+        {"approved citizen", func(s *testState, self, other string) string { return other }, nil, true},
+        {"self", func(s *testState, self, other string) string { return self }, nil, false},
+        {"unknown citizen", func(s *testState, self, other string) string { return "unknown" }, nil, false},
+        {"pending citizen", func(s *testState, self, other string) string {
+            _, id := s.register("Pending")
+            return id
+        }, nil, false},
+        {"election and category", func(s *testState, self, other string) string { return other },
+            func(id string) (string, string) { return id, "budget" }, false},
+        {"unknown election", func(s *testState, self, other string) string { return other },
+            func(id string) (string, string) { return "unknown", "" }, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestState(t, RegistryPolicy{})
+            delegator, self := s.citizen("Delegator")
+            _, other := s.citizen("Delegate")
+            id := s.startElection(ElectionOptions{})
+            electionID, category := "", ""
+            if tt.scope != nil {
+                electionID, category = tt.scope(id)
+            }
+
+            delegate := tt.delegate(s, self, other)
+            err := s.apply(signedBy(t, delegator)(NewDelegationTx(delegator.Public, delegate, electionID, category, s.ctx.Timestamp)))
+            if (err == nil) != tt.ok {
+                t.Errorf("delegation error = %v, want ok = %v", err, tt.ok)
+            }
+        })
+    }
+}
+
+func TestRevokeDelegation(t *testing.T) {
+    s := newTestState(t, RegistryPolicy{})
+    delegator, _ := s.citizen("Delegator")
+    _, delegateID := s.citizen("Delegate")
+    s.mustApply(signedBy(t, delegator)(NewDelegationTx(delegator.Public, delegateID, "", "budget", s.ctx.Timestamp)))
+
+    if err := s.apply(signedBy(t, delegator)(NewDelegationRevocationTx(delegator.Public, "", "", s.ctx.Timestamp))); err == nil {
+        t.Error("revoked a delegation in a scope without one")
+    }
+    s.mustApply(signedBy(t, delegator)(NewDelegationRevocationTx(delegator.Public, "", "budget", s.ctx.Timestamp)))
+    if delegations := s.electionSystem.GetDelegations(); len(delegations) != 0 {
+        t.Errorf("delegations after revocation = %v", delegations)
+    }
+
+    id := s.startElection(ElectionOptions{Category: "budget"})
+    s.mustApply(signedBy(t, s.admin)(NewElectionEndTx(s.admin.Public, id, s.ctx.Timestamp)))
+    if delegations := s.election(id).Delegations; len(delegations) != 0 {
+        t.Errorf("election used a revoked delegation: %v", delegations)
+    }
+}
@@ -4,6 +4,10 @@ import (
     "crypto/sha256"
     "encoding/hex"    
     "errors"
+    "fmt"
+    "maps"
+    "math/big"
+    "slices"
     "sync"
     "time"
 )
@@ -15,8 +19,102 @@ const (
     InProgress
     Completed
     Cancelled
+    Revealing  // secret ballot elections: voting has closed and ballots are being opened
+    Tallying   // encrypted elections: voting has closed and trustees are decrypting the tally
+    Nominating // candidates may register; voting has not opened yet
 )
 
+// electionStatusNames are the names ParseElectionStatus accepts
+var electionStatusNames = map[ElectionStatus]string{
+    NotStarted: "not-started",
+    InProgress: "in-progress",
+    Completed:  "completed",
+    Cancelled:  "cancelled",
+    Revealing:  "revealing",
+    Tallying:   "tallying",
+    Nominating: "nominating",
+}
+
+func (s ElectionStatus) String() string {
+    if name, ok := electionStatusNames[s]; ok {
+        return name
+    }
+    return fmt.Sprintf("ElectionStatus(%d)", int(s))
+}
+
+// ParseElectionStatus returns the status with the given name, such as
+// "in-progress" or "completed"
+func ParseElectionStatus(name string) (ElectionStatus, error) {
+    for status, statusName := range electionStatusNames {
+        if statusName == name {
+            return status, nil
+        }
+    }
+    return 0, fmt.Errorf("unknown election status %q", name)
+}
+
+// ElectionOptions selects how an election takes and counts its ballots.
+// Without options votes are cast in the clear and counted by plurality.
+type ElectionOptions struct {
+    // Method is the voting method, MethodPlurality by default
+    Method string `json:"method,omitempty"`
+
+    // Seats is the number of candidates elected, 1 by default. Only STV
+    // elections fill more than one seat.
+    Seats int `json:"seats,omitempty"`
+
+    // MaxScore is the highest score a score ballot may give, 5 by default
+    MaxScore int `json:"maxScore,omitempty"`
+
+    // An election takes votes for its duration in days. NominationDays
+    // opens it for candidates alone for that many days first, and TallyDays
+    // bounds the reveal or decryption phase that follows voting; without it
+    // the phase lasts until it completes or an admin ends the election. An
+    // encrypted election that has not been decrypted by then is cancelled.
+    NominationDays int `json:"nominationDays,omitempty"`
+    TallyDays      int `json:"tallyDays,omitempty"`
+
+    // An election with VotingBlocks is scheduled in blocks instead of days.
+    // Its phases then only advance as blocks are produced.
+    NominationBlocks int64 `json:"nominationBlocks,omitempty"`
+    VotingBlocks     int64 `json:"votingBlocks,omitempty"`
+    TallyBlocks      int64 `json:"tallyBlocks,omitempty"`
+
+    // SecretBallot elections take commitments that are revealed once voting
+    // has closed
+    SecretBallot bool `json:"secretBallot,omitempty"`
+
+    // Elections with trustees take ballots encrypted under a key the
+    // trustees generate together; any Threshold of them can decrypt the
+    // tally, and no fewer can decrypt anything
+    Trustees  []string `json:"trustees,omitempty"`
+    Threshold int      `json:"threshold,omitempty"`
+
+    // A referendum asks Question instead of electing candidates; its
+    // ballots pick one of Choices, or Yes or No if it has none. Quorum is
+    // the turnout, in percent of the voter roll, below which nothing
+    // carries; it is never met on an empty roll. Supermajority is the
+    // share of the ballots, such as "2/3", the leading choice needs to
+    // carry.
+    Question      string   `json:"question,omitempty"`
+    Choices       []string `json:"choices,omitempty"`
+    Quorum        int      `json:"quorum,omitempty"`
+    Supermajority string   `json:"supermajority,omitempty"`
+
+    // Only citizens approved by the block at RollHeight, the election's
+    // first block by default, and at least MinimumAge years old when it
+    // starts may vote. A referendum's quorum is a share of them.
+    RollHeight int64 `json:"rollHeight,omitempty"`
+    MinimumAge int   `json:"minimumAge,omitempty"`
+
+    // Endorsements is the number of approved citizens, other than the
+    // candidate, who must endorse a candidate before they are on the ballot
+    Endorsements int `json:"endorsements,omitempty"`
+
+    // Category groups elections for delegations; see Delegation
+    Category string `json:"category,omitempty"`
+}
+
 // Election represents a presidential election
 type Election struct {
     ID            string         `json:"id"`
@@ -25,8 +123,69 @@ type Election struct {
     EndDate       int64          `json:"endDate"`
     Status        ElectionStatus `json:"status"`
     Candidates    []Candidate    `json:"candidates"`
-    Votes         map[string]string  `json:"votes"`  // CitizenID -> CandidateID
+    Votes         map[string]string  `json:"votes"`  // voter public key -> candidate ID
     Winner        *Candidate     `json:"winner,omitempty"`
+    Winners       []Candidate    `json:"winners,omitempty"` // every elected candidate, in order of election
+    ElectionOptions
+
+    // Phase deadlines, as block timestamps or, for elections scheduled in
+    // blocks, heights. A phase is over from the first block to reach its
+    // deadline; zero means it has none. EndDate closes voting.
+    NominationEndDate   int64 `json:"nominationEndDate,omitempty"`
+    TallyEndDate        int64 `json:"tallyEndDate,omitempty"`
+    StartHeight         int64 `json:"startHeight"`
+    NominationEndHeight int64 `json:"nominationEndHeight,omitempty"`
+    EndHeight           int64 `json:"endHeight,omitempty"`
+    TallyEndHeight      int64 `json:"tallyEndHeight,omitempty"`
+
+    // A secret ballot election collects commitments while it is in
+    // progress and fills Votes only as they are revealed, so the chain shows
+    // who voted but not for whom until the reveal phase
+    Commitments   map[string]string  `json:"commitments,omitempty"` // voter public key -> commitment
+
+    // Instant-runoff and STV elections take rankings instead of Votes and
+    // report every round of the count once completed
+    Rankings       map[string][]string `json:"rankings,omitempty"` // voter public key -> candidate IDs, most preferred first
+    Rounds         []RunoffRound       `json:"rounds,omitempty"`
+    TransferRounds []TransferRound     `json:"transferRounds,omitempty"`
+
+    // Approval and score elections take their own ballots instead of Votes
+    Approvals map[string][]string       `json:"approvals,omitempty"` // voter public key -> approved candidate IDs
+    Scores    map[string]map[string]int `json:"scores,omitempty"`    // voter public key -> candidate ID -> score
+
+    // An encrypted election only ever adds its ballots up. Votes stays
+    // empty; the counts come from decrypting EncryptedTally.
+    TrusteeCommitments map[string][]string `json:"trusteeCommitments,omitempty"` // trustee -> dealing commitments
+    PublicKey          string              `json:"publicKey,omitempty"`          // joint key, once every trustee has dealt
+    VerificationKeys   []string            `json:"verificationKeys,omitempty"`   // g^share of each trustee, in trustee order
+    Ballots            map[string]string   `json:"ballots,omitempty"`            // voter public key -> ballot transaction ID
+    EncryptedTally     []Ciphertext        `json:"encryptedTally,omitempty"`     // one ciphertext per candidate
+    DecryptionShares   map[string][]string `json:"decryptionShares,omitempty"`   // trustee -> partial decryptions of the tally
+
+    // The voter roll is frozen when the election starts. Turnout counts
+    // the ballots counted against it once the election has completed.
+    VoterRoll      []string `json:"voterRoll"` // citizen IDs, sorted
+    Eligible       int      `json:"eligible"`
+    Turnout        int      `json:"turnout"`
+    TurnoutPercent float64  `json:"turnoutPercent"`
+
+    // Candidates needing endorsements wait among Nominations until they
+    // have them; withdrawn candidates, on the ballot or not, are kept apart
+    Nominations []Candidate `json:"nominations,omitempty"`
+    Withdrawn   []Candidate `json:"withdrawn,omitempty"`
+
+    // Delegations maps each citizen on the voter roll who delegated in the
+    // election to their delegate, by public key, as of the close of voting.
+    // Each ballot counts for its voter and for everyone whose delegations
+    // reach them; DelegatedWeight is the part of the count that came
+    // through delegation.
+    Delegations     map[string]string `json:"delegations,omitempty"`
+    ReceivedWeight  map[string]int    `json:"receivedWeight,omitempty"` // voter -> votes delegated to them
+    DelegatedWeight int               `json:"delegatedWeight"`
+
+    // A referendum's choices are its candidates, without public keys.
+    // Winner is the choice that carried, if any.
+    Result *ReferendumResult `json:"result,omitempty"`
 }
 
 // Candidate represents a presidential candidate
@@ -36,12 +195,19 @@ type Candidate struct {
     PublicKey   string    `json:"publicKey"`
     Platform    string    `json:"platform"`
     VoteCount   int       `json:"voteCount"`
+    Endorsers   []string  `json:"endorsers,omitempty"` // citizen public keys, in the order they endorsed
+
+    // Disqualified is set on a candidate who was no longer an approved
+    // citizen when the ballots were counted; they cannot be elected
+    Disqualified bool `json:"disqualified,omitempty"`
 }
 
-// ElectionSystem manages the election process
+// ElectionSystem manages the election process. Any number of elections may
+// run at once; each is addressed by its ID.
 type ElectionSystem struct {
-    currentElection *Election
-    pastElections   []*Election
+    elections       map[string]*Election
+    order           []string // election IDs in the order they started
+    delegations     map[string]Delegation // scope -> delegation
     citizenRegistry *CitizenRegistry
     mu             sync.RWMutex
 }
@@ -49,55 +215,258 @@ type ElectionSystem struct {
 // NewElectionSystem creates a new election system
 func NewElectionSystem(registry *CitizenRegistry) *ElectionSystem {
     return &ElectionSystem{
-        pastElections:   make([]*Election, 0),
+        elections:       make(map[string]*Election),
+        delegations:     make(map[string]Delegation),
         citizenRegistry: registry,
     }
 }
 
-// StartElection initiates a new presidential election
-func (es *ElectionSystem) StartElection(name string, durationDays int) error {
+// StartElection initiates a new presidential election in the block
+// described by ctx. Its phase deadlines are computed in UTC from the block
+// timestamp, or from its height, so every node derives the same values.
+func (es *ElectionSystem) StartElection(id, name string, ctx BlockContext, durationDays int, options ElectionOptions) error {
     es.mu.Lock()
     defer es.mu.Unlock()
 
-    if es.currentElection != nil && es.currentElection.Status == InProgress {
-        return errors.New("an election is already in progress")
+    if _, exists := es.elections[id]; exists {
+        return errors.New("election already exists")
     }
 
-    startDate := time.Now().Unix()
-    endDate := time.Now().AddDate(0, 0, durationDays).Unix()
+    if err := options.validateSchedule(durationDays); err != nil {
+        return err
+    }
+    if err := options.validate(); err != nil {
+        return err
+    }
+    if err := options.validateReferendum(); err != nil {
+        return err
+    }
+    if err := options.validateRoll(ctx); err != nil {
+        return err
+    }
+    if options.RollHeight == 0 {
+        options.RollHeight = ctx.Height
+    }
+    if options.Method == "" {
+        options.Method = MethodPlurality
+    }
+    if options.Seats == 0 {
+        options.Seats = 1
+    }
+    if options.Method == MethodScore && options.MaxScore == 0 {
+        options.MaxScore = defaultMaxScore
+    }
 
-    es.currentElection = &Election{
-        ID:         generateElectionID(),
+    election := &Election{
+        ID:         id,
         Name:       name,
-        StartDate:  startDate,
-        EndDate:    endDate,
+        StartDate:  ctx.Timestamp,
+        StartHeight: ctx.Height,
         Status:     InProgress,
         Candidates: make([]Candidate, 0),
         Votes:      make(map[string]string),
+        ElectionOptions: options,
+    }
+    election.schedule(durationDays)
+    election.VoterRoll = es.citizenRegistry.voterRoll(options.RollHeight, options.MinimumAge, ctx.Timestamp)
+    election.Eligible = len(election.VoterRoll)
+    if options.referendum() {
+        election.Candidates = election.choiceCandidates()
+    }
+    if options.SecretBallot {
+        election.Commitments = make(map[string]string)
+    }
+    switch options.Method {
+    case MethodInstantRunoff, MethodSTV:
+        election.Rankings = make(map[string][]string)
+    case MethodApproval:
+        election.Approvals = make(map[string][]string)
+    case MethodScore:
+        election.Scores = make(map[string]map[string]int)
+    }
+    if options.encrypted() {
+        election.TrusteeCommitments = make(map[string][]string)
+        election.Ballots = make(map[string]string)
+        election.DecryptionShares = make(map[string][]string)
     }
 
+    es.elections[id] = election
+    es.order = append(es.order, id)
     return nil
 }
 
-// GetCurrentElection returns the current election
+// schedule sets the phase deadlines from the start of the election
+func (e *Election) schedule(durationDays int) {
+    if e.VotingBlocks > 0 {
+        votingStart := e.StartHeight
+        if e.NominationBlocks > 0 {
+            e.NominationEndHeight = e.StartHeight + e.NominationBlocks
+            votingStart = e.NominationEndHeight
+        }
+        e.EndHeight = votingStart + e.VotingBlocks
+        if e.TallyBlocks > 0 {
+            e.TallyEndHeight = e.EndHeight + e.TallyBlocks
+        }
+    } else {
+        votingStart := e.StartDate
+        if e.NominationDays > 0 {
+            e.NominationEndDate = addDays(e.StartDate, e.NominationDays)
+            votingStart = e.NominationEndDate
+        }
+        e.EndDate = addDays(votingStart, durationDays)
+        if e.TallyDays > 0 {
+            e.TallyEndDate = addDays(e.EndDate, e.TallyDays)
+        }
+    }
+
+    if e.NominationEndDate != 0 || e.NominationEndHeight != 0 {
+        e.Status = Nominating
+    }
+}
+
+func addDays(timestamp int64, days int) int64 {
+    return time.Unix(timestamp, 0).UTC().AddDate(0, 0, days).Unix()
+}
+
+// advance moves the current election through its phases as the block
+// described by ctx reaches their deadlines: nomination to voting, voting to
+// the reveal or decryption phase or straight to the certified result, and
+// an expired reveal phase to its result. An encrypted election whose
+// trustees miss the decryption deadline is cancelled. It runs before the
+// block's transactions, so every node makes the same transitions in the
+// same block, and a transaction in that block already sees the new phase.
+func (es *ElectionSystem) advance(ctx BlockContext) {
+    es.mu.Lock()
+    defer es.mu.Unlock()
+
+    // Elections are visited in the order they started, and one block may
+    // pass several deadlines of the same election
+    for _, id := range es.order {
+        election := es.elections[id]
+        for election.deadlinePassed(ctx) {
+            switch election.Status {
+            case Nominating:
+                election.Status = InProgress
+            case InProgress:
+                es.closeVoting(election)
+            case Revealing:
+                es.disqualifyIneligible(election)
+                election.complete(election.count())
+            case Tallying:
+                election.Status = Cancelled
+            }
+        }
+    }
+}
+
+// due reports whether a block described by ctx would move any election to
+// its next phase
+func (es *ElectionSystem) due(ctx BlockContext) bool {
+    es.mu.RLock()
+    defer es.mu.RUnlock()
+
+    for _, election := range es.elections {
+        if election.deadlinePassed(ctx) {
+            return true
+        }
+    }
+    return false
+}
+
+// deadlinePassed reports whether the block described by ctx is past the
+// deadline of the election's current phase
+func (e *Election) deadlinePassed(ctx BlockContext) bool {
+    var date, height int64
+    switch e.Status {
+    case Nominating:
+        date, height = e.NominationEndDate, e.NominationEndHeight
+    case InProgress:
+        date, height = e.EndDate, e.EndHeight
+    case Revealing, Tallying:
+        date, height = e.TallyEndDate, e.TallyEndHeight
+    }
+    return (date != 0 && ctx.Timestamp >= date) || (height != 0 && ctx.Height >= height)
+}
+
+// closeVoting ends the voting phase of the election: secret ballots are
+// then revealed, encrypted ones decrypted, and open ones counted at once
+func (e *Election) closeVoting() {
+    switch {
+    case e.SecretBallot:
+        e.Status = Revealing
+    case len(e.Ballots) > 0:
+        e.Status = Tallying
+    default:
+        e.complete(e.count())
+    }
+}
+
+// GetElection returns the election with the given ID
+func (es *ElectionSystem) GetElection(id string) (*Election, bool) {
+    es.mu.RLock()
+    defer es.mu.RUnlock()
+    election, ok := es.elections[id]
+    return election, ok
+}
+
+// GetElections returns the elections whose status is among statuses, or
+// every election if none are given, in the order they started
+func (es *ElectionSystem) GetElections(statuses ...ElectionStatus) []*Election {
+    es.mu.RLock()
+    defer es.mu.RUnlock()
+
+    elections := make([]*Election, 0)
+    for _, id := range es.order {
+        election := es.elections[id]
+        if len(statuses) == 0 || slices.Contains(statuses, election.Status) {
+            elections = append(elections, election)
+        }
+    }
+    return elections
+}
+
+// GetCurrentElection returns the most recently started election that has
+// not finished, or nil
 func (es *ElectionSystem) GetCurrentElection() *Election {
     es.mu.RLock()
     defer es.mu.RUnlock()
-    return es.currentElection
+
+    for i := len(es.order) - 1; i >= 0; i-- {
+        if election := es.elections[es.order[i]]; !election.finished() {
+            return election
+        }
+    }
+    return nil
 }
 
 // RegisterCandidate registers a new presidential candidate
-func (es *ElectionSystem) RegisterCandidate(name, publicKey, platform string) error {
+func (es *ElectionSystem) RegisterCandidate(electionID, name, publicKey, platform string) error {
     es.mu.Lock()
     defer es.mu.Unlock()
 
-    if es.currentElection == nil || es.currentElection.Status != InProgress {
-        return errors.New("no active election")
+    election, err := es.get(electionID)
+    if err != nil {
+        return err
+    }
+    if election.referendum() {
+        return errors.New("referendums take no candidates")
+    }
+    if !election.nominating() {
+        return errors.New("nominations are closed")
     }
 
     if !es.citizenRegistry.IsCitizen(publicKey) {
         return errors.New("candidate must be an approved citizen")
     }
+    if election.standing(publicKey) {
+        return errors.New("candidate has already stood in this election")
+    }
+
+    // Encrypted ballots hold one choice per candidate, so the list is
+    // fixed once the first one is cast
+    if len(election.Ballots) > 0 {
+        return errors.New("candidates cannot register once encrypted ballots have been cast")
+    }
 
     candidate := Candidate{
         ID:        generateCandidateID(name, publicKey),
@@ -106,81 +475,675 @@ func (es *ElectionSystem) RegisterCandidate(name, publicKey, platform string) er
         Platform:  platform,
     }
 
-    es.currentElection.Candidates = append(es.currentElection.Candidates, candidate)
+    // Candidates who need endorsements wait among the nominations until
+    // they have them
+    if election.Endorsements > 0 {
+        election.Nominations = append(election.Nominations, candidate)
+        return nil
+    }
+    election.Candidates = append(election.Candidates, candidate)
     return nil
 }
 
-// CastVote records a citizen's vote
-func (es *ElectionSystem) CastVote(citizenPublicKey, candidateID string) error {
+// CastVote records a citizen's vote. The ballot must be of the kind the
+// election's voting method takes.
+func (es *ElectionSystem) CastVote(electionID, citizenPublicKey string, ballot *Ballot) error {
     es.mu.Lock()
     defer es.mu.Unlock()
 
-    if es.currentElection == nil || es.currentElection.Status != InProgress {
-        return errors.New("no active election")
+    election, err := es.checkOpenBallot(electionID, citizenPublicKey)
+    if err != nil {
+        return err
     }
 
-    if !es.citizenRegistry.IsCitizen(citizenPublicKey) {
-        return errors.New("voter must be an approved citizen")
+    if err := election.validateBallot(ballot); err != nil {
+        return err
     }
 
-    if _, voted := es.currentElection.Votes[citizenPublicKey]; voted {
+    election.recordBallot(citizenPublicKey, ballot)
+    return nil
+}
+
+// checkOpenBallot returns the election named by electionID if the citizen
+// may cast an open ballot in it
+func (es *ElectionSystem) checkOpenBallot(electionID, citizenPublicKey string) (*Election, error) {
+    election, err := es.checkActive(electionID)
+    if err != nil {
+        return nil, err
+    }
+    if election.SecretBallot {
+        return nil, errors.New("election takes secret ballots; submit a commitment instead")
+    }
+    if election.encrypted() {
+        return nil, errors.New("election takes encrypted ballots")
+    }
+
+    if err := es.checkVoter(election, citizenPublicKey); err != nil {
+        return nil, err
+    }
+
+    if election.hasVoted(citizenPublicKey) {
+        return nil, errors.New("citizen has already voted")
+    }
+    return election, nil
+}
+
+// CommitVote records a citizen's sealed ballot in a secret ballot election
+func (es *ElectionSystem) CommitVote(electionID, citizenPublicKey, commitment string) error {
+    es.mu.Lock()
+    defer es.mu.Unlock()
+
+    election, err := es.checkActive(electionID)
+    if err != nil {
+        return err
+    }
+    if !election.SecretBallot {
+        return errors.New("election does not take secret ballots")
+    }
+
+    if err := es.checkVoter(election, citizenPublicKey); err != nil {
+        return err
+    }
+
+    if _, voted := election.Commitments[citizenPublicKey]; voted {
         return errors.New("citizen has already voted")
     }
 
-    candidateExists := false
-    for _, candidate := range es.currentElection.Candidates {
-        if candidate.ID == candidateID {
-            candidateExists = true
-            break
+    if err := validateCommitment(commitment); err != nil {
+        return err
+    }
+
+    election.Commitments[citizenPublicKey] = commitment
+    return nil
+}
+
+// CloseVoting stops a secret ballot election from taking commitments and
+// opens its reveal phase
+func (es *ElectionSystem) CloseVoting(electionID string) error {
+    es.mu.Lock()
+    defer es.mu.Unlock()
+
+    election, err := es.checkActive(electionID)
+    if err != nil {
+        return err
+    }
+    if !election.SecretBallot {
+        return errors.New("election does not take secret ballots")
+    }
+
+    es.closeVoting(election)
+    return nil
+}
+
+// RevealVote opens a citizen's commitment. Only a reveal that matches the
+// commitment and holds a valid ballot for the election's voting method is
+// counted, and only while the citizen may still vote: a citizen suspended
+// or revoked since committing cannot reveal.
+func (es *ElectionSystem) RevealVote(electionID, citizenPublicKey string, ballot *Ballot, salt string) error {
+    es.mu.Lock()
+    defer es.mu.Unlock()
+
+    election, err := es.checkPhase(electionID, Revealing)
+    if err != nil {
+        return err
+    }
+
+    commitment, committed := election.Commitments[citizenPublicKey]
+    if !committed {
+        return errors.New("citizen did not submit a ballot")
+    }
+
+    if err := es.checkVoter(election, citizenPublicKey); err != nil {
+        return err
+    }
+
+    if election.hasVoted(citizenPublicKey) {
+        return errors.New("ballot has already been revealed")
+    }
+
+    if err := validateSalt(salt); err != nil {
+        return err
+    }
+
+    choice := ballot.choice(election.method())
+    if !es.opensCommitment(electionID, citizenPublicKey, choice, salt, commitment) {
+        return errors.New("reveal does not match the committed ballot")
+    }
+
+    if err := election.validateBallot(ballot); err != nil {
+        return err
+    }
+
+    election.recordBallot(citizenPublicKey, ballot)
+    return nil
+}
+
+// SubmitDealing records a trustee's contribution to the key of an
+// encrypted election. Once every trustee has dealt, the joint key and the
+// trustees' verification keys are fixed and ballots can be cast.
+func (es *ElectionSystem) SubmitDealing(electionID, trusteeKey string, dealing *TrusteeDealing) error {
+    es.mu.Lock()
+    defer es.mu.Unlock()
+
+    election, err := es.get(electionID)
+    if err != nil {
+        return err
+    }
+    if election.Status != Nominating && election.Status != InProgress {
+        return errors.New("election is no longer taking votes")
+    }
+    if election.trusteeIndex(trusteeKey) < 0 {
+        return errors.New("not a trustee of this election")
+    }
+    if _, dealt := election.TrusteeCommitments[trusteeKey]; dealt {
+        return errors.New("trustee has already dealt")
+    }
+
+    if _, err := verifyDealing(electionID, trusteeKey, election.Threshold, dealing); err != nil {
+        return err
+    }
+    election.TrusteeCommitments[trusteeKey] = dealing.Commitments
+
+    if len(election.TrusteeCommitments) == len(election.Trustees) {
+        return election.deriveKeys()
+    }
+    return nil
+}
+
+// deriveKeys computes the joint key, the product of every trustee's g^a_0,
+// and each trustee's verification key g^s_j, where s_j is the sum of the
+// shares dealt to it
+func (e *Election) deriveKeys() error {
+    dealings := make([][]*big.Int, len(e.Trustees))
+    key := big.NewInt(1)
+    for i, trustee := range e.Trustees {
+        commitments, err := decodeElements(e.TrusteeCommitments[trustee])
+        if err != nil {
+            return err
+        }
+        dealings[i] = commitments
+        key = groupMul(key, commitments[0])
+    }
+
+    e.VerificationKeys = make([]string, len(e.Trustees))
+    for j := range e.Trustees {
+        verificationKey := big.NewInt(1)
+        for _, commitments := range dealings {
+            verificationKey = groupMul(verificationKey, evaluateCommitments(commitments, int64(j+1)))
         }
+        e.VerificationKeys[j] = encodeInt(verificationKey)
+    }
+    e.PublicKey = encodeInt(key)
+    return nil
+}
+
+// CastEncryptedVote adds a citizen's encrypted ballot to the tally of an
+// encrypted election. The ballot itself stays in transaction txID.
+func (es *ElectionSystem) CastEncryptedVote(electionID, citizenPublicKey, txID string, ballot *EncryptedBallot) error {
+    es.mu.Lock()
+    defer es.mu.Unlock()
+
+    election, err := es.checkActive(electionID)
+    if err != nil {
+        return err
+    }
+    if !election.encrypted() {
+        return errors.New("election does not take encrypted ballots")
     }
-    if !candidateExists {
-        return errors.New("invalid candidate")
+    if election.PublicKey == "" {
+        return errors.New("election key is not ready; waiting for trustees to deal")
     }
 
-    es.currentElection.Votes[citizenPublicKey] = candidateID
+    if err := es.checkVoter(election, citizenPublicKey); err != nil {
+        return err
+    }
+
+    if _, voted := election.Ballots[citizenPublicKey]; voted {
+        return errors.New("citizen has already voted")
+    }
+
+    key, err := decodeElement(election.PublicKey)
+    if err != nil {
+        return err
+    }
+    choices, err := verifyBallot(electionID, citizenPublicKey, key, len(election.Candidates), ballot)
+    if err != nil {
+        return err
+    }
+
+    tally := make([]Ciphertext, len(choices))
+    for i, choice := range choices {
+        a, b := choice[0], choice[1]
+        if len(election.EncryptedTally) > 0 {
+            sumA, sumB, err := election.EncryptedTally[i].decode()
+            if err != nil {
+                return err
+            }
+            a, b = groupMul(a, sumA), groupMul(b, sumB)
+        }
+        tally[i] = newCiphertext(a, b)
+    }
+
+    election.EncryptedTally = tally
+    election.Ballots[citizenPublicKey] = txID
     return nil
 }
 
-// EndElection concludes the current election and determines the winner
-func (es *ElectionSystem) EndElection() error {
+// SubmitDecryption records a trustee's partial decryption of the tally of
+// an ended encrypted election. The election completes as soon as Threshold
+// trustees have decrypted, with counts anyone can recompute from their
+// published shares.
+func (es *ElectionSystem) SubmitDecryption(electionID, trusteeKey string, share *DecryptionShare) error {
     es.mu.Lock()
     defer es.mu.Unlock()
 
-    if es.currentElection == nil || es.currentElection.Status != InProgress {
-        return errors.New("no active election")
+    election, err := es.checkPhase(electionID, Tallying)
+    if err != nil {
+        return err
+    }
+    index := election.trusteeIndex(trusteeKey)
+    if index < 0 {
+        return errors.New("not a trustee of this election")
+    }
+    if _, decrypted := election.DecryptionShares[trusteeKey]; decrypted {
+        return errors.New("trustee has already decrypted the tally")
+    }
+
+    tally := make([][2]*big.Int, len(election.EncryptedTally))
+    for i := range election.EncryptedTally {
+        a, b, err := election.EncryptedTally[i].decode()
+        if err != nil {
+            return err
+        }
+        tally[i] = [2]*big.Int{a, b}
+    }
+    verificationKey, err := decodeElement(election.VerificationKeys[index])
+    if err != nil {
+        return err
+    }
+    if _, err := verifyDecryptionShare(electionID, trusteeKey, verificationKey, tally, share); err != nil {
+        return err
+    }
+    if len(election.DecryptionShares)+1 < election.Threshold {
+        election.DecryptionShares[trusteeKey] = share.Values
+        return nil
     }
 
-    // Count votes
+    // The share is recorded only once the tally decrypts with it, so a
+    // failed decryption leaves the election as it was
+    shares := maps.Clone(election.DecryptionShares)
+    shares[trusteeKey] = share.Values
     voteCounts := make(map[string]int)
-    for _, candidateID := range es.currentElection.Votes {
-        voteCounts[candidateID]++
+    for i, candidate := range election.Candidates {
+        partials := make(map[int64]*big.Int)
+        for j, trustee := range election.Trustees {
+            if values, ok := shares[trustee]; ok {
+                value, err := decodeElement(values[i])
+                if err != nil {
+                    return err
+                }
+                partials[int64(j+1)] = value
+            }
+        }
+        count, err := decryptCount(tally[i][1], partials, len(election.Ballots))
+        if err != nil {
+            return err
+        }
+        voteCounts[candidate.ID] = count
+    }
+    election.DecryptionShares = shares
+    es.disqualifyIneligible(election)
+    election.complete(voteCounts, election.topCandidates(voteCounts))
+    return nil
+}
+
+// EndElection concludes the current election and determines the winner.
+// An encrypted election still waiting for its trustees to decrypt the
+// tally is cancelled instead, as when its decryption deadline passes.
+func (es *ElectionSystem) EndElection(electionID string) error {
+    es.mu.Lock()
+    defer es.mu.Unlock()
+
+    election, err := es.get(electionID)
+    if err != nil {
+        return err
+    }
+    if election.Status == Tallying {
+        election.Status = Cancelled
+        return nil
     }
 
-    // Find winner
-    var winningCandidate *Candidate
-    maxVotes := 0
-    for i, candidate := range es.currentElection.Candidates {
-        votes := voteCounts[candidate.ID]
-        es.currentElection.Candidates[i].VoteCount = votes
-        if votes > maxVotes {
-            maxVotes = votes
-            winningCandidate = &es.currentElection.Candidates[i]
+    // Secret ballots are counted once they have had a chance to be revealed;
+    // unrevealed commitments are not counted
+    phase := InProgress
+    if election.SecretBallot {
+        phase = Revealing
+    }
+    if _, err := es.checkPhase(electionID, phase); err != nil {
+        return err
+    }
+
+    if election.Status == Revealing {
+        es.disqualifyIneligible(election)
+        election.complete(election.count())
+        return nil
+    }
+
+    // Encrypted ballots are counted once the trustees have decrypted their sum
+    es.closeVoting(election)
+    return nil
+}
+
+// complete records the final counts of the election and the indexes of
+// the candidates it elected, if any. A referendum instead records its
+// result, and the choice that carried as its winner.
+func (e *Election) complete(voteCounts map[string]int, winners []int) {
+    for i, candidate := range e.Candidates {
+        e.Candidates[i].VoteCount = voteCounts[candidate.ID]
+    }
+    e.recordTurnout()
+    if e.referendum() {
+        e.Result = e.referendumResult(voteCounts)
+        winners = nil
+        if e.Result.Carried != "" {
+            winners = []int{e.candidateIndex(e.Result.Carried)}
         }
     }
 
-    es.currentElection.Status = Completed
-    es.currentElection.Winner = winningCandidate
-    es.pastElections = append(es.pastElections, es.currentElection)
-    es.currentElection = nil
+    e.Status = Completed
+    for _, winner := range winners {
+        e.Winners = append(e.Winners, e.Candidates[winner])
+    }
+    if len(winners) > 0 {
+        e.Winner = &e.Candidates[winners[0]]
+    }
+}
 
+// checkVoter verifies that a citizen is on the election's voter roll and
+// still an approved citizen
+func (es *ElectionSystem) checkVoter(election *Election, citizenPublicKey string) error {
+    citizen, exists := es.citizenRegistry.GetCitizen(citizenPublicKey)
+    if !exists || !es.citizenRegistry.IsCitizen(citizenPublicKey) {
+        return errors.New("voter must be an approved citizen")
+    }
+    if !election.onRoll(citizen.ID) {
+        return errors.New("citizen is not on the election's voter roll")
+    }
     return nil
 }
 
-func generateElectionID() string {
-    h := sha256.New()
-    h.Write([]byte(time.Now().String()))
-    return hex.EncodeToString(h.Sum(nil))
+// checkActive returns the election named by electionID if it is taking votes
+func (es *ElectionSystem) checkActive(electionID string) (*Election, error) {
+    return es.checkPhase(electionID, InProgress)
+}
+
+// checkPhase returns the election named by electionID if it has reached
+// status
+func (es *ElectionSystem) checkPhase(electionID string, status ElectionStatus) (*Election, error) {
+    election, err := es.get(electionID)
+    if err != nil {
+        return nil, err
+    }
+    if election.Status != status {
+        switch {
+        case election.Status == Nominating:
+            return nil, errors.New("election is not taking votes yet")
+        case status == Revealing:
+            return nil, errors.New("election is not in its reveal phase")
+        case status == Tallying:
+            return nil, errors.New("election is not being tallied")
+        }
+        return nil, errors.New("election is no longer taking votes")
+    }
+    return election, nil
+}
+
+// get returns the election named by electionID
+func (es *ElectionSystem) get(electionID string) (*Election, error) {
+    election, ok := es.elections[electionID]
+    if !ok {
+        return nil, errors.New("election not found")
+    }
+    return election, nil
+}
+
+// validateSchedule checks that the election is scheduled either in days,
+// taking votes for durationDays, or in blocks
+func (o *ElectionOptions) validateSchedule(durationDays int) error {
+    if o.NominationDays < 0 || o.TallyDays < 0 || o.NominationBlocks < 0 || o.VotingBlocks < 0 || o.TallyBlocks < 0 {
+        return errors.New("election phases cannot have a negative length")
+    }
+    if o.VotingBlocks > 0 {
+        if durationDays != 0 || o.NominationDays != 0 || o.TallyDays != 0 {
+            return errors.New("an election is scheduled either in days or in blocks, not both")
+        }
+        return nil
+    }
+    if o.NominationBlocks != 0 || o.TallyBlocks != 0 {
+        return errors.New("an election scheduled in blocks needs votingBlocks")
+    }
+    if durationDays <= 0 {
+        return errors.New("election duration must be at least one day")
+    }
+    return nil
+}
+
+// validate checks that the options describe a single, well-formed ballot mode
+func (o *ElectionOptions) validate() error {
+    switch o.Method {
+    case "", MethodPlurality:
+    case MethodInstantRunoff, MethodApproval, MethodScore, MethodSTV:
+        if o.encrypted() {
+            return errors.New("encrypted ballots can only be counted by plurality")
+        }
+    default:
+        return fmt.Errorf("unknown voting method %q", o.Method)
+    }
+
+    if o.Seats < 0 {
+        return errors.New("seats cannot be negative")
+    }
+    if o.Endorsements < 0 {
+        return errors.New("endorsements cannot be negative")
+    }
+    if o.Seats > 1 && o.Method != MethodSTV {
+        return errors.New("only stv elections can fill more than one seat")
+    }
+    if o.MaxScore != 0 {
+        if o.Method != MethodScore {
+            return errors.New("a maximum score applies only to score elections")
+        }
+        if o.MaxScore < 1 || o.MaxScore > maxMaxScore {
+            return fmt.Errorf("maximum score must be between 1 and %d", maxMaxScore)
+        }
+    }
+
+    if !o.encrypted() {
+        if o.Threshold != 0 {
+            return errors.New("a threshold requires trustees")
+        }
+        return nil
+    }
+    if o.SecretBallot {
+        return errors.New("an election cannot take both secret and encrypted ballots")
+    }
+    seen := make(map[string]bool)
+    for _, trustee := range o.Trustees {
+        if err := ValidatePublicKey(trustee); err != nil {
+            return fmt.Errorf("trustee %q: %v", trustee, err)
+        }
+        if seen[trustee] {
+            return fmt.Errorf("trustee %q listed twice", trustee)
+        }
+        seen[trustee] = true
+    }
+    if o.Threshold < 1 || o.Threshold > len(o.Trustees) {
+        return fmt.Errorf("threshold must be between 1 and the %d trustees", len(o.Trustees))
+    }
+    return nil
+}
+
+// method returns the voting method, defaulting to plurality
+func (o *ElectionOptions) method() string {
+    if o.Method == "" {
+        return MethodPlurality
+    }
+    return o.Method
+}
+
+// encrypted reports whether the election takes encrypted ballots
+func (o *ElectionOptions) encrypted() bool {
+    return len(o.Trustees) > 0
+}
+
+// trusteeIndex returns the position of key among the trustees, or -1
+func (o *ElectionOptions) trusteeIndex(key string) int {
+    for i, trustee := range o.Trustees {
+        if trustee == key {
+            return i
+        }
+    }
+    return -1
+}
+
+// finished reports whether the election has completed or been cancelled
+func (e *Election) finished() bool {
+    return e.Status == Completed || e.Status == Cancelled
+}
+
+// nominating reports whether candidates may register: during the
+// nomination phase, or while voting if the election has none
+func (e *Election) nominating() bool {
+    if e.NominationEndDate != 0 || e.NominationEndHeight != 0 {
+        return e.Status == Nominating
+    }
+    return e.Status == InProgress
+}
+
+// hasVoted reports whether a citizen's open or revealed ballot is recorded
+func (e *Election) hasVoted(citizenPublicKey string) bool {
+    if _, voted := e.Votes[citizenPublicKey]; voted {
+        return true
+    }
+    if _, ranked := e.Rankings[citizenPublicKey]; ranked {
+        return true
+    }
+    if _, approved := e.Approvals[citizenPublicKey]; approved {
+        return true
+    }
+    _, scored := e.Scores[citizenPublicKey]
+    return scored
+}
+
+// hasCandidate reports whether candidateID is registered in the election
+func (e *Election) hasCandidate(candidateID string) bool {
+    for _, candidate := range e.Candidates {
+        if candidate.ID == candidateID {
+            return true
+        }
+    }
+    return false
+}
+
+// clone returns a deep copy of the election system bound to registry
+func (es *ElectionSystem) clone(registry *CitizenRegistry) *ElectionSystem {
+    es.mu.RLock()
+    defer es.mu.RUnlock()
+
+    system := &ElectionSystem{
+        elections:       make(map[string]*Election, len(es.elections)),
+        order:           append([]string(nil), es.order...),
+        delegations:     make(map[string]Delegation, len(es.delegations)),
+        citizenRegistry: registry,
+    }
+    for scope, delegation := range es.delegations {
+        system.delegations[scope] = delegation
+    }
+    for id, election := range es.elections {
+        // Finished elections are never modified again, so they can be shared
+        if !election.finished() {
+            election = election.clone()
+        }
+        system.elections[id] = election
+    }
+    return system
+}
+
+// clone returns a deep copy of the election
+func (e *Election) clone() *Election {
+    copied := *e
+    copied.Candidates = make([]Candidate, len(e.Candidates))
+    copy(copied.Candidates, e.Candidates)
+    copied.Votes = make(map[string]string, len(e.Votes))
+    for voter, candidateID := range e.Votes {
+        copied.Votes[voter] = candidateID
+    }
+    if e.Commitments != nil {
+        copied.Commitments = make(map[string]string, len(e.Commitments))
+        for voter, commitment := range e.Commitments {
+            copied.Commitments[voter] = commitment
+        }
+    }
+    copied.Trustees = append([]string(nil), e.Trustees...)
+    copied.VerificationKeys = append([]string(nil), e.VerificationKeys...)
+    copied.EncryptedTally = append([]Ciphertext(nil), e.EncryptedTally...)
+    copied.TrusteeCommitments = copyListMap(e.TrusteeCommitments)
+    copied.DecryptionShares = copyListMap(e.DecryptionShares)
+    if e.Rankings != nil {
+        // Rankings are never modified once cast
+        copied.Rankings = make(map[string][]string, len(e.Rankings))
+        for voter, ranking := range e.Rankings {
+            copied.Rankings[voter] = ranking
+        }
+    }
+    copied.Approvals = copyListMap(e.Approvals)
+    if e.Scores != nil {
+        // Scores are never modified once cast
+        copied.Scores = make(map[string]map[string]int, len(e.Scores))
+        for voter, scores := range e.Scores {
+            copied.Scores[voter] = scores
+        }
+    }
+    if e.Ballots != nil {
+        copied.Ballots = make(map[string]string, len(e.Ballots))
+        for voter, txID := range e.Ballots {
+            copied.Ballots[voter] = txID
+        }
+    }
+    if e.Delegations != nil {
+        copied.Delegations = make(map[string]string, len(e.Delegations))
+        for delegator, delegate := range e.Delegations {
+            copied.Delegations[delegator] = delegate
+        }
+    }
+    if e.Winner != nil {
+        winner := *e.Winner
+        copied.Winner = &winner
+    }
+    copied.Winners = append([]Candidate(nil), e.Winners...)
+    copied.Choices = append([]string(nil), e.Choices...)
+    copied.Withdrawn = append([]Candidate(nil), e.Withdrawn...)
+    if e.Nominations != nil {
+        // Nominations collect endorsements in place
+        copied.Nominations = make([]Candidate, len(e.Nominations))
+        for i, nominee := range e.Nominations {
+            nominee.Endorsers = append([]string(nil), nominee.Endorsers...)
+            copied.Nominations[i] = nominee
+        }
+    }
+    return &copied
+}
+
+// copyListMap copies a map whose values are never modified in place
+func copyListMap(m map[string][]string) map[string][]string {
+    if m == nil {
+        return nil
+    }
+    copied := make(map[string][]string, len(m))
+    for key, values := range m {
+        copied[key] = values
+    }
+    return copied
 }
 
 func generateCandidateID(name, publicKey string) string {
@@ -0,0 +1,289 @@
+package blockchain
+
+import (
+    "slices"
+    "testing"
+)
+
+func TestRevealRequiresStanding(t *testing.T) {
+    tests := []struct {
+        name   string
+        change string // status change applied between commit and reveal
+        ok     bool
+    }{
+        {"approved", "", true},
+        {"suspended", TxCitizenSuspension, false},
+        {"revoked", TxCitizenRevocation, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestState(t, RegistryPolicy{})
+            voter, voterID := s.citizen("Voter")
+            id := s.startElection(ElectionOptions{SecretBallot: true})
+            _, candidateID := s.candidate(id, "Candidate")
+            s.advance(10)
+
+            ballot := Ballot{CandidateID: candidateID}
+            commitment, salt, err := NewBallot(id, voter.Public, MethodPlurality, &ballot)
+            if err != nil {
+                t.Fatal(err)
+            }
+            s.mustApply(signedBy(t, voter)(NewVoteCommitTx(id, voter.Public, commitment, s.ctx.Timestamp)))
+            if tt.change != "" {
+                s.mustApply(signedBy(t, s.admin)(NewCitizenStatusChangeTx(s.admin.Public, tt.change, voterID, "Under investigation", false, s.ctx.Timestamp)))
+            }
+            s.mustApply(signedBy(t, s.admin)(NewVotingCloseTx(s.admin.Public, id, s.ctx.Timestamp)))
+
+            err = s.apply(signedBy(t, voter)(NewVoteRevealTx(id, voter.Public, ballot, salt, s.ctx.Timestamp)))
+            if (err == nil) != tt.ok {
+                t.Fatalf("reveal error = %v, want ok = %v", err, tt.ok)
+            }
+            want := 0
+            if tt.ok {
+                want = 1
+            }
+            if got := len(s.election(id).Votes); got != want {
+                t.Errorf("%d ballots revealed, want %d", got, want)
+            }
+        })
+    }
+}
+
+func TestRevealMustOpenCommitment(t *testing.T) {
+    tests := []struct {
+        name  string
+        close bool // whether voting closes before the reveal
+        // change alters the revealed ballot and returns the revealed salt
+        change func(ballot *Ballot, salt, other string) string
+        ok     bool
+    }{
+        {"matching reveal", true, func(ballot *Ballot, salt, other string) string { return salt }, true},
+        {"before voting closes", false, func(ballot *Ballot, salt, other string) string { return salt }, false},
+        {"wrong salt", true, func(ballot *Ballot, salt, other string) string { return salt[:len(salt)-2] + "00" }, false},
+        {"short salt", true, func(ballot *Ballot, salt, other string) string { return salt[:8] }, false},
+        {"other candidate", true, func(ballot *Ballot, salt, other string) string {
+            ballot.CandidateID = other
+            return salt
+        }, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestState(t, RegistryPolicy{})
+            voter, _ := s.citizen("Voter")
+            id := s.startElection(ElectionOptions{SecretBallot: true})
+            _, first := s.candidate(id, "First")
+            _, second := s.candidate(id, "Second")
+            s.advance(10)
+
+            ballot := Ballot{CandidateID: first}
+            commitment, salt, err := NewBallot(id, voter.Public, MethodPlurality, &ballot)
+            if err != nil {
+                t.Fatal(err)
+            }
+            s.mustApply(signedBy(t, voter)(NewVoteCommitTx(id, voter.Public, commitment, s.ctx.Timestamp)))
+            if tt.close {
+                s.mustApply(signedBy(t, s.admin)(NewVotingCloseTx(s.admin.Public, id, s.ctx.Timestamp)))
+            }
+
+            salt = tt.change(&ballot, salt, second)
+            err = s.apply(signedBy(t, voter)(NewVoteRevealTx(id, voter.Public, ballot, salt, s.ctx.Timestamp)))
+            if (err == nil) != tt.ok {
+                t.Fatalf("reveal error = %v, want ok = %v", err, tt.ok)
+            }
+        })
+    }
+}
+
+func TestSecretBallotCountsRevealedBallots(t *testing.T) {
+    s := newTestState(t, RegistryPolicy{})
+    alice, _ := s.citizen("Alice")
+    bob, _ := s.citizen("Bob")
+    id := s.startElection(ElectionOptions{SecretBallot: true})
+    _, first := s.candidate(id, "First")
+    _, second := s.candidate(id, "Second")
+    s.advance(10)
+
+    commit := func(voter testKey, candidateID string) (Ballot, string) {
+        ballot := Ballot{CandidateID: candidateID}
+        commitment, salt, err := NewBallot(id, voter.Public, MethodPlurality, &ballot)
+        if err != nil {
+            t.Fatal(err)
+        }
+        s.mustApply(signedBy(t, voter)(NewVoteCommitTx(id, voter.Public, commitment, s.ctx.Timestamp)))
+        return ballot, salt
+    }
+    aliceBallot, aliceSalt := commit(alice, first)
+    commit(bob, second)
+
+    if err := s.apply(signedBy(t, alice)(NewVoteTx(id, alice.Public, first, s.ctx.Timestamp))); err == nil {
+        t.Error("open ballot was accepted in a secret ballot election")
+    }
+    if err := s.apply(signedBy(t, alice)(NewVoteCommitTx(id, alice.Public, BallotCommitment(id, alice.Public, second, aliceSalt), s.ctx.Timestamp))); err == nil {
+        t.Error("second commitment was accepted")
+    }
+    if len(s.election(id).Votes) != 0 {
+        t.Error("votes were visible before voting closed")
+    }
+
+    s.mustApply(signedBy(t, s.admin)(NewVotingCloseTx(s.admin.Public, id, s.ctx.Timestamp)))
+    s.mustApply(signedBy(t, alice)(NewVoteRevealTx(id, alice.Public, aliceBallot, aliceSalt, s.ctx.Timestamp)))
+    if err := s.apply(signedBy(t, alice)(NewVoteRevealTx(id, alice.Public, aliceBallot, aliceSalt, s.ctx.Timestamp))); err == nil {
+        t.Error("ballot was revealed twice")
+    }
+    s.mustApply(signedBy(t, s.admin)(NewElectionEndTx(s.admin.Public, id, s.ctx.Timestamp)))
+
+    election := s.election(id)
+    if election.Winner == nil || election.Winner.ID != first {
+        t.Fatalf("winner = %v, want the first candidate", election.Winner)
+    }
+    if election.Turnout != 1 {
+        t.Errorf("turnout = %d, want the one revealed ballot", election.Turnout)
+    }
+}
+
+func TestElectionPhases(t *testing.T) {
+    const day = secondsPerDay
+    type step struct {
+        seconds int64 // time to the next block; 0 in the first step stays in the starting block
+        status  ElectionStatus
+    }
+    tests := []struct {
+        name    string
+        days    int
+        options ElectionOptions
+        steps   []step
+    }{
+        {
+            name:  "voting closes at the end date",
+            days:  7,
+            steps: []step{{7*day - 1, InProgress}, {1, Completed}},
+        },
+        {
+            name:    "nomination opens voting",
+            days:    7,
+            options: ElectionOptions{NominationDays: 2},
+            steps:   []step{{0, Nominating}, {2 * day, InProgress}, {7*day - 1, InProgress}, {1, Completed}},
+        },
+        {
+            name:    "reveal phase expires",
+            days:    7,
+            options: ElectionOptions{SecretBallot: true, TallyDays: 1},
+            steps:   []step{{7 * day, Revealing}, {day - 1, Revealing}, {1, Completed}},
+        },
+        {
+            name:    "reveal phase without a deadline",
+            days:    7,
+            options: ElectionOptions{SecretBallot: true},
+            steps:   []step{{7 * day, Revealing}, {365 * day, Revealing}},
+        },
+        {
+            name:    "one block passes every deadline",
+            days:    7,
+            options: ElectionOptions{SecretBallot: true, NominationDays: 1, TallyDays: 1},
+            steps:   []step{{30 * day, Completed}},
+        },
+        {
+            name:    "scheduled in blocks",
+            options: ElectionOptions{NominationBlocks: 1, VotingBlocks: 2},
+            steps:   []step{{0, Nominating}, {day, InProgress}, {day, InProgress}, {1, Completed}},
+        },
+        {
+            name:    "blocks ignore time",
+            options: ElectionOptions{VotingBlocks: 2},
+            steps:   []step{{365 * day, InProgress}, {1, Completed}},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestState(t, RegistryPolicy{})
+            tx := signedBy(t, s.admin)(NewElectionStartTx(s.admin.Public, "General election", tt.days, tt.options, s.ctx.Timestamp))
+            s.mustApply(tx)
+            for i, step := range tt.steps {
+                if i > 0 || step.seconds > 0 {
+                    s.advance(step.seconds)
+                }
+                if got := s.election(tx.ID).Status; got != step.status {
+                    t.Fatalf("step %d: status = %v, want %v", i, got, step.status)
+                }
+            }
+        })
+    }
+}
+
+func TestVotingClosesAtEndDate(t *testing.T) {
+    s := newTestState(t, RegistryPolicy{})
+    alice, _ := s.citizen("Alice")
+    bob, _ := s.citizen("Bob")
+    id := s.startElection(ElectionOptions{})
+    _, candidateID := s.candidate(id, "Candidate")
+    election := s.election(id)
+
+    s.advance(election.EndDate - s.ctx.Timestamp - 1)
+    s.mustApply(signedBy(t, alice)(NewVoteTx(id, alice.Public, candidateID, s.ctx.Timestamp)))
+
+    s.advance(1)
+    if err := s.apply(signedBy(t, bob)(NewVoteTx(id, bob.Public, candidateID, s.ctx.Timestamp))); err == nil {
+        t.Error("vote was accepted after the end date")
+    }
+    election = s.election(id)
+    if election.Status != Completed || election.Winner == nil || election.Winner.ID != candidateID {
+        t.Errorf("status = %v, winner = %v, want completed with the candidate", election.Status, election.Winner)
+    }
+    if err := s.apply(signedBy(t, s.admin)(NewElectionEndTx(s.admin.Public, id, s.ctx.Timestamp))); err == nil {
+        t.Error("completed election was ended again")
+    }
+}
+
+func TestConcurrentElections(t *testing.T) {
+    s := newTestState(t, RegistryPolicy{})
+    voter, _ := s.citizen("Voter")
+    first := s.startElection(ElectionOptions{})
+    _, firstCandidate := s.candidate(first, "First")
+    s.advance(10)
+    second := s.startElection(ElectionOptions{})
+    _, secondCandidate := s.candidate(second, "Second")
+    s.advance(10)
+
+    if err := s.apply(signedBy(t, voter)(NewVoteTx(first, voter.Public, secondCandidate, s.ctx.Timestamp))); err == nil {
+        t.Error("vote for another election's candidate was accepted")
+    }
+    s.mustApply(signedBy(t, voter)(NewVoteTx(first, voter.Public, firstCandidate, s.ctx.Timestamp)))
+    s.mustApply(signedBy(t, voter)(NewVoteTx(second, voter.Public, secondCandidate, s.ctx.Timestamp)))
+
+    if current := s.electionSystem.GetCurrentElection(); current == nil || current.ID != second {
+        t.Errorf("current election = %v, want the second", current)
+    }
+    s.mustApply(signedBy(t, s.admin)(NewElectionEndTx(s.admin.Public, second, s.ctx.Timestamp)))
+    if current := s.electionSystem.GetCurrentElection(); current == nil || current.ID != first {
+        t.Errorf("current election = %v, want the first once the second ended", current)
+    }
+
+    tests := []struct {
+        name     string
+        statuses []ElectionStatus
+        want     []string
+    }{
+        {"every election", nil, []string{first, second}},
+        {"in progress", []ElectionStatus{InProgress}, []string{first}},
+        {"completed", []ElectionStatus{Completed}, []string{second}},
+        {"either", []ElectionStatus{Completed, InProgress}, []string{first, second}},
+        {"none match", []ElectionStatus{Cancelled}, []string{}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got := []string{}
+            for _, election := range s.electionSystem.GetElections(tt.statuses...) {
+                got = append(got, election.ID)
+            }
+            if !slices.Equal(got, tt.want) {
+                t.Errorf("elections = %v, want %v", got, tt.want)
+            }
+        })
+    }
+
+    for id, candidateID := range map[string]string{first: firstCandidate, second: secondCandidate} {
+        if votes := s.election(id).Votes; len(votes) != 1 || votes[voter.Public] != candidateID {
+            t.Errorf("votes in %s = %v, want the voter's one vote", id, votes)
+        }
+    }
+}
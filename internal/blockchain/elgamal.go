@@ -0,0 +1,562 @@
+package blockchain
+
+import (
+    "crypto/rand"
+    "crypto/sha256"
+    "errors"
+    "fmt"
+    "math/big"
+)
+
+// Encrypted elections use exponential ElGamal in a Schnorr group: p is a
+// 2048-bit prime, q a 256-bit prime dividing p-1, and g = 2^((p-1)/q) mod p
+// generates the subgroup of order q. p and q were generated as DSA
+// L2048N256 parameters; g can be rederived from them.
+//
+// A vote m is encrypted under the election key Y as (g^r, g^m Y^r), so
+// multiplying ciphertexts adds the votes inside them. Group elements and
+// scalars are exchanged as hex strings.
+var (
+    groupP = mustParseHex("e428601d5a9c5c43b8f31e717c54ec5c23ea96e5d6c824340754f46bdd453c4ee91188ffcdc72486db0a6cb96bcdfd60742ed304ad8856d9de57fc83460cecfabb7b0c76ff0857edcd06f84f17c6670807e346df218f8bfe7a6ea187225e3d545ac0e99fb795b1475a4d3cd60ec35db4360cbfe4d1a3668f81c5575f5af3ae311591e1768c21347f81d65fd88606c64a3d0aeea133004198fd135ca6c5973397459e0a9201a9fca3bbbcf13fa271a70af00634a559c4502083c17e24358a29e5c0a0f0a6051df7369f888655eb7c1cc41dd73459f3a79920cbf28fe2ad396975080213587b664900586420fad2185b2c97dcaa843bfb26c6af8fb372806b87a7")
+    groupQ = mustParseHex("a7c92ea7fe06a080dfcd0134f0788ceb8af2a8aee125b0e3692fea76039279cd")
+    groupG = mustParseHex("396e4411024542888a3769be45af6b36ebcf075ded7b746560d0a5458da9ac41c7e77c887121460269c3239883810ec6587135a552b8426ef85e2135877ea291d23a93243f7e973ce4b0db11e17261d1447bd402ed0c71f943249dd099ec7ab14008c2120c37e781fc5f3cd3a22d8ca77de986a368679c5b1367b331f48d936f875f2d410ad69ce27192df2da9198ed8c238f258055d1093421c94ade4ce91785843b551a03209fa03e03ab8a95919ab4935471343cf794363e539e48318c59db56765ccd018d67fc555c5e2b6f974b9a0f51a0f1e6214717d13b3aa1faa6afa778c7a44e846391d6b59473359caca5bab3c761960d7cd789b71ff4295c76c76")
+)
+
+// proofDomain separates the challenges of election proofs from every other hash
+const proofDomain = "virtual-ethiopia/election-proof"
+
+// Ciphertext is an exponential ElGamal encryption (g^r, g^m Y^r)
+type Ciphertext struct {
+    A string `json:"a"`
+    B string `json:"b"`
+}
+
+// Proof is a non-interactive Chaum-Pedersen proof that for one of several
+// values y_i, log_g1(x) = log_g2(y_i), without revealing which. With a single
+// value it proves two discrete logarithms equal; with g1 = g2 it is a Schnorr
+// proof of knowledge.
+type Proof struct {
+    Challenges []string `json:"challenges"`
+    Responses  []string `json:"responses"`
+}
+
+// EncryptedBallot holds one ciphertext per candidate, in registration
+// order, encrypting 1 for the chosen candidate and 0 for the rest
+type EncryptedBallot struct {
+    Choices  []Ciphertext `json:"choices"`
+    Proofs   []Proof      `json:"proofs"`   // each choice encrypts 0 or 1
+    SumProof Proof        `json:"sumProof"` // the choices add up to 1
+}
+
+// TrusteeDealing is a trustee's contribution to the election key: Feldman
+// commitments g^a_k to the coefficients of a secret polynomial f of degree
+// threshold-1. Trustee j's share of it is f(j), handed over privately.
+type TrusteeDealing struct {
+    Commitments []string `json:"commitments"`
+    Proof       Proof    `json:"proof"` // knowledge of a_0, which rules out rogue keys
+}
+
+// DecryptionShare is a trustee's partial decryption A^s of each ciphertext
+// of the encrypted tally, where s is the trustee's combined key share
+type DecryptionShare struct {
+    Values []string `json:"values"`
+    Proofs []Proof  `json:"proofs"` // each value used the share behind the trustee's verification key
+}
+
+func mustParseHex(s string) *big.Int {
+    n, ok := new(big.Int).SetString(s, 16)
+    if !ok {
+        panic("invalid group constant")
+    }
+    return n
+}
+
+func encodeInt(n *big.Int) string {
+    return n.Text(16)
+}
+
+// decodeElement parses a member of the order-q subgroup. Anything outside
+// it could leak information or break the soundness of the proofs.
+func decodeElement(s string) (*big.Int, error) {
+    n, ok := new(big.Int).SetString(s, 16)
+    if !ok || n.Sign() <= 0 || n.Cmp(groupP) >= 0 || new(big.Int).Exp(n, groupQ, groupP).Cmp(big.NewInt(1)) != 0 {
+        return nil, fmt.Errorf("invalid group element")
+    }
+    return n, nil
+}
+
+func decodeElements(values []string) ([]*big.Int, error) {
+    elements := make([]*big.Int, len(values))
+    for i, value := range values {
+        element, err := decodeElement(value)
+        if err != nil {
+            return nil, err
+        }
+        elements[i] = element
+    }
+    return elements, nil
+}
+
+// decodeScalar parses an exponent in [0, q)
+func decodeScalar(s string) (*big.Int, error) {
+    n, ok := new(big.Int).SetString(s, 16)
+    if !ok || n.Sign() < 0 || n.Cmp(groupQ) >= 0 {
+        return nil, fmt.Errorf("invalid scalar")
+    }
+    return n, nil
+}
+
+func randomScalar() (*big.Int, error) {
+    return rand.Int(rand.Reader, groupQ)
+}
+
+func groupExp(base, exponent *big.Int) *big.Int {
+    return new(big.Int).Exp(base, exponent, groupP)
+}
+
+func groupMul(a, b *big.Int) *big.Int {
+    product := new(big.Int).Mul(a, b)
+    return product.Mod(product, groupP)
+}
+
+func groupDiv(a, b *big.Int) *big.Int {
+    return groupMul(a, new(big.Int).ModInverse(b, groupP))
+}
+
+// encodedGenerator returns g^m for a small, possibly negative, m
+func encodedGenerator(m int64) *big.Int {
+    exponent := new(big.Int).Mod(big.NewInt(m), groupQ)
+    return groupExp(groupG, exponent)
+}
+
+func (c Ciphertext) decode() (a, b *big.Int, err error) {
+    if a, err = decodeElement(c.A); err != nil {
+        return nil, nil, err
+    }
+    if b, err = decodeElement(c.B); err != nil {
+        return nil, nil, err
+    }
+    return a, b, nil
+}
+
+func newCiphertext(a, b *big.Int) Ciphertext {
+    return Ciphertext{A: encodeInt(a), B: encodeInt(b)}
+}
+
+// proofContext binds a proof to the election and actor it was made for, so
+// it cannot be replayed by anyone else
+func proofContext(parts ...string) []byte {
+    var e encoder
+    e.string(proofDomain)
+    for _, part := range parts {
+        e.string(part)
+    }
+    return e.buf.Bytes()
+}
+
+func challenge(context []byte, values ...*big.Int) *big.Int {
+    var e encoder
+    e.bytes(context)
+    for _, value := range values {
+        e.bytes(value.Bytes())
+    }
+    hash := sha256.Sum256(e.buf.Bytes())
+    c := new(big.Int).SetBytes(hash[:])
+    return c.Mod(c, groupQ)
+}
+
+// proofCommitments returns a_i = g1^z x^-c and b_i = g2^z y^-c, which equal the
+// prover's g1^w and g2^w when z = w + c·secret
+func proofCommitments(g1, g2, x, y, c, z *big.Int) (*big.Int, *big.Int) {
+    a := groupDiv(groupExp(g1, z), groupExp(x, c))
+    b := groupDiv(groupExp(g2, z), groupExp(y, c))
+    return a, b
+}
+
+// transcript lists everything a proof's challenge is computed over
+func transcript(g1, g2, x *big.Int, ys, commitments []*big.Int) []*big.Int {
+    values := make([]*big.Int, 0, 3+len(ys)+len(commitments))
+    values = append(values, g1, g2, x)
+    values = append(values, ys...)
+    return append(values, commitments...)
+}
+
+// prove proves that log_g1(x) = log_g2(ys[index]) = secret
+func prove(context []byte, g1, g2, x *big.Int, ys []*big.Int, index int, secret *big.Int) (Proof, error) {
+    challenges := make([]*big.Int, len(ys))
+    responses := make([]*big.Int, len(ys))
+    commitments := make([]*big.Int, 0, 2*len(ys))
+
+    w, err := randomScalar()
+    if err != nil {
+        return Proof{}, err
+    }
+
+    // The statements that are false are simulated with challenges chosen in
+    // advance; the true one takes whatever challenge is left over
+    sum := new(big.Int)
+    for i, y := range ys {
+        if i == index {
+            commitments = append(commitments, groupExp(g1, w), groupExp(g2, w))
+            continue
+        }
+        if challenges[i], err = randomScalar(); err != nil {
+            return Proof{}, err
+        }
+        if responses[i], err = randomScalar(); err != nil {
+            return Proof{}, err
+        }
+        a, b := proofCommitments(g1, g2, x, y, challenges[i], responses[i])
+        commitments = append(commitments, a, b)
+        sum.Add(sum, challenges[i])
+    }
+
+    c := challenge(context, transcript(g1, g2, x, ys, commitments)...)
+    challenges[index] = new(big.Int).Mod(new(big.Int).Sub(c, sum), groupQ)
+    responses[index] = new(big.Int).Mul(challenges[index], secret)
+    responses[index].Add(responses[index], w).Mod(responses[index], groupQ)
+
+    proof := Proof{Challenges: make([]string, len(ys)), Responses: make([]string, len(ys))}
+    for i := range ys {
+        proof.Challenges[i] = encodeInt(challenges[i])
+        proof.Responses[i] = encodeInt(responses[i])
+    }
+    return proof, nil
+}
+
+// verify checks a proof made by prove
+func (p *Proof) verify(context []byte, g1, g2, x *big.Int, ys []*big.Int) error {
+    if len(p.Challenges) != len(ys) || len(p.Responses) != len(ys) {
+        return errors.New("proof has the wrong number of statements")
+    }
+
+    sum := new(big.Int)
+    commitments := make([]*big.Int, 0, 2*len(ys))
+    for i, y := range ys {
+        c, err := decodeScalar(p.Challenges[i])
+        if err != nil {
+            return err
+        }
+        z, err := decodeScalar(p.Responses[i])
+        if err != nil {
+            return err
+        }
+        a, b := proofCommitments(g1, g2, x, y, c, z)
+        commitments = append(commitments, a, b)
+        sum.Add(sum, c)
+    }
+
+    c := challenge(context, transcript(g1, g2, x, ys, commitments)...)
+    if sum.Mod(sum, groupQ).Cmp(c) != 0 {
+        return errors.New("invalid proof")
+    }
+    return nil
+}
+
+// proveEncrypts proves that (a, b), encrypted with randomness r under key,
+// holds allowed[index]
+func proveEncrypts(context []byte, key, a, b, r *big.Int, allowed []int64, index int) (Proof, error) {
+    return prove(context, groupG, key, a, plaintextCandidates(b, allowed), index, r)
+}
+
+// verifyEncrypts checks that (a, b) holds one of the allowed values
+func verifyEncrypts(context []byte, key, a, b *big.Int, allowed []int64, proof *Proof) error {
+    return proof.verify(context, groupG, key, a, plaintextCandidates(b, allowed))
+}
+
+// plaintextCandidates returns b / g^m for every allowed m; for the true m it
+// equals key^r, whose logarithm to key is the log of a to g
+func plaintextCandidates(b *big.Int, allowed []int64) []*big.Int {
+    ys := make([]*big.Int, len(allowed))
+    for i, m := range allowed {
+        ys[i] = groupDiv(b, encodedGenerator(m))
+    }
+    return ys
+}
+
+// EncryptBallot encrypts voterKey's vote for candidateID under the key of
+// an encrypted election
+func EncryptBallot(election *Election, voterKey, candidateID string) (*EncryptedBallot, error) {
+    key, err := decodeElement(election.PublicKey)
+    if err != nil {
+        return nil, fmt.Errorf("election key is not ready")
+    }
+
+    choice := -1
+    for i, candidate := range election.Candidates {
+        if candidate.ID == candidateID {
+            choice = i
+        }
+    }
+    if choice < 0 {
+        return nil, errors.New("invalid candidate")
+    }
+
+    ballot := &EncryptedBallot{}
+    sumA, sumB, sumR := big.NewInt(1), big.NewInt(1), new(big.Int)
+    for i := range election.Candidates {
+        var m int64
+        if i == choice {
+            m = 1
+        }
+        r, err := randomScalar()
+        if err != nil {
+            return nil, err
+        }
+        a := groupExp(groupG, r)
+        b := groupMul(encodedGenerator(m), groupExp(key, r))
+
+        proof, err := proveEncrypts(ballotContext(election.ID, voterKey, i), key, a, b, r, []int64{0, 1}, int(m))
+        if err != nil {
+            return nil, err
+        }
+        ballot.Choices = append(ballot.Choices, newCiphertext(a, b))
+        ballot.Proofs = append(ballot.Proofs, proof)
+
+        sumA, sumB = groupMul(sumA, a), groupMul(sumB, b)
+        sumR.Add(sumR, r).Mod(sumR, groupQ)
+    }
+
+    sumProof, err := proveEncrypts(ballotContext(election.ID, voterKey, -1), key, sumA, sumB, sumR, []int64{1}, 0)
+    if err != nil {
+        return nil, err
+    }
+    ballot.SumProof = sumProof
+    return ballot, nil
+}
+
+// verifyBallot checks that a ballot holds a single vote for one of
+// candidates candidates and returns its ciphertexts
+func verifyBallot(electionID, voterKey string, key *big.Int, candidates int, ballot *EncryptedBallot) ([][2]*big.Int, error) {
+    if len(ballot.Choices) != candidates || len(ballot.Proofs) != candidates {
+        return nil, fmt.Errorf("ballot must hold one choice for each of the %d candidates", candidates)
+    }
+
+    choices := make([][2]*big.Int, candidates)
+    sumA, sumB := big.NewInt(1), big.NewInt(1)
+    for i := range ballot.Choices {
+        a, b, err := ballot.Choices[i].decode()
+        if err != nil {
+            return nil, fmt.Errorf("choice %d: %v", i, err)
+        }
+        if err := verifyEncrypts(ballotContext(electionID, voterKey, i), key, a, b, []int64{0, 1}, &ballot.Proofs[i]); err != nil {
+            return nil, fmt.Errorf("choice %d: %v", i, err)
+        }
+        choices[i] = [2]*big.Int{a, b}
+        sumA, sumB = groupMul(sumA, a), groupMul(sumB, b)
+    }
+
+    if err := verifyEncrypts(ballotContext(electionID, voterKey, -1), key, sumA, sumB, []int64{1}, &ballot.SumProof); err != nil {
+        return nil, fmt.Errorf("ballot does not hold exactly one vote: %v", err)
+    }
+    return choices, nil
+}
+
+func ballotContext(electionID, voterKey string, choice int) []byte {
+    return proofContext("ballot", electionID, voterKey, fmt.Sprint(choice))
+}
+
+// DealTrusteeShares creates trusteeKey's dealing for an encrypted election
+// and the share of it for every trustee, in trustee order. Each share must
+// reach its trustee privately.
+func DealTrusteeShares(election *Election, trusteeKey string) (*TrusteeDealing, []string, error) {
+    if election.Threshold < 1 {
+        return nil, nil, errors.New("election has no trustees")
+    }
+
+    coefficients := make([]*big.Int, election.Threshold)
+    dealing := &TrusteeDealing{}
+    for k := range coefficients {
+        coefficient, err := randomScalar()
+        if err != nil {
+            return nil, nil, err
+        }
+        coefficients[k] = coefficient
+        dealing.Commitments = append(dealing.Commitments, encodeInt(groupExp(groupG, coefficient)))
+    }
+
+    commitment := groupExp(groupG, coefficients[0])
+    proof, err := prove(dealingContext(election.ID, trusteeKey), groupG, groupG, commitment, []*big.Int{commitment}, 0, coefficients[0])
+    if err != nil {
+        return nil, nil, err
+    }
+    dealing.Proof = proof
+
+    shares := make([]string, len(election.Trustees))
+    for j := range shares {
+        shares[j] = encodeInt(evaluatePolynomial(coefficients, int64(j+1)))
+    }
+    return dealing, shares, nil
+}
+
+// verifyDealing checks a trustee's dealing and returns its commitments
+func verifyDealing(electionID, trusteeKey string, threshold int, dealing *TrusteeDealing) ([]*big.Int, error) {
+    if len(dealing.Commitments) != threshold {
+        return nil, fmt.Errorf("dealing must commit to %d coefficients", threshold)
+    }
+    commitments, err := decodeElements(dealing.Commitments)
+    if err != nil {
+        return nil, err
+    }
+    if err := dealing.Proof.verify(dealingContext(electionID, trusteeKey), groupG, groupG, commitments[0], commitments[:1]); err != nil {
+        return nil, err
+    }
+    return commitments, nil
+}
+
+func dealingContext(electionID, trusteeKey string) []byte {
+    return proofContext("dealing", electionID, trusteeKey)
+}
+
+// evaluatePolynomial returns f(x) mod q
+func evaluatePolynomial(coefficients []*big.Int, x int64) *big.Int {
+    result := new(big.Int)
+    for k := len(coefficients) - 1; k >= 0; k-- {
+        result.Mul(result, big.NewInt(x))
+        result.Add(result, coefficients[k]).Mod(result, groupQ)
+    }
+    return result
+}
+
+// evaluateCommitments returns g^f(x) from the Feldman commitments to f
+func evaluateCommitments(commitments []*big.Int, x int64) *big.Int {
+    result := big.NewInt(1)
+    power := big.NewInt(1)
+    for _, commitment := range commitments {
+        result = groupMul(result, groupExp(commitment, power))
+        power = new(big.Int).Mod(new(big.Int).Mul(power, big.NewInt(x)), groupQ)
+    }
+    return result
+}
+
+// CombineTrusteeShares checks the shares trusteeKey received from every
+// trustee, keyed by dealer, against their published dealings and returns
+// the trustee's combined key share
+func CombineTrusteeShares(election *Election, trusteeKey string, shares map[string]string) (string, error) {
+    index := election.trusteeIndex(trusteeKey)
+    if index < 0 {
+        return "", errors.New("not a trustee of this election")
+    }
+    if election.PublicKey == "" {
+        return "", errors.New("election key is not ready")
+    }
+
+    combined := new(big.Int)
+    for _, dealer := range election.Trustees {
+        share, ok := shares[dealer]
+        if !ok {
+            return "", fmt.Errorf("missing the share dealt by %s", dealer)
+        }
+        value, err := decodeScalar(share)
+        if err != nil {
+            return "", fmt.Errorf("share dealt by %s: %v", dealer, err)
+        }
+        commitments, err := decodeElements(election.TrusteeCommitments[dealer])
+        if err != nil {
+            return "", err
+        }
+        if groupExp(groupG, value).Cmp(evaluateCommitments(commitments, int64(index+1))) != 0 {
+            return "", fmt.Errorf("share dealt by %s does not match its dealing", dealer)
+        }
+        combined.Add(combined, value).Mod(combined, groupQ)
+    }
+    return encodeInt(combined), nil
+}
+
+// DecryptTallyShare computes trusteeKey's partial decryption of the
+// encrypted tally with the trustee's combined key share
+func DecryptTallyShare(election *Election, trusteeKey, keyShare string) (*DecryptionShare, error) {
+    index := election.trusteeIndex(trusteeKey)
+    if index < 0 || index >= len(election.VerificationKeys) {
+        return nil, errors.New("not a trustee of this election")
+    }
+    secret, err := decodeScalar(keyShare)
+    if err != nil {
+        return nil, err
+    }
+    verificationKey, err := decodeElement(election.VerificationKeys[index])
+    if err != nil {
+        return nil, err
+    }
+    if groupExp(groupG, secret).Cmp(verificationKey) != 0 {
+        return nil, errors.New("key share does not match the trustee's verification key")
+    }
+
+    share := &DecryptionShare{}
+    for i, ciphertext := range election.EncryptedTally {
+        a, _, err := ciphertext.decode()
+        if err != nil {
+            return nil, err
+        }
+        value := groupExp(a, secret)
+        proof, err := prove(decryptionContext(election.ID, trusteeKey, i), groupG, a, verificationKey, []*big.Int{value}, 0, secret)
+        if err != nil {
+            return nil, err
+        }
+        share.Values = append(share.Values, encodeInt(value))
+        share.Proofs = append(share.Proofs, proof)
+    }
+    return share, nil
+}
+
+// verifyDecryptionShare checks a trustee's partial decryption of tally
+// against its verification key and returns the decoded values
+func verifyDecryptionShare(electionID, trusteeKey string, verificationKey *big.Int, tally [][2]*big.Int, share *DecryptionShare) ([]*big.Int, error) {
+    if len(share.Values) != len(tally) || len(share.Proofs) != len(tally) {
+        return nil, fmt.Errorf("decryption must cover all %d tally ciphertexts", len(tally))
+    }
+    values, err := decodeElements(share.Values)
+    if err != nil {
+        return nil, err
+    }
+    for i := range values {
+        if err := share.Proofs[i].verify(decryptionContext(electionID, trusteeKey, i), groupG, tally[i][0], verificationKey, values[i:i+1]); err != nil {
+            return nil, fmt.Errorf("decryption %d: %v", i, err)
+        }
+    }
+    return values, nil
+}
+
+func decryptionContext(electionID, trusteeKey string, i int) []byte {
+    return proofContext("decryption", electionID, trusteeKey, fmt.Sprint(i))
+}
+
+// lagrangeCoefficient returns the coefficient of trustee index in the
+// interpolation at zero over the trustee indexes in indexes (all 1-based)
+func lagrangeCoefficient(index int64, indexes []int64) *big.Int {
+    numerator, denominator := big.NewInt(1), big.NewInt(1)
+    for _, other := range indexes {
+        if other == index {
+            continue
+        }
+        numerator.Mul(numerator, big.NewInt(other)).Mod(numerator, groupQ)
+        difference := new(big.Int).Mod(big.NewInt(other-index), groupQ)
+        denominator.Mul(denominator, difference).Mod(denominator, groupQ)
+    }
+    return numerator.Mul(numerator, denominator.ModInverse(denominator, groupQ)).Mod(numerator, groupQ)
+}
+
+// decryptCount combines threshold partial decryptions, keyed by 1-based
+// trustee index, of the ciphertext (a, b) and recovers a count of at most max
+func decryptCount(b *big.Int, partials map[int64]*big.Int, max int) (int, error) {
+    indexes := make([]int64, 0, len(partials))
+    for index := range partials {
+        indexes = append(indexes, index)
+    }
+
+    // a^x = Π (a^s_j)^λ_j, so g^m = b / a^x
+    mask := big.NewInt(1)
+    for _, index := range indexes {
+        mask = groupMul(mask, groupExp(partials[index], lagrangeCoefficient(index, indexes)))
+    }
+    encoded := groupDiv(b, mask)
+
+    power := big.NewInt(1)
+    for m := 0; m <= max; m++ {
+        if power.Cmp(encoded) == 0 {
+            return m, nil
+        }
+        power = groupMul(power, groupG)
+    }
+    return 0, errors.New("decrypted count is out of range")
+}
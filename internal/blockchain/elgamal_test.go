@@ -0,0 +1,293 @@
+package blockchain
+
+import "testing"
+
+// encryptedElection is an encrypted election whose trustees have dealt,
+// with two candidates and three voters on its roll
+type encryptedElection struct {
+    *testState
+    id         string
+    trustees   []testKey
+    keyShares  []string // combined key share of each trustee
+    candidates []string
+    voters     []testKey
+}
+
+// newEncryptedElection starts an election with the given number of
+// trustees, any threshold of whom can decrypt, and has every trustee deal
+func newEncryptedElection(t *testing.T, trustees, threshold int) *encryptedElection {
+    t.Helper()
+    e := &encryptedElection{testState: newTestState(t, RegistryPolicy{})}
+    for _, name := range []string{"Alice", "Bob", "Carol"} {
+        voter, _ := e.citizen(name)
+        e.voters = append(e.voters, voter)
+    }
+    options := ElectionOptions{Threshold: threshold}
+    for i := 0; i < trustees; i++ {
+        key := newTestKey(t)
+        e.trustees = append(e.trustees, key)
+        options.Trustees = append(options.Trustees, key.Public)
+    }
+    e.id = e.startElection(options)
+    for _, name := range []string{"First", "Second"} {
+        _, candidateID := e.candidate(e.id, name)
+        e.candidates = append(e.candidates, candidateID)
+    }
+
+    // received[j] holds the shares trustee j received, by dealer
+    received := make([]map[string]string, trustees)
+    for j := range received {
+        received[j] = make(map[string]string)
+    }
+    for _, trustee := range e.trustees {
+        dealing, shares, err := DealTrusteeShares(e.election(e.id), trustee.Public)
+        if err != nil {
+            t.Fatal(err)
+        }
+        e.mustApply(signedBy(t, trustee)(NewTrusteeDealingTx(e.id, trustee.Public, *dealing, e.ctx.Timestamp)))
+        for j, share := range shares {
+            received[j][trustee.Public] = share
+        }
+    }
+    for j, trustee := range e.trustees {
+        keyShare, err := CombineTrusteeShares(e.election(e.id), trustee.Public, received[j])
+        if err != nil {
+            t.Fatal(err)
+        }
+        e.keyShares = append(e.keyShares, keyShare)
+    }
+    return e
+}
+
+// vote casts an encrypted ballot of voter for the candidate at index
+func (e *encryptedElection) vote(voter testKey, candidate int) error {
+    e.t.Helper()
+    ballot, err := EncryptBallot(e.election(e.id), voter.Public, e.candidates[candidate])
+    if err != nil {
+        e.t.Fatal(err)
+    }
+    return e.apply(signedBy(e.t, voter)(NewEncryptedVoteTx(e.id, voter.Public, *ballot, e.ctx.Timestamp)))
+}
+
+// decrypt submits the partial decryption of the trustee at index
+func (e *encryptedElection) decrypt(trustee int) error {
+    e.t.Helper()
+    share, err := DecryptTallyShare(e.election(e.id), e.trustees[trustee].Public, e.keyShares[trustee])
+    if err != nil {
+        e.t.Fatal(err)
+    }
+    key := e.trustees[trustee]
+    return e.apply(signedBy(e.t, key)(NewTallyDecryptionTx(e.id, key.Public, *share, e.ctx.Timestamp)))
+}
+
+func TestThresholdDecryption(t *testing.T) {
+    tests := []struct {
+        name      string
+        trustees  int
+        threshold int
+        decrypt   []int // trustees decrypting, in order
+        completed bool
+    }{
+        {"single trustee", 1, 1, []int{0}, true},
+        {"first two of three", 3, 2, []int{0, 1}, true},
+        {"last two of three", 3, 2, []int{2, 1}, true},
+        {"outer two of three", 3, 2, []int{0, 2}, true},
+        {"one of three", 3, 2, []int{1}, false},
+        {"every trustee required", 3, 3, []int{0, 1}, false},
+        {"three of five", 5, 3, []int{4, 0, 2}, true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            e := newEncryptedElection(t, tt.trustees, tt.threshold)
+            e.advance(10)
+            for i, candidate := range []int{0, 1, 0} {
+                if err := e.vote(e.voters[i], candidate); err != nil {
+                    t.Fatal(err)
+                }
+            }
+            e.mustApply(signedBy(t, e.admin)(NewElectionEndTx(e.admin.Public, e.id, e.ctx.Timestamp)))
+            for _, trustee := range tt.decrypt {
+                if err := e.decrypt(trustee); err != nil {
+                    t.Fatal(err)
+                }
+            }
+
+            election := e.election(e.id)
+            if completed := election.Status == Completed; completed != tt.completed {
+                t.Fatalf("status = %v, want completed = %v", election.Status, tt.completed)
+            }
+            if !tt.completed {
+                return
+            }
+            if got := []int{election.Candidates[0].VoteCount, election.Candidates[1].VoteCount}; got[0] != 2 || got[1] != 1 {
+                t.Errorf("counts = %v, want [2 1]", got)
+            }
+            if election.Winner == nil || election.Winner.ID != e.candidates[0] {
+                t.Errorf("winner = %v, want the first candidate", election.Winner)
+            }
+        })
+    }
+}
+
+func TestEncryptedElectionRejectsForgeries(t *testing.T) {
+    tests := []struct {
+        name string
+        act  func(t *testing.T, e *encryptedElection) error
+    }{
+        {"ballot cast for another voter", func(t *testing.T, e *encryptedElection) error {
+            alice, bob := e.voters[0], e.voters[1]
+            ballot, err := EncryptBallot(e.election(e.id), alice.Public, e.candidates[0])
+            if err != nil {
+                t.Fatal(err)
+            }
+            return e.apply(signedBy(t, bob)(NewEncryptedVoteTx(e.id, bob.Public, *ballot, e.ctx.Timestamp)))
+        }},
+        {"ballot with its choices swapped", func(t *testing.T, e *encryptedElection) error {
+            voter := e.voters[0]
+            ballot, err := EncryptBallot(e.election(e.id), voter.Public, e.candidates[0])
+            if err != nil {
+                t.Fatal(err)
+            }
+            ballot.Choices[0], ballot.Choices[1] = ballot.Choices[1], ballot.Choices[0]
+            return e.apply(signedBy(t, voter)(NewEncryptedVoteTx(e.id, voter.Public, *ballot, e.ctx.Timestamp)))
+        }},
+        {"ballot with a choice doubled", func(t *testing.T, e *encryptedElection) error {
+            voter := e.voters[0]
+            ballot, err := EncryptBallot(e.election(e.id), voter.Public, e.candidates[0])
+            if err != nil {
+                t.Fatal(err)
+            }
+            ballot.Choices[1], ballot.Proofs[1] = ballot.Choices[0], ballot.Proofs[0]
+            return e.apply(signedBy(t, voter)(NewEncryptedVoteTx(e.id, voter.Public, *ballot, e.ctx.Timestamp)))
+        }},
+        {"second ballot", func(t *testing.T, e *encryptedElection) error {
+            if err := e.vote(e.voters[0], 0); err != nil {
+                t.Fatal(err)
+            }
+            return e.vote(e.voters[0], 1)
+        }},
+        {"decryption copied from another trustee", func(t *testing.T, e *encryptedElection) error {
+            e.mustApply(signedBy(t, e.admin)(NewElectionEndTx(e.admin.Public, e.id, e.ctx.Timestamp)))
+            share, err := DecryptTallyShare(e.election(e.id), e.trustees[0].Public, e.keyShares[0])
+            if err != nil {
+                t.Fatal(err)
+            }
+            key := e.trustees[1]
+            return e.apply(signedBy(t, key)(NewTallyDecryptionTx(e.id, key.Public, *share, e.ctx.Timestamp)))
+        }},
+        {"second decryption", func(t *testing.T, e *encryptedElection) error {
+            e.mustApply(signedBy(t, e.admin)(NewElectionEndTx(e.admin.Public, e.id, e.ctx.Timestamp)))
+            if err := e.decrypt(0); err != nil {
+                t.Fatal(err)
+            }
+            return e.decrypt(0)
+        }},
+        {"decryption before voting ends", func(t *testing.T, e *encryptedElection) error {
+            return e.decrypt(0)
+        }},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            e := newEncryptedElection(t, 3, 2)
+            e.advance(10)
+            if err := e.vote(e.voters[2], 1); err != nil {
+                t.Fatal(err)
+            }
+            if err := tt.act(t, e); err == nil {
+                t.Error("forgery was accepted")
+            }
+        })
+    }
+}
+
+func TestCombineTrusteeSharesChecksDealings(t *testing.T) {
+    e := newEncryptedElection(t, 3, 2)
+    election := e.election(e.id)
+    _, shares, err := DealTrusteeShares(election, e.trustees[0].Public)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // A share from a dealing other than the published one is caught
+    received := make(map[string]string)
+    for _, trustee := range e.trustees {
+        received[trustee.Public] = shares[1]
+    }
+    if _, err := CombineTrusteeShares(election, e.trustees[1].Public, received); err == nil {
+        t.Error("shares that do not match the dealings were combined")
+    }
+    if _, err := DecryptTallyShare(election, e.trustees[1].Public, e.keyShares[0]); err == nil {
+        t.Error("another trustee's key share was accepted")
+    }
+}
+
+func TestFailedDecryptionLeavesNoShare(t *testing.T) {
+    e := newEncryptedElection(t, 3, 2)
+    e.advance(10)
+    for i, candidate := range []int{0, 1, 0} {
+        if err := e.vote(e.voters[i], candidate); err != nil {
+            t.Fatal(err)
+        }
+    }
+    e.mustApply(signedBy(t, e.admin)(NewElectionEndTx(e.admin.Public, e.id, e.ctx.Timestamp)))
+    if err := e.decrypt(0); err != nil {
+        t.Fatal(err)
+    }
+
+    // A recorded share that no longer decodes makes the decryption
+    // completing the tally fail
+    election := e.election(e.id)
+    recorded := election.DecryptionShares[e.trustees[0].Public]
+    election.DecryptionShares[e.trustees[0].Public] = []string{"zz", "zz"}
+    if err := e.decrypt(1); err == nil {
+        t.Fatal("decryption with a corrupt share succeeded")
+    }
+    if _, recorded := election.DecryptionShares[e.trustees[1].Public]; recorded || election.Status != Tallying {
+        t.Fatalf("failed decryption changed the election: share recorded = %v, status = %v", recorded, election.Status)
+    }
+
+    election.DecryptionShares[e.trustees[0].Public] = recorded
+    if err := e.decrypt(1); err != nil {
+        t.Fatal(err)
+    }
+    if election.Status != Completed || len(election.DecryptionShares) != 2 {
+        t.Errorf("status = %v with %d shares, want completed with 2", election.Status, len(election.DecryptionShares))
+    }
+}
+
+func TestEndingUndecryptedElectionCancelsIt(t *testing.T) {
+    tests := []struct {
+        name    string
+        decrypt []int // trustees decrypting before the admin ends the election
+    }{
+        {"no decryptions", nil},
+        {"too few decryptions", []int{1}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            e := newEncryptedElection(t, 3, 2)
+            e.advance(10)
+            if err := e.vote(e.voters[0], 0); err != nil {
+                t.Fatal(err)
+            }
+            e.mustApply(signedBy(t, e.admin)(NewElectionEndTx(e.admin.Public, e.id, e.ctx.Timestamp)))
+            if status := e.election(e.id).Status; status != Tallying {
+                t.Fatalf("status after voting = %v, want tallying", status)
+            }
+            for _, trustee := range tt.decrypt {
+                if err := e.decrypt(trustee); err != nil {
+                    t.Fatal(err)
+                }
+            }
+
+            e.mustApply(signedBy(t, e.admin)(NewElectionEndTx(e.admin.Public, e.id, e.ctx.Timestamp)))
+            election := e.election(e.id)
+            if election.Status != Cancelled || election.Winner != nil {
+                t.Fatalf("status = %v, winner = %v, want cancelled without a winner", election.Status, election.Winner)
+            }
+            if err := e.decrypt(0); err == nil {
+                t.Error("decryption accepted after the election was cancelled")
+            }
+        })
+    }
+}
@@ -0,0 +1,132 @@
+package blockchain
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "testing"
+)
+
+// Golden vectors pinning the canonical encodings. Any change that alters
+// these hashes would split the network or orphan stored chains.
+const (
+    vectorPrivateKey  = "0101010101010101010101010101010101010101010101010101010101010101"
+    vectorTxID        = "1c0f4d24c59fc1065b309cbc701e60ef54e86a9a5b11ccc8c2d599e0d819416f"
+    vectorTxSignature = "a69e6a74578a77291fe4de5b64c014c61c1eb02bb242b759d39f1cfa099134132eececc540746025de5c69aa1659324f9a9ff0d9d59d200857d42cd0b7ad7805"
+
+    // Block version 1 holding the golden transaction
+    vectorBlockHashV1 = "8834b31adfebd016d6446f16199762d6a415d977a0fe4638f7aff4cc4f72ff3f"
+
+    // Block version 2 holding the golden transaction and two more, so that
+    // the Merkle tree has an unbalanced right edge
+    vectorTxRootV2    = "5e4153a506f56c82a2a5dfaf6ac7d5ec5c57eef76e25801bab8c9a1743ee2a0d"
+    vectorBlockHashV2 = "d197b55a8a3eec42ca0b0a48cb62c7c255147c5babcc4fb98841e8d4607207ba"
+)
+
+// vectorTransaction builds the golden transaction with the given nonce
+func vectorTransaction(t *testing.T, nonce string) *Transaction {
+    t.Helper()
+    publicKey, err := PublicKeyFromPrivate(vectorPrivateKey)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // The data covers strings, escaping, integers and fractions, which must
+    // all encode the same after a JSON round-trip
+    tx := NewTransactionAt(publicKey, "ELECTION", 12.5, 1704067200)
+    tx.Data = map[string]interface{}{
+        "memo":  "Addis Ababa <ET> & \"friends\"",
+        "count": 3,
+        "ratio": 0.1,
+        "list":  []interface{}{"b", "a"},
+    }
+    tx.SetNonce(nonce)
+    if err := tx.Sign(vectorPrivateKey); err != nil {
+        t.Fatal(err)
+    }
+    return tx
+}
+
+// vectorBlock builds the golden block of the given version
+func vectorBlock(t *testing.T, version int) *Block {
+    t.Helper()
+    nonces := []string{"golden"}
+    if version >= 2 {
+        nonces = append(nonces, "golden-2", "golden-3")
+    }
+
+    transactions := make([]Transaction, 0, len(nonces))
+    for _, nonce := range nonces {
+        transactions = append(transactions, *vectorTransaction(t, nonce))
+    }
+
+    prevHash := sha256.Sum256([]byte("golden parent"))
+    block := newBlockAt(1, 1704067205, transactions, prevHash[:])
+    block.Version = version
+    block.Proposer = transactions[0].From
+    if version < 2 {
+        block.TxRoot = nil
+        block.Hash = block.calculateHash()
+    } else {
+        block.seal()
+    }
+    return block
+}
+
+// roundTrip returns block after the JSON round-trip every block takes
+// through storage and the network
+func roundTrip(t *testing.T, block *Block) *Block {
+    t.Helper()
+    raw, err := json.Marshal(block)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var decoded Block
+    if err := json.Unmarshal(raw, &decoded); err != nil {
+        t.Fatal(err)
+    }
+    return &decoded
+}
+
+func TestGoldenEncoding(t *testing.T) {
+    tests := []struct {
+        name      string
+        version   int
+        roundTrip bool
+        txRoot    string
+        blockHash string
+    }{
+        {"version 1", 1, false, "", vectorBlockHashV1},
+        {"version 1 after JSON", 1, true, "", vectorBlockHashV1},
+        {"version 2", 2, false, vectorTxRootV2, vectorBlockHashV2},
+        {"version 2 after JSON", 2, true, vectorTxRootV2, vectorBlockHashV2},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            block := vectorBlock(t, tt.version)
+            if tt.roundTrip {
+                block = roundTrip(t, block)
+            }
+
+            tx := &block.Transactions[0]
+            if id := calculateTransactionHash(tx); id != vectorTxID {
+                t.Errorf("transaction ID = %s, want %s", id, vectorTxID)
+            }
+            if tx.Signature != vectorTxSignature {
+                t.Errorf("transaction signature = %s, want %s", tx.Signature, vectorTxSignature)
+            }
+            if !tx.VerifySignature() {
+                t.Error("golden transaction signature does not verify")
+            }
+            if err := block.verifyContents(); err != nil {
+                t.Error(err)
+            }
+            if root := hex.EncodeToString(block.TxRoot); root != tt.txRoot {
+                t.Errorf("transaction root = %s, want %s", root, tt.txRoot)
+            }
+            if hash := hex.EncodeToString(block.Hash); hash != tt.blockHash {
+                t.Errorf("block hash = %s, want %s", hash, tt.blockHash)
+            }
+        })
+    }
+}
@@ -0,0 +1,149 @@
+package blockchain
+
+import (
+    "crypto/sha256"
+    "encoding/json"
+    "fmt"
+    "os"
+)
+
+// Genesis describes the initial state every node of a network must agree on
+type Genesis struct {
+    Timestamp  int64    `json:"timestamp"`
+    Validators []string `json:"validators"` // validator public keys in proposer order
+    Admins     []string `json:"admins"`     // public keys allowed to approve citizens and run elections
+    RegistryPolicy
+}
+
+// RegistryPolicy sets how the citizen registry admits citizens and changes
+// its admins. Zero values select the defaults.
+type RegistryPolicy struct {
+    // AdminThreshold is the number of admins who must approve adding or
+    // removing an admin, a majority of them if zero. ApprovalThreshold is
+    // the number who must approve a citizen, one if zero. Neither may
+    // exceed the number of genesis admins; later both are capped at the
+    // number of admins at the time.
+    AdminThreshold    int `json:"adminThreshold,omitempty"`
+    ApprovalThreshold int `json:"approvalThreshold,omitempty"`
+
+    // A pending citizen vouched for by SponsorThreshold approved citizens
+    // is approved without an admin; zero leaves approval to the admins.
+    // Each citizen may vouch for SponsorLimit applicants (3 by default) in
+    // any SponsorPeriodDays days (30 by default), and may not vouch for
+    // SponsorPenaltyDays days (365 by default) after someone they vouched
+    // for is revoked for fraud.
+    SponsorThreshold   int `json:"sponsorThreshold,omitempty"`
+    SponsorLimit       int `json:"sponsorLimit,omitempty"`
+    SponsorPeriodDays  int `json:"sponsorPeriodDays,omitempty"`
+    SponsorPenaltyDays int `json:"sponsorPenaltyDays,omitempty"`
+
+    // IdentityCheck flags registrations that may duplicate an existing
+    // citizen. It is set in code rather than in the genesis file; when
+    // unset, a SaltedIdentity with IdentitySalt is used.
+    IdentitySalt  string        `json:"identitySalt,omitempty"`
+    IdentityCheck IdentityCheck `json:"-"`
+}
+
+// Defaults of a RegistryPolicy
+const (
+    defaultSponsorLimit       = 3
+    defaultSponsorPeriodDays  = 30
+    defaultSponsorPenaltyDays = 365
+)
+
+// withDefaults returns the policy with its zero values replaced by the
+// defaults they select
+func (p RegistryPolicy) withDefaults() RegistryPolicy {
+    if p.SponsorLimit == 0 {
+        p.SponsorLimit = defaultSponsorLimit
+    }
+    if p.SponsorPeriodDays == 0 {
+        p.SponsorPeriodDays = defaultSponsorPeriodDays
+    }
+    if p.SponsorPenaltyDays == 0 {
+        p.SponsorPenaltyDays = defaultSponsorPenaltyDays
+    }
+    if p.IdentityCheck == nil {
+        p.IdentityCheck = SaltedIdentity{Salt: p.IdentitySalt}
+    }
+    return p
+}
+
+// validate rejects negative thresholds and limits
+func (p *RegistryPolicy) validate() error {
+    for _, value := range []int{p.AdminThreshold, p.ApprovalThreshold, p.SponsorThreshold, p.SponsorLimit, p.SponsorPeriodDays, p.SponsorPenaltyDays} {
+        if value < 0 {
+            return fmt.Errorf("genesis registry thresholds and limits cannot be negative")
+        }
+    }
+    return nil
+}
+
+// LoadGenesis reads a genesis configuration from a JSON file
+func LoadGenesis(path string) (*Genesis, error) {
+    raw, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read genesis file: %v", err)
+    }
+
+    var genesis Genesis
+    if err := json.Unmarshal(raw, &genesis); err != nil {
+        return nil, fmt.Errorf("invalid genesis file: %v", err)
+    }
+    if err := genesis.Validate(); err != nil {
+        return nil, err
+    }
+    return &genesis, nil
+}
+
+// Validate checks that the genesis configuration is usable
+func (g *Genesis) Validate() error {
+    if len(g.Validators) == 0 {
+        return fmt.Errorf("genesis must list at least one validator")
+    }
+    if err := validateKeyList("validator", g.Validators); err != nil {
+        return err
+    }
+    if err := g.RegistryPolicy.validate(); err != nil {
+        return err
+    }
+
+    // Without admins the admin set could never change and no citizen could
+    // be approved
+    if len(g.Admins) == 0 {
+        return fmt.Errorf("genesis must list at least one admin")
+    }
+    if g.AdminThreshold > len(g.Admins) || g.ApprovalThreshold > len(g.Admins) {
+        return fmt.Errorf("genesis admin and approval thresholds cannot exceed the %d admins", len(g.Admins))
+    }
+    return validateKeyList("admin", g.Admins)
+}
+
+// validateKeyList checks that keys are well-formed and distinct
+func validateKeyList(role string, keys []string) error {
+    seen := make(map[string]bool)
+    for _, key := range keys {
+        if err := ValidatePublicKey(key); err != nil {
+            return fmt.Errorf("genesis %s %q: %v", role, key, err)
+        }
+        if seen[key] {
+            return fmt.Errorf("genesis %s %q listed twice", role, key)
+        }
+        seen[key] = true
+    }
+    return nil
+}
+
+// Hash commits to the whole genesis configuration; it is used as the
+// previous hash of block 0 so that networks with different genesis
+// configurations never share a chain
+func (g *Genesis) Hash() []byte {
+    raw, _ := json.Marshal(g)
+    hash := sha256.Sum256(raw)
+    return hash[:]
+}
+
+// Block returns the genesis block described by the configuration
+func (g *Genesis) Block() *Block {
+    return newBlockAt(0, g.Timestamp, []Transaction{}, g.Hash())
+}
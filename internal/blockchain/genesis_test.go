@@ -0,0 +1,33 @@
+package blockchain
+
+import "testing"
+
+func TestValidateGenesis(t *testing.T) {
+    validator, first, second := newTestKey(t).Public, newTestKey(t).Public, newTestKey(t).Public
+    tests := []struct {
+        name    string
+        genesis Genesis
+        ok      bool
+    }{
+        {"one admin", Genesis{Validators: []string{validator}, Admins: []string{first}}, true},
+        {"thresholds within the admins", Genesis{Validators: []string{validator}, Admins: []string{first, second},
+            RegistryPolicy: RegistryPolicy{AdminThreshold: 2, ApprovalThreshold: 2}}, true},
+        {"no validators", Genesis{Admins: []string{first}}, false},
+        {"no admins", Genesis{Validators: []string{validator}}, false},
+        {"admin listed twice", Genesis{Validators: []string{validator}, Admins: []string{first, first}}, false},
+        {"malformed admin", Genesis{Validators: []string{validator}, Admins: []string{"abcd"}}, false},
+        {"admin threshold above the admins", Genesis{Validators: []string{validator}, Admins: []string{first, second},
+            RegistryPolicy: RegistryPolicy{AdminThreshold: 3}}, false},
+        {"approval threshold above the admins", Genesis{Validators: []string{validator}, Admins: []string{first},
+            RegistryPolicy: RegistryPolicy{ApprovalThreshold: 2}}, false},
+        {"negative threshold", Genesis{Validators: []string{validator}, Admins: []string{first},
+            RegistryPolicy: RegistryPolicy{SponsorThreshold: -1}}, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if err := tt.genesis.Validate(); (err == nil) != tt.ok {
+                t.Errorf("Validate() = %v, want ok = %v", err, tt.ok)
+            }
+        })
+    }
+}
@@ -0,0 +1,155 @@
+package blockchain
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+    "testing"
+)
+
+// testKey is a key pair of a test participant
+type testKey struct {
+    Public  string
+    Private string
+}
+
+func newTestKey(t *testing.T) testKey {
+    t.Helper()
+    public, private, err := GenerateKeyPair()
+    if err != nil {
+        t.Fatal(err)
+    }
+    return testKey{Public: public, Private: private}
+}
+
+// signedBy returns a function that gives the transaction returned by a
+// builder a fresh nonce and signs it with key
+func signedBy(t *testing.T, key testKey) func(*Transaction, error) *Transaction {
+    return func(tx *Transaction, err error) *Transaction {
+        t.Helper()
+        return signTx(t, key, tx, err)
+    }
+}
+
+func signTx(t *testing.T, key testKey, tx *Transaction, err error) *Transaction {
+    t.Helper()
+    if err != nil {
+        t.Fatal(err)
+    }
+    nonce := make([]byte, 8)
+    if _, err := rand.Read(nonce); err != nil {
+        t.Fatal(err)
+    }
+    tx.SetNonce(hex.EncodeToString(nonce))
+    if err := tx.Sign(key.Private); err != nil {
+        t.Fatal(err)
+    }
+    return tx
+}
+
+// testStartTime is the timestamp of the first test block
+const testStartTime = 1_700_000_000
+
+// testState drives a State block by block without a chain
+type testState struct {
+    *State
+    t     *testing.T
+    ctx   BlockContext
+    admin testKey
+}
+
+// newTestState returns a state with one admin, at block 1
+func newTestState(t *testing.T, policy RegistryPolicy) *testState {
+    t.Helper()
+    s, _ := newTestStateWithAdmins(t, policy, 1)
+    return s
+}
+
+// newTestStateWithAdmins returns a state with n admins, at block 1. The
+// first of them is the state's admin.
+func newTestStateWithAdmins(t *testing.T, policy RegistryPolicy, n int) (*testState, []testKey) {
+    t.Helper()
+    var admins []testKey
+    genesis := &Genesis{Timestamp: testStartTime, RegistryPolicy: policy}
+    for i := 0; i < n; i++ {
+        admin := newTestKey(t)
+        admins = append(admins, admin)
+        genesis.Admins = append(genesis.Admins, admin.Public)
+    }
+    s := &testState{State: NewState(genesis), t: t, admin: admins[0]}
+    s.ctx = BlockContext{Height: 1, Timestamp: testStartTime}
+    s.beginBlock(s.ctx)
+    return s, admins
+}
+
+// apply applies tx in the current block
+func (s *testState) apply(tx *Transaction) error {
+    return s.ApplyTransaction(tx, s.ctx)
+}
+
+// mustApply applies tx in the current block and fails the test on error
+func (s *testState) mustApply(tx *Transaction) {
+    s.t.Helper()
+    if err := s.apply(tx); err != nil {
+        s.t.Fatalf("%s: %v", tx.Data["type"], err)
+    }
+}
+
+// advance moves to a block the given number of seconds later
+func (s *testState) advance(seconds int64) {
+    s.ctx = BlockContext{Height: s.ctx.Height + 1, Timestamp: s.ctx.Timestamp + seconds}
+    s.beginBlock(s.ctx)
+}
+
+// register applies a registration of a new citizen and returns their key
+// and citizen ID
+func (s *testState) register(name string) (testKey, string) {
+    s.t.Helper()
+    key := newTestKey(s.t)
+    s.mustApply(signedBy(s.t, key)(NewCitizenRegistrationTx(name, "1990-01-01", "", key.Public, s.ctx.Timestamp)))
+    return key, generateCitizenID(name, key.Public)
+}
+
+// citizen registers a citizen and has the admin approve them
+func (s *testState) citizen(name string) (testKey, string) {
+    s.t.Helper()
+    key, id := s.register(name)
+    s.mustApply(signedBy(s.t, s.admin)(NewCitizenApprovalTx(s.admin.Public, id, s.ctx.Timestamp)))
+    return key, id
+}
+
+// status returns the status of the citizen with the given ID
+func (s *testState) status(citizenID string) CitizenStatus {
+    s.t.Helper()
+    citizen, exists := s.citizenRegistry.GetCitizenByID(citizenID)
+    if !exists {
+        s.t.Fatalf("citizen %s not found", citizenID)
+    }
+    return citizen.Status
+}
+
+// startElection has the admin start a week-long election and returns its ID
+func (s *testState) startElection(options ElectionOptions) string {
+    s.t.Helper()
+    tx := signedBy(s.t, s.admin)(NewElectionStartTx(s.admin.Public, "General election", 7, options, s.ctx.Timestamp))
+    s.mustApply(tx)
+    return tx.ID
+}
+
+// candidate registers a new citizen as a candidate in an election and
+// returns their key and candidate ID
+func (s *testState) candidate(electionID, name string) (testKey, string) {
+    s.t.Helper()
+    key, _ := s.citizen(name)
+    s.mustApply(signedBy(s.t, key)(NewCandidateRegistrationTx(electionID, name, key.Public, "", s.ctx.Timestamp)))
+    return key, generateCandidateID(name, key.Public)
+}
+
+// election returns the election with the given ID
+func (s *testState) election(id string) *Election {
+    s.t.Helper()
+    election, exists := s.electionSystem.GetElection(id)
+    if !exists {
+        s.t.Fatalf("election %s not found", id)
+    }
+    return election
+}
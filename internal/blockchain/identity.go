@@ -0,0 +1,150 @@
+package blockchain
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "slices"
+    "sort"
+    "strings"
+)
+
+// Domain tags of the identity hashes
+const (
+    documentDomain = "virtual-ethiopia/document"
+    identityDomain = "virtual-ethiopia/identity"
+)
+
+// IdentityCheck recognises registrations that may belong to the same
+// person. It derives identity keys from what an applicant declares; two
+// citizens sharing a key are suspected duplicates. Every node must derive
+// the same keys, so implementations must be deterministic.
+type IdentityCheck interface {
+    IdentityKeys(name, dateOfBirth, documentHash string) []string
+}
+
+// SaltedIdentity is the default IdentityCheck. It derives one key from the
+// name and date of birth, and one from the identity document when the
+// applicant declared one. Both are hashed with the network's salt, so the
+// keys of one network cannot be matched against another's.
+type SaltedIdentity struct {
+    Salt string
+}
+
+// IdentityKeys implements IdentityCheck
+func (s SaltedIdentity) IdentityKeys(name, dateOfBirth, documentHash string) []string {
+    keys := []string{s.hash("person", strings.ToLower(strings.Join(strings.Fields(name), " ")), dateOfBirth)}
+    if documentHash != "" {
+        keys = append(keys, s.hash("document", documentHash))
+    }
+    return keys
+}
+
+func (s SaltedIdentity) hash(fields ...string) string {
+    var e encoder
+    e.string(identityDomain)
+    e.string(s.Salt)
+    for _, field := range fields {
+        e.string(field)
+    }
+    hash := sha256.Sum256(e.buf.Bytes())
+    return hex.EncodeToString(hash[:])
+}
+
+// DocumentHash returns the hash of an identity document number that an
+// applicant declares in place of the number itself. Spaces are ignored and
+// letters compared without case.
+func DocumentHash(documentNumber string) string {
+    if documentNumber == "" {
+        return ""
+    }
+    var e encoder
+    e.string(documentDomain)
+    e.string(strings.ToUpper(strings.Join(strings.Fields(documentNumber), "")))
+    hash := sha256.Sum256(e.buf.Bytes())
+    return hex.EncodeToString(hash[:])
+}
+
+// duplicatesOf returns, sorted, the IDs of the citizens sharing one of the
+// given identity keys
+func (cr *CitizenRegistry) duplicatesOf(keys []string) []string {
+    var ids []string
+    for _, key := range keys {
+        ids = append(ids, cr.identities[key]...)
+    }
+    sort.Strings(ids)
+    return slices.Compact(ids)
+}
+
+// GetDuplicateClusters returns the groups of two or more citizens linked,
+// directly or through one another, by shared identity keys. Each group
+// lists its citizens in order of registration, and the groups are ordered
+// by their first registration.
+func (cr *CitizenRegistry) GetDuplicateClusters() [][]*Citizen {
+    cr.mu.RLock()
+    defer cr.mu.RUnlock()
+
+    sets := make(disjointSets)
+    for _, ids := range cr.identities {
+        for _, id := range ids[1:] {
+            sets.union(ids[0], id)
+        }
+    }
+
+    citizens := make([]*Citizen, 0, len(cr.citizens))
+    for _, key := range sortedKeys(cr.citizens) {
+        citizens = append(citizens, cr.citizens[key])
+    }
+    sort.SliceStable(citizens, func(i, j int) bool {
+        return citizens[i].RegisterDate < citizens[j].RegisterDate
+    })
+
+    clusters := make([][]*Citizen, 0)
+    index := make(map[string]int) // root ID -> position in clusters
+    for _, citizen := range citizens {
+        if !sets.linked(citizen.ID) {
+            continue
+        }
+        root := sets.find(citizen.ID)
+        i, exists := index[root]
+        if !exists {
+            i = len(clusters)
+            index[root] = i
+            clusters = append(clusters, nil)
+        }
+        clusters[i] = append(clusters[i], citizen)
+    }
+    return clusters
+}
+
+// disjointSets is a union-find over citizen IDs. An ID missing from the map
+// is alone in its set.
+type disjointSets map[string]string // citizen ID -> parent ID
+
+// find returns the ID representing the set of id
+func (d disjointSets) find(id string) string {
+    for {
+        parent, exists := d[id]
+        if !exists || parent == id {
+            return id
+        }
+        if grandparent, exists := d[parent]; exists {
+            d[id] = grandparent
+        }
+        id = parent
+    }
+}
+
+// union merges the sets of a and b
+func (d disjointSets) union(a, b string) {
+    a, b = d.find(a), d.find(b)
+    if a != b {
+        d[a] = a
+        d[b] = a
+    }
+}
+
+// linked reports whether id shares its set with another ID
+func (d disjointSets) linked(id string) bool {
+    _, exists := d[id]
+    return exists
+}
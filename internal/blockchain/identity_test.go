@@ -0,0 +1,187 @@
+package blockchain
+
+import (
+    "slices"
+    "testing"
+)
+
+// applicant is a registration in a duplicate detection test, known by label
+type applicant struct {
+    label, name, dateOfBirth, document string
+}
+
+// registerAll registers the applicants one second apart and returns the
+// citizen ID of each label
+func registerAll(t *testing.T, registry *CitizenRegistry, applicants []applicant) map[string]string {
+    t.Helper()
+    ids := make(map[string]string)
+    for i, a := range applicants {
+        key := newTestKey(t)
+        ctx := BlockContext{Height: int64(i + 1), Timestamp: testStartTime + int64(i)}
+        citizen, err := registry.RegisterCitizen(a.name, a.dateOfBirth, DocumentHash(a.document), key.Public, ctx)
+        if err != nil {
+            t.Fatal(err)
+        }
+        ids[a.label] = citizen.ID
+    }
+    return ids
+}
+
+func TestDuplicateDetection(t *testing.T) {
+    tests := []struct {
+        name        string
+        applicants  []applicant
+        clusters    [][]string          // labels, in order of registration
+        duplicateOf map[string][]string // label -> labels flagged on registration
+    }{
+        {
+            name: "distinct people",
+            applicants: []applicant{
+                {"a", "Abebe Bikila", "1932-08-07", "EP1"},
+                {"b", "Abebe Bikila", "1950-01-01", "EP2"},
+                {"c", "Tirunesh Dibaba", "1985-06-01", ""},
+            },
+        },
+        {
+            name: "same name and date of birth",
+            applicants: []applicant{
+                {"a", "Abebe Bikila", "1932-08-07", ""},
+                {"b", "abebe  BIKILA", "1932-08-07", ""},
+            },
+            clusters:    [][]string{{"a", "b"}},
+            duplicateOf: map[string][]string{"b": {"a"}},
+        },
+        {
+            name: "same document",
+            applicants: []applicant{
+                {"a", "Abebe Bikila", "1932-08-07", "EP 123"},
+                {"b", "Abebe B.", "1932-08-08", "ep123"},
+            },
+            clusters:    [][]string{{"a", "b"}},
+            duplicateOf: map[string][]string{"b": {"a"}},
+        },
+        {
+            name: "linked through another citizen",
+            applicants: []applicant{
+                {"a", "Abebe Bikila", "1932-08-07", ""},
+                {"b", "Abebe Bikila", "1932-08-07", "EP123"},
+                {"c", "Someone Else", "1990-01-01", "EP123"},
+            },
+            clusters:    [][]string{{"a", "b", "c"}},
+            duplicateOf: map[string][]string{"b": {"a"}, "c": {"b"}},
+        },
+        {
+            name: "clusters joined by a later registration",
+            applicants: []applicant{
+                {"a", "Abebe Bikila", "1932-08-07", ""},
+                {"b", "Derartu Tulu", "1972-03-21", "EP9"},
+                {"c", "Abebe Bikila", "1932-08-07", "EP9"},
+            },
+            clusters:    [][]string{{"a", "b", "c"}},
+            duplicateOf: map[string][]string{"c": {"a", "b"}},
+        },
+        {
+            name: "separate clusters",
+            applicants: []applicant{
+                {"a", "Derartu Tulu", "1972-03-21", ""},
+                {"b", "Abebe Bikila", "1932-08-07", ""},
+                {"c", "Abebe Bikila", "1932-08-07", ""},
+                {"d", "Derartu Tulu", "1972-03-21", ""},
+            },
+            clusters:    [][]string{{"a", "d"}, {"b", "c"}},
+            duplicateOf: map[string][]string{"c": {"b"}, "d": {"a"}},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            registry := NewCitizenRegistry(nil, RegistryPolicy{IdentitySalt: "test"})
+            ids := registerAll(t, registry, tt.applicants)
+
+            var clusters [][]string
+            for _, cluster := range registry.GetDuplicateClusters() {
+                var labels []string
+                for _, citizen := range cluster {
+                    for label, id := range ids {
+                        if id == citizen.ID {
+                            labels = append(labels, label)
+                        }
+                    }
+                }
+                clusters = append(clusters, labels)
+            }
+            if !slices.EqualFunc(clusters, tt.clusters, slices.Equal) {
+                t.Errorf("clusters = %v, want %v", clusters, tt.clusters)
+            }
+
+            for _, a := range tt.applicants {
+                var want []string
+                for _, label := range tt.duplicateOf[a.label] {
+                    want = append(want, ids[label])
+                }
+                slices.Sort(want)
+                if got := registry.byID(ids[a.label]).DuplicateOf; !slices.Equal(got, want) {
+                    t.Errorf("%s: duplicate of %v, want %v", a.label, got, want)
+                }
+            }
+        })
+    }
+}
+
+func TestCloneCopiesIdentityIndex(t *testing.T) {
+    registry := NewCitizenRegistry(nil, RegistryPolicy{})
+    registerAll(t, registry, []applicant{{"a", "Abebe Bikila", "1932-08-07", ""}})
+
+    clone := registry.clone()
+    registerAll(t, clone, []applicant{{"b", "Abebe Bikila", "1932-08-07", ""}})
+
+    if got := len(clone.GetDuplicateClusters()); got != 1 {
+        t.Errorf("clusters in the clone = %d, want 1", got)
+    }
+    if got := len(registry.GetDuplicateClusters()); got != 0 {
+        t.Errorf("clusters in the original = %d, want 0", got)
+    }
+}
+
+func TestCitizenIndexFollowsKeys(t *testing.T) {
+    registry := NewCitizenRegistry(nil, RegistryPolicy{})
+    old, current := newTestKey(t).Public, newTestKey(t).Public
+    citizen, err := registry.RegisterCitizen("Abebe Bikila", "1932-08-07", "", old, BlockContext{Height: 1, Timestamp: testStartTime})
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    clone := registry.clone()
+    if err := clone.RotateKey(old, current, BlockContext{Height: 2, Timestamp: testStartTime + 1}); err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        name     string
+        registry *CitizenRegistry
+        key      string // the citizen's key in the registry
+        retired  string // a retired key of theirs, if any
+    }{
+        {"original", registry, old, ""},
+        {"rotated clone", clone, current, old},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            found := tt.registry.byID(citizen.ID)
+            if found == nil || found.PublicKey != tt.key {
+                t.Fatalf("byID = %v, want the citizen under %s", found, tt.key)
+            }
+            if !tt.registry.keyUsed(tt.key) {
+                t.Error("current key is not in use")
+            }
+            if tt.retired != "" && !tt.registry.keyUsed(tt.retired) {
+                t.Error("retired key is free again")
+            }
+            if tt.registry.keyUsed(newTestKey(t).Public) {
+                t.Error("fresh key is in use")
+            }
+        })
+    }
+    if registry.keyUsed(current) {
+        t.Error("rotation in the clone reached the original")
+    }
+}
@@ -0,0 +1,253 @@
+package blockchain
+
+import (
+    "errors"
+    "slices"
+)
+
+// Actions recorded in a citizen's history
+const (
+    ActionRegistered        = "registered"
+    ActionApproved          = "approved"
+    ActionRejected          = "rejected"
+    ActionSuspended         = "suspended"
+    ActionReinstated        = "reinstated"
+    ActionRevoked           = "revoked"
+    ActionAppealFiled       = "appeal-filed"
+    ActionAppealGranted     = "appeal-granted"
+    ActionAppealDenied      = "appeal-denied"
+    ActionVouchingSuspended = "vouching-suspended"
+    ActionGuardiansSet      = "guardians-set"
+    ActionKeyRotated        = "key-rotated"
+    ActionKeyRecovered      = "key-recovered"
+)
+
+// StatusChange is an entry in a citizen's history
+type StatusChange struct {
+    Action string        `json:"action"`
+    Status CitizenStatus `json:"status"` // status once the action applied
+    By     string        `json:"by"`     // public key of the admin, or of the citizen for their own actions
+    Reason string        `json:"reason,omitempty"`
+    Date   int64         `json:"date"`
+    Height int64         `json:"height"`
+}
+
+// Appeal is a citizen's request to overturn their rejection, suspension
+// or revocation. It is decided by a majority of the admins.
+type Appeal struct {
+    Against   CitizenStatus `json:"against"`
+    Statement string        `json:"statement"`
+    FiledDate int64         `json:"filedDate"`
+    Grants    []string      `json:"grants"`  // admins in favour
+    Denials   []string      `json:"denials"` // admins against
+}
+
+// record appends an action to the citizen's history
+func (c *Citizen) record(action, by, reason string, ctx BlockContext) {
+    c.History = append(c.History, StatusChange{
+        Action: action,
+        Status: c.Status,
+        By:     by,
+        Reason: reason,
+        Date:   ctx.Timestamp,
+        Height: ctx.Height,
+    })
+}
+
+// RejectCitizen turns down a pending application
+func (cr *CitizenRegistry) RejectCitizen(citizenID, adminKey, reason string, ctx BlockContext) error {
+    return cr.changeStatus(citizenID, adminKey, reason, ctx, ActionRejected, Rejected, Pending)
+}
+
+// SuspendCitizen withdraws an approved citizen's voting and candidacy
+// rights until they are reinstated
+func (cr *CitizenRegistry) SuspendCitizen(citizenID, adminKey, reason string, ctx BlockContext) error {
+    return cr.changeStatus(citizenID, adminKey, reason, ctx, ActionSuspended, Suspended, Approved)
+}
+
+// ReinstateCitizen restores a suspended citizen's rights
+func (cr *CitizenRegistry) ReinstateCitizen(citizenID, adminKey, reason string, ctx BlockContext) error {
+    return cr.changeStatus(citizenID, adminKey, reason, ctx, ActionReinstated, Approved, Suspended)
+}
+
+// RevokeCitizen ends an approved or suspended citizen's citizenship. A
+// citizen revoked for fraud costs their sponsors the right to vouch for a
+// while.
+func (cr *CitizenRegistry) RevokeCitizen(citizenID, adminKey, reason string, fraud bool, ctx BlockContext) error {
+    if err := cr.changeStatus(citizenID, adminKey, reason, ctx, ActionRevoked, Revoked, Approved, Suspended); err != nil {
+        return err
+    }
+    if fraud {
+        cr.sanctionSponsors(citizenID, adminKey, ctx)
+    }
+    return nil
+}
+
+// changeStatus moves a citizen from one of the from statuses to status on
+// an admin's decision. Every decision but a reinstatement needs a reason.
+// An open appeal is closed, since it was against the earlier status.
+func (cr *CitizenRegistry) changeStatus(citizenID, adminKey, reason string, ctx BlockContext, action string, status CitizenStatus, from ...CitizenStatus) error {
+    cr.mu.Lock()
+    defer cr.mu.Unlock()
+
+    if !cr.admins[adminKey] {
+        return errors.New("not authorized to change citizen status")
+    }
+    if reason == "" && action != ActionReinstated {
+        return errors.New("a reason is required")
+    }
+
+    citizen := cr.byID(citizenID)
+    if citizen == nil {
+        return errors.New("citizen not found")
+    }
+    if !slices.Contains(from, citizen.Status) {
+        return errors.New("citizen status does not allow this change")
+    }
+
+    citizen.Status = status
+    citizen.Appeal = nil
+    citizen.record(action, adminKey, reason, ctx)
+    return nil
+}
+
+// FileAppeal opens an appeal by the citizen holding publicKey against
+// their rejection, suspension or revocation. Each decision can be appealed
+// once.
+func (cr *CitizenRegistry) FileAppeal(publicKey, statement string, ctx BlockContext) error {
+    cr.mu.Lock()
+    defer cr.mu.Unlock()
+
+    citizen, exists := cr.citizens[publicKey]
+    if !exists {
+        return errors.New("citizen not found")
+    }
+    switch citizen.Status {
+    case Rejected, Suspended, Revoked:
+    default:
+        return errors.New("only a rejection, suspension or revocation can be appealed")
+    }
+    if citizen.Appeal != nil {
+        return errors.New("an appeal is already open")
+    }
+    if citizen.appealed() {
+        return errors.New("this decision has already been appealed")
+    }
+    if statement == "" {
+        return errors.New("an appeal needs a statement")
+    }
+
+    citizen.Appeal = &Appeal{
+        Against:   citizen.Status,
+        Statement: statement,
+        FiledDate: ctx.Timestamp,
+        Grants:    make([]string, 0),
+        Denials:   make([]string, 0),
+    }
+    citizen.record(ActionAppealFiled, publicKey, statement, ctx)
+    return nil
+}
+
+// DecideAppeal records an admin's vote on a citizen's open appeal; see
+// settleAppeal for when the appeal is decided
+func (cr *CitizenRegistry) DecideAppeal(citizenID, adminKey string, grant bool, ctx BlockContext) error {
+    cr.mu.Lock()
+    defer cr.mu.Unlock()
+
+    if !cr.admins[adminKey] {
+        return errors.New("not authorized to decide appeals")
+    }
+    citizen := cr.byID(citizenID)
+    if citizen == nil {
+        return errors.New("citizen not found")
+    }
+    appeal := citizen.Appeal
+    if appeal == nil {
+        return errors.New("citizen has no open appeal")
+    }
+    if slices.Contains(appeal.Grants, adminKey) || slices.Contains(appeal.Denials, adminKey) {
+        return errors.New("admin has already decided this appeal")
+    }
+
+    if grant {
+        appeal.Grants = append(appeal.Grants, adminKey)
+    } else {
+        appeal.Denials = append(appeal.Denials, adminKey)
+    }
+    cr.settleAppeal(citizen, adminKey, ctx)
+    return nil
+}
+
+// settleAppeal decides the citizen's open appeal once its outcome is
+// certain. Only the votes of current admins count. The appeal is granted
+// when a majority of the admins grant it, which approves the citizen, and
+// denied as soon as the grants can no longer reach a majority, which
+// leaves their status as it is; an even split denies it. adminKey is the
+// admin whose transaction settled it.
+func (cr *CitizenRegistry) settleAppeal(citizen *Citizen, adminKey string, ctx BlockContext) {
+    appeal := citizen.Appeal
+    grants := cr.countAdmins(appeal.Grants)
+    undecided := len(cr.admins) - grants - cr.countAdmins(appeal.Denials)
+
+    quorum := cr.appealQuorum()
+    switch {
+    case grants >= quorum:
+        if citizen.Status == Rejected {
+            citizen.ApprovedBy = adminKey
+            citizen.ApprovalDate = ctx.Timestamp
+            citizen.ApprovalHeight = ctx.Height
+        }
+        citizen.Status = Approved
+        citizen.Appeal = nil
+        citizen.record(ActionAppealGranted, adminKey, "", ctx)
+    case grants+undecided < quorum:
+        citizen.Appeal = nil
+        citizen.record(ActionAppealDenied, adminKey, "", ctx)
+    }
+}
+
+// settleAppeals settles every open appeal again after the admin set
+// changed, since the votes that count and the majority needed change with
+// it
+func (cr *CitizenRegistry) settleAppeals(adminKey string, ctx BlockContext) {
+    for _, key := range sortedKeys(cr.citizens) {
+        if citizen := cr.citizens[key]; citizen.Appeal != nil {
+            cr.settleAppeal(citizen, adminKey, ctx)
+        }
+    }
+}
+
+// GetOpenAppeals returns the citizens with an open appeal
+func (cr *CitizenRegistry) GetOpenAppeals() []*Citizen {
+    cr.mu.RLock()
+    defer cr.mu.RUnlock()
+
+    citizens := make([]*Citizen, 0)
+    for _, key := range sortedKeys(cr.citizens) {
+        if citizen := cr.citizens[key]; citizen.Appeal != nil {
+            citizens = append(citizens, citizen)
+        }
+    }
+    return citizens
+}
+
+// appealQuorum returns the number of admins who must grant an appeal: a
+// majority of them
+func (cr *CitizenRegistry) appealQuorum() int {
+    return len(cr.admins)/2 + 1
+}
+
+// appealed reports whether the citizen has appealed since their status
+// last changed
+func (c *Citizen) appealed() bool {
+    for i := len(c.History) - 1; i >= 0; i-- {
+        switch c.History[i].Action {
+        case ActionAppealFiled:
+            return true
+        case ActionAppealGranted, ActionAppealDenied:
+            continue
+        }
+        return false
+    }
+    return false
+}
@@ -0,0 +1,260 @@
+package blockchain
+
+import (
+    "slices"
+    "testing"
+)
+
+// reach brings a new citizen to status through admin decisions and returns
+// their key and citizen ID
+func (s *testState) reach(name string, status CitizenStatus) (testKey, string) {
+    s.t.Helper()
+    key, id := s.register(name)
+    change := func(txType string) {
+        s.mustApply(signedBy(s.t, s.admin)(NewCitizenStatusChangeTx(s.admin.Public, txType, id, "Decision", false, s.ctx.Timestamp)))
+    }
+    switch status {
+    case Pending:
+    case Rejected:
+        change(TxCitizenRejection)
+    default:
+        s.mustApply(signedBy(s.t, s.admin)(NewCitizenApprovalTx(s.admin.Public, id, s.ctx.Timestamp)))
+        switch status {
+        case Suspended:
+            change(TxCitizenSuspension)
+        case Revoked:
+            change(TxCitizenRevocation)
+        }
+    }
+    return key, id
+}
+
+func TestStatusChanges(t *testing.T) {
+    names := map[CitizenStatus]string{
+        Pending: "pending", Approved: "approved", Rejected: "rejected", Suspended: "suspended", Revoked: "revoked",
+    }
+    tests := []struct {
+        from   CitizenStatus
+        txType string
+        want   CitizenStatus // the status it moves to, or from if it is refused
+    }{
+        {Pending, TxCitizenRejection, Rejected},
+        {Pending, TxCitizenSuspension, Pending},
+        {Pending, TxCitizenReinstatement, Pending},
+        {Pending, TxCitizenRevocation, Pending},
+        {Approved, TxCitizenRejection, Approved},
+        {Approved, TxCitizenSuspension, Suspended},
+        {Approved, TxCitizenReinstatement, Approved},
+        {Approved, TxCitizenRevocation, Revoked},
+        {Suspended, TxCitizenSuspension, Suspended},
+        {Suspended, TxCitizenReinstatement, Approved},
+        {Suspended, TxCitizenRevocation, Revoked},
+        {Rejected, TxCitizenReinstatement, Rejected},
+        {Rejected, TxCitizenRevocation, Rejected},
+        {Revoked, TxCitizenReinstatement, Revoked},
+        {Revoked, TxCitizenSuspension, Revoked},
+    }
+    for _, tt := range tests {
+        t.Run(names[tt.from]+" "+tt.txType, func(t *testing.T) {
+            s := newTestState(t, RegistryPolicy{})
+            _, id := s.reach("Citizen", tt.from)
+            err := s.apply(signedBy(t, s.admin)(NewCitizenStatusChangeTx(s.admin.Public, tt.txType, id, "Decision", false, s.ctx.Timestamp)))
+            if (err == nil) != (tt.want != tt.from) {
+                t.Errorf("error = %v, want a change to %v", err, tt.want)
+            }
+            if got := s.status(id); got != tt.want {
+                t.Errorf("status = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestStatusChangeAuthority(t *testing.T) {
+    s := newTestState(t, RegistryPolicy{})
+    citizen, id := s.citizen("Citizen")
+
+    if err := s.apply(signedBy(t, citizen)(NewCitizenStatusChangeTx(citizen.Public, TxCitizenSuspension, id, "Decision", false, s.ctx.Timestamp))); err == nil {
+        t.Error("a citizen who is not an admin suspended a citizen")
+    }
+    if err := s.apply(signedBy(t, s.admin)(NewCitizenStatusChangeTx(s.admin.Public, TxCitizenSuspension, id, "", false, s.ctx.Timestamp))); err == nil {
+        t.Error("a citizen was suspended without a reason")
+    }
+    s.mustApply(signedBy(t, s.admin)(NewCitizenStatusChangeTx(s.admin.Public, TxCitizenSuspension, id, "Decision", false, s.ctx.Timestamp)))
+    s.mustApply(signedBy(t, s.admin)(NewCitizenStatusChangeTx(s.admin.Public, TxCitizenReinstatement, id, "", false, s.ctx.Timestamp)))
+
+    citizenRecord, _ := s.citizenRegistry.GetCitizenByID(id)
+    var actions []string
+    for _, change := range citizenRecord.History {
+        actions = append(actions, change.Action)
+    }
+    want := []string{ActionRegistered, ActionApproved, ActionSuspended, ActionReinstated}
+    if !slices.Equal(actions, want) {
+        t.Errorf("history = %v, want %v", actions, want)
+    }
+}
+
+func TestAppeals(t *testing.T) {
+    tests := []struct {
+        name      string
+        from      CitizenStatus
+        decisions []bool // of the first admins, in order; three admins decide by two
+        want      CitizenStatus
+        open      bool // whether the appeal is still open
+    }{
+        {"suspension granted", Suspended, []bool{true, true}, Approved, false},
+        {"suspension denied", Suspended, []bool{false, false}, Suspended, false},
+        {"split, then granted", Suspended, []bool{true, false, true}, Approved, false},
+        {"undecided", Suspended, []bool{true}, Suspended, true},
+        {"rejection granted", Rejected, []bool{true, true}, Approved, false},
+        {"revocation granted", Revoked, []bool{true, true}, Approved, false},
+        {"revocation denied", Revoked, []bool{false, true, false}, Revoked, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s, admins := newTestStateWithAdmins(t, RegistryPolicy{}, 3)
+            citizen, id := s.reach("Citizen", tt.from)
+            s.mustApply(signedBy(t, citizen)(NewCitizenAppealTx(citizen.Public, "I did nothing wrong", s.ctx.Timestamp)))
+            for i, grant := range tt.decisions {
+                s.mustApply(signedBy(t, admins[i])(NewAppealDecisionTx(admins[i].Public, id, grant, s.ctx.Timestamp)))
+            }
+
+            record, _ := s.citizenRegistry.GetCitizenByID(id)
+            if record.Status != tt.want || (record.Appeal != nil) != tt.open {
+                t.Fatalf("status = %v, open appeal = %v, want %v and %v", record.Status, record.Appeal != nil, tt.want, tt.open)
+            }
+            if tt.from == Rejected && tt.want == Approved && record.ApprovalHeight != s.ctx.Height {
+                t.Errorf("approval height = %d, want %d", record.ApprovalHeight, s.ctx.Height)
+            }
+
+            // Each decision is appealed once
+            err := s.apply(signedBy(t, citizen)(NewCitizenAppealTx(citizen.Public, "Once more", s.ctx.Timestamp)))
+            if err == nil {
+                t.Error("a second appeal was filed")
+            }
+        })
+    }
+}
+
+func TestAppealCountsCurrentAdmins(t *testing.T) {
+    type step struct {
+        admin  int
+        grant  bool
+        remove []int // if set, admin proposes removing remove[0] and the others approve
+    }
+    tests := []struct {
+        name   string
+        admins int
+        steps  []step
+        want   CitizenStatus
+        open   bool
+    }{
+        {"even split", 4, []step{{admin: 0, grant: true}, {admin: 1, grant: true}, {admin: 2}, {admin: 3}}, Suspended, false},
+        {"majority out of reach", 4, []step{{admin: 0}, {admin: 1}}, Suspended, false},
+        {"majority within reach", 4, []step{{admin: 0, grant: true}, {admin: 1}}, Suspended, true},
+        {"removed admin's grant lapses", 3, []step{
+            {admin: 1, grant: true},
+            {admin: 0, remove: []int{1, 2}},
+            {admin: 0, grant: true},
+        }, Suspended, true},
+        {"remaining admins grant", 3, []step{
+            {admin: 1, grant: true},
+            {admin: 0, remove: []int{1, 2}},
+            {admin: 0, grant: true},
+            {admin: 2, grant: true},
+        }, Approved, false},
+        {"removal leaves a split", 3, []step{
+            {admin: 0, grant: true},
+            {admin: 1},
+            {admin: 0, remove: []int{2, 1}},
+        }, Suspended, false},
+        {"removal leaves a majority", 3, []step{
+            {admin: 0, grant: true},
+            {admin: 1},
+            {admin: 0, remove: []int{1, 2}},
+            {admin: 2, grant: true},
+        }, Approved, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s, admins := newTestStateWithAdmins(t, RegistryPolicy{}, tt.admins)
+            citizen, id := s.reach("Citizen", Suspended)
+            s.mustApply(signedBy(t, citizen)(NewCitizenAppealTx(citizen.Public, "I did nothing wrong", s.ctx.Timestamp)))
+            for _, step := range tt.steps {
+                admin := admins[step.admin]
+                if step.remove == nil {
+                    s.mustApply(signedBy(t, admin)(NewAppealDecisionTx(admin.Public, id, step.grant, s.ctx.Timestamp)))
+                    continue
+                }
+                proposalID, err := s.proposeAdminChange(admin, AdminRemove, admins[step.remove[0]].Public)
+                if err != nil {
+                    t.Fatal(err)
+                }
+                for _, i := range step.remove[1:] {
+                    if err := s.approveAdminChange(admins[i], proposalID); err != nil {
+                        t.Fatal(err)
+                    }
+                }
+            }
+
+            record, _ := s.citizenRegistry.GetCitizenByID(id)
+            if record.Status != tt.want || (record.Appeal != nil) != tt.open {
+                t.Fatalf("status = %v, open appeal = %v, want %v and %v", record.Status, record.Appeal != nil, tt.want, tt.open)
+            }
+        })
+    }
+}
+
+func TestAppealRules(t *testing.T) {
+    tests := []struct {
+        name string
+        act  func(s *testState, admins []testKey, citizen testKey, id string) error
+    }{
+        {"appeal while approved", func(s *testState, admins []testKey, citizen testKey, id string) error {
+            s.mustApply(signedBy(s.t, admins[0])(NewCitizenStatusChangeTx(admins[0].Public, TxCitizenReinstatement, id, "", false, s.ctx.Timestamp)))
+            return s.apply(signedBy(s.t, citizen)(NewCitizenAppealTx(citizen.Public, "Statement", s.ctx.Timestamp)))
+        }},
+        {"appeal without a statement", func(s *testState, admins []testKey, citizen testKey, id string) error {
+            return s.apply(signedBy(s.t, citizen)(NewCitizenAppealTx(citizen.Public, "", s.ctx.Timestamp)))
+        }},
+        {"decision without an appeal", func(s *testState, admins []testKey, citizen testKey, id string) error {
+            return s.apply(signedBy(s.t, admins[0])(NewAppealDecisionTx(admins[0].Public, id, true, s.ctx.Timestamp)))
+        }},
+        {"decision by a citizen", func(s *testState, admins []testKey, citizen testKey, id string) error {
+            s.mustApply(signedBy(s.t, citizen)(NewCitizenAppealTx(citizen.Public, "Statement", s.ctx.Timestamp)))
+            return s.apply(signedBy(s.t, citizen)(NewAppealDecisionTx(citizen.Public, id, true, s.ctx.Timestamp)))
+        }},
+        {"admin decides twice", func(s *testState, admins []testKey, citizen testKey, id string) error {
+            s.mustApply(signedBy(s.t, citizen)(NewCitizenAppealTx(citizen.Public, "Statement", s.ctx.Timestamp)))
+            s.mustApply(signedBy(s.t, admins[1])(NewAppealDecisionTx(admins[1].Public, id, true, s.ctx.Timestamp)))
+            return s.apply(signedBy(s.t, admins[1])(NewAppealDecisionTx(admins[1].Public, id, true, s.ctx.Timestamp)))
+        }},
+        {"decision after a status change", func(s *testState, admins []testKey, citizen testKey, id string) error {
+            s.mustApply(signedBy(s.t, citizen)(NewCitizenAppealTx(citizen.Public, "Statement", s.ctx.Timestamp)))
+            s.mustApply(signedBy(s.t, admins[0])(NewCitizenStatusChangeTx(admins[0].Public, TxCitizenRevocation, id, "Decision", false, s.ctx.Timestamp)))
+            return s.apply(signedBy(s.t, admins[1])(NewAppealDecisionTx(admins[1].Public, id, true, s.ctx.Timestamp)))
+        }},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s, admins := newTestStateWithAdmins(t, RegistryPolicy{}, 3)
+            citizen, id := s.reach("Citizen", Suspended)
+            if err := tt.act(s, admins, citizen, id); err == nil {
+                t.Error("action was accepted")
+            }
+        })
+    }
+}
+
+func TestNewDecisionMayBeAppealed(t *testing.T) {
+    s, admins := newTestStateWithAdmins(t, RegistryPolicy{}, 3)
+    citizen, id := s.reach("Citizen", Suspended)
+    s.mustApply(signedBy(t, citizen)(NewCitizenAppealTx(citizen.Public, "Statement", s.ctx.Timestamp)))
+    for _, admin := range admins[:2] {
+        s.mustApply(signedBy(t, admin)(NewAppealDecisionTx(admin.Public, id, false, s.ctx.Timestamp)))
+    }
+    s.mustApply(signedBy(t, s.admin)(NewCitizenStatusChangeTx(s.admin.Public, TxCitizenRevocation, id, "Decision", false, s.ctx.Timestamp)))
+    s.mustApply(signedBy(t, citizen)(NewCitizenAppealTx(citizen.Public, "Against the revocation", s.ctx.Timestamp)))
+    if appeals := s.citizenRegistry.GetOpenAppeals(); len(appeals) != 1 || appeals[0].Appeal.Against != Revoked {
+        t.Errorf("open appeals = %v, want one against the revocation", appeals)
+    }
+}
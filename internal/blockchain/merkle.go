@@ -0,0 +1,179 @@
+package blockchain
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "fmt"
+)
+
+// The transaction tree follows RFC 6962: leaves and interior nodes are
+// hashed with distinct prefixes, and a tree of n leaves splits at the
+// largest power of two below n, so no two different transaction lists share
+// a root.
+const (
+    merkleLeafPrefix = 0x00
+    merkleNodePrefix = 0x01
+)
+
+// MerkleProof proves that a transaction is included in a block: the path of
+// sibling hashes leads from the transaction's leaf to the TxRoot of Header
+type MerkleProof struct {
+    Header   BlockHeader `json:"header"`
+    TxIndex  int         `json:"txIndex"`  // position of the transaction in the block
+    TreeSize int         `json:"treeSize"` // number of transactions in the block
+    Path     [][]byte    `json:"path"`     // sibling hashes, from the leaf up
+}
+
+// TransactionLeaf returns the Merkle leaf hash of a transaction. It covers
+// the canonical encoding and the signature.
+func TransactionLeaf(tx *Transaction) ([]byte, error) {
+    encoded, err := encodeTransaction(tx)
+    if err != nil {
+        return nil, err
+    }
+
+    var e encoder
+    e.buf.WriteByte(merkleLeafPrefix)
+    e.bytes(encoded)
+    e.string(tx.Signature)
+    hash := sha256.Sum256(e.buf.Bytes())
+    return hash[:], nil
+}
+
+func merkleNode(left, right []byte) []byte {
+    h := sha256.New()
+    h.Write([]byte{merkleNodePrefix})
+    h.Write(left)
+    h.Write(right)
+    return h.Sum(nil)
+}
+
+// splitPoint returns the largest power of two smaller than n, for n > 1
+func splitPoint(n int) int {
+    k := 1
+    for k<<1 < n {
+        k <<= 1
+    }
+    return k
+}
+
+// merkleRoot returns the root over leaves; the root of no leaves is the hash
+// of the empty string
+func merkleRoot(leaves [][]byte) []byte {
+    switch len(leaves) {
+    case 0:
+        empty := sha256.Sum256(nil)
+        return empty[:]
+    case 1:
+        return leaves[0]
+    }
+    k := splitPoint(len(leaves))
+    return merkleNode(merkleRoot(leaves[:k]), merkleRoot(leaves[k:]))
+}
+
+// merklePath returns the sibling hashes proving leaves[index], leaf first
+func merklePath(leaves [][]byte, index int) [][]byte {
+    if len(leaves) <= 1 {
+        return [][]byte{}
+    }
+    k := splitPoint(len(leaves))
+    if index < k {
+        return append(merklePath(leaves[:k], index), merkleRoot(leaves[k:]))
+    }
+    return append(merklePath(leaves[k:], index-k), merkleRoot(leaves[:k]))
+}
+
+// transactionLeaves returns the leaf hashes of a block's transactions
+func transactionLeaves(transactions []Transaction) ([][]byte, error) {
+    leaves := make([][]byte, len(transactions))
+    for i := range transactions {
+        leaf, err := TransactionLeaf(&transactions[i])
+        if err != nil {
+            return nil, fmt.Errorf("transaction %d: %v", i, err)
+        }
+        leaves[i] = leaf
+    }
+    return leaves, nil
+}
+
+// computeTxRoot returns the Merkle root of a block's transactions
+func computeTxRoot(transactions []Transaction) ([]byte, error) {
+    leaves, err := transactionLeaves(transactions)
+    if err != nil {
+        return nil, err
+    }
+    return merkleRoot(leaves), nil
+}
+
+// ProveTransaction returns the inclusion proof of the transaction with the
+// given ID, or false if the block does not contain it
+func (b *Block) ProveTransaction(txID string) (*MerkleProof, bool, error) {
+    if b.Version < 2 {
+        return nil, false, fmt.Errorf("block %d predates transaction roots", b.Index)
+    }
+
+    leaves, err := transactionLeaves(b.Transactions)
+    if err != nil {
+        return nil, false, err
+    }
+    for i := range b.Transactions {
+        if b.Transactions[i].ID == txID {
+            return &MerkleProof{
+                Header:   b.BlockHeader,
+                TxIndex:  i,
+                TreeSize: len(leaves),
+                Path:     merklePath(leaves, i),
+            }, true, nil
+        }
+    }
+    return nil, false, nil
+}
+
+// VerifyMerkleProof checks that tx is included in the block whose header
+// hashes to headerHash. A client that trusts headerHash, for example from a
+// commit certificate or several independent nodes, needs nothing else from
+// the block.
+func VerifyMerkleProof(tx *Transaction, proof *MerkleProof, headerHash []byte) error {
+    hash, err := hashBlockHeader(&proof.Header)
+    if err != nil {
+        return err
+    }
+    if !bytes.Equal(hash, headerHash) {
+        return fmt.Errorf("proof header does not match the block hash")
+    }
+    if proof.TxIndex < 0 || proof.TxIndex >= proof.TreeSize {
+        return fmt.Errorf("transaction index %d is outside a tree of %d leaves", proof.TxIndex, proof.TreeSize)
+    }
+
+    leaf, err := TransactionLeaf(tx)
+    if err != nil {
+        return err
+    }
+
+    // RFC 9162 section 2.1.3.2
+    fn, sn := proof.TxIndex, proof.TreeSize-1
+    node := leaf
+    for _, sibling := range proof.Path {
+        if sn == 0 {
+            return fmt.Errorf("proof path is too long")
+        }
+        if fn&1 == 1 || fn == sn {
+            node = merkleNode(sibling, node)
+            for fn&1 == 0 && fn != 0 {
+                fn >>= 1
+                sn >>= 1
+            }
+        } else {
+            node = merkleNode(node, sibling)
+        }
+        fn >>= 1
+        sn >>= 1
+    }
+    if sn != 0 {
+        return fmt.Errorf("proof path is too short")
+    }
+    if !bytes.Equal(node, proof.Header.TxRoot) {
+        return fmt.Errorf("transaction is not included under the block's transaction root")
+    }
+    return nil
+}
@@ -0,0 +1,117 @@
+package blockchain
+
+import (
+    "bytes"
+    "fmt"
+    "testing"
+)
+
+// merkleBlock returns a block holding n golden transactions
+func merkleBlock(t *testing.T, n int) *Block {
+    t.Helper()
+    transactions := make([]Transaction, 0, n)
+    for i := 0; i < n; i++ {
+        transactions = append(transactions, *vectorTransaction(t, fmt.Sprint("merkle-", i)))
+    }
+    return newBlockAt(1, testStartTime, transactions, make([]byte, 32))
+}
+
+func TestMerkleProofEveryLeaf(t *testing.T) {
+    for n := 1; n <= 9; n++ {
+        block := merkleBlock(t, n)
+        for i := range block.Transactions {
+            tx := &block.Transactions[i]
+            proof, found, err := block.ProveTransaction(tx.ID)
+            if err != nil || !found {
+                t.Fatalf("%d of %d: found = %v, err = %v", i, n, found, err)
+            }
+            if err := VerifyMerkleProof(tx, proof, block.Hash); err != nil {
+                t.Errorf("%d of %d: %v", i, n, err)
+            }
+        }
+    }
+}
+
+func TestMerkleProofRejectsTampering(t *testing.T) {
+    other := merkleBlock(t, 2)
+    tests := []struct {
+        name   string
+        tamper func(tx *Transaction, proof *MerkleProof, hash *[]byte)
+    }{
+        {"other transaction", func(tx *Transaction, proof *MerkleProof, hash *[]byte) {
+            *tx = other.Transactions[0]
+        }},
+        {"resigned transaction", func(tx *Transaction, proof *MerkleProof, hash *[]byte) {
+            tx.Signature = other.Transactions[0].Signature
+        }},
+        {"flipped sibling", func(tx *Transaction, proof *MerkleProof, hash *[]byte) {
+            proof.Path[0] = bytes.Clone(proof.Path[0])
+            proof.Path[0][0] ^= 1
+        }},
+        {"path too short", func(tx *Transaction, proof *MerkleProof, hash *[]byte) {
+            proof.Path = proof.Path[:len(proof.Path)-1]
+        }},
+        {"path too long", func(tx *Transaction, proof *MerkleProof, hash *[]byte) {
+            proof.Path = append(proof.Path, proof.Path[0])
+        }},
+        {"wrong index", func(tx *Transaction, proof *MerkleProof, hash *[]byte) {
+            proof.TxIndex ^= 1
+        }},
+        {"index outside the tree", func(tx *Transaction, proof *MerkleProof, hash *[]byte) {
+            proof.TxIndex = proof.TreeSize
+        }},
+        {"negative index", func(tx *Transaction, proof *MerkleProof, hash *[]byte) {
+            proof.TxIndex = -1
+        }},
+        {"smaller tree", func(tx *Transaction, proof *MerkleProof, hash *[]byte) {
+            proof.TreeSize = 3
+        }},
+        {"forged root", func(tx *Transaction, proof *MerkleProof, hash *[]byte) {
+            proof.Header.TxRoot = other.TxRoot
+        }},
+        {"forged root with matching hash", func(tx *Transaction, proof *MerkleProof, hash *[]byte) {
+            proof.Header.TxRoot = other.TxRoot
+            *hash, _ = hashBlockHeader(&proof.Header)
+            *tx = other.Transactions[1]
+        }},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            block := merkleBlock(t, 5)
+            tx := block.Transactions[2]
+            proof, _, err := block.ProveTransaction(tx.ID)
+            if err != nil {
+                t.Fatal(err)
+            }
+            hash := block.Hash
+            tt.tamper(&tx, proof, &hash)
+            if err := VerifyMerkleProof(&tx, proof, hash); err == nil {
+                t.Error("tampered proof was accepted")
+            }
+        })
+    }
+}
+
+func TestTxRootDistinguishesTransactionLists(t *testing.T) {
+    block := merkleBlock(t, 3)
+    tests := []struct {
+        name         string
+        transactions []Transaction
+    }{
+        {"fewer", block.Transactions[:2]},
+        {"reordered", []Transaction{block.Transactions[1], block.Transactions[0], block.Transactions[2]}},
+        {"last repeated", append(block.Transactions[:3:3], block.Transactions[2])},
+        {"empty", nil},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            root, err := computeTxRoot(tt.transactions)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if bytes.Equal(root, block.TxRoot) {
+                t.Error("different transactions share the root")
+            }
+        })
+    }
+}
@@ -0,0 +1,141 @@
+package blockchain
+
+import (
+    "errors"
+    "slices"
+)
+
+// EndorseCandidate records an approved citizen's endorsement of a nominee
+// while nominations are open. A nominee with as many endorsements as the
+// election requires goes on the ballot.
+func (es *ElectionSystem) EndorseCandidate(electionID, candidateID, endorserKey string) error {
+    es.mu.Lock()
+    defer es.mu.Unlock()
+
+    election, err := es.get(electionID)
+    if err != nil {
+        return err
+    }
+    if !election.nominating() {
+        return errors.New("nominations are closed")
+    }
+    if !es.citizenRegistry.IsCitizen(endorserKey) {
+        return errors.New("endorser must be an approved citizen")
+    }
+
+    index := slices.IndexFunc(election.Nominations, func(nominee Candidate) bool {
+        return nominee.ID == candidateID
+    })
+    if index < 0 {
+        if election.hasCandidate(candidateID) {
+            return errors.New("candidate is already on the ballot")
+        }
+        return errors.New("nominee not found")
+    }
+    nominee := &election.Nominations[index]
+    if nominee.PublicKey == endorserKey {
+        return errors.New("candidates cannot endorse themselves")
+    }
+    if slices.Contains(nominee.Endorsers, endorserKey) {
+        return errors.New("citizen has already endorsed this candidate")
+    }
+    if len(nominee.Endorsers)+1 >= election.Endorsements && len(election.Ballots) > 0 {
+        return errors.New("candidates cannot join the ballot once encrypted ballots have been cast")
+    }
+
+    nominee.Endorsers = append(nominee.Endorsers, endorserKey)
+    if len(nominee.Endorsers) >= election.Endorsements {
+        election.Candidates = append(election.Candidates, *nominee)
+        election.Nominations = slices.Delete(election.Nominations, index, index+1)
+    }
+    return nil
+}
+
+// WithdrawCandidate takes the candidate standing with candidateKey off the
+// ballot, or out of the nominations, before voting opens. In an election
+// without a nomination phase candidates may withdraw until the first
+// ballot is cast.
+func (es *ElectionSystem) WithdrawCandidate(electionID, candidateKey string) error {
+    es.mu.Lock()
+    defer es.mu.Unlock()
+
+    election, err := es.get(electionID)
+    if err != nil {
+        return err
+    }
+    if !election.withdrawable() {
+        return errors.New("candidates cannot withdraw once voting has opened")
+    }
+    if !election.withdraw(candidateKey) {
+        return errors.New("not a candidate in this election")
+    }
+    return nil
+}
+
+// disqualify withdraws a citizen who lost their candidacy rights from every
+// election they may still withdraw from. Where voting has opened they stay
+// on the ballot, and the ballots cast for them stand, but they are
+// disqualified when the ballots are counted unless reinstated by then.
+func (es *ElectionSystem) disqualify(publicKey string) {
+    es.mu.Lock()
+    defer es.mu.Unlock()
+
+    for _, id := range es.order {
+        if election := es.elections[id]; election.withdrawable() {
+            election.withdraw(publicKey)
+        }
+    }
+}
+
+// disqualifyIneligible marks the candidates of the election who are no
+// longer approved citizens as disqualified, so that the count passes them
+// over. A referendum's choices stand for no citizen and are never
+// disqualified.
+func (es *ElectionSystem) disqualifyIneligible(election *Election) {
+    for i := range election.Candidates {
+        candidate := &election.Candidates[i]
+        candidate.Disqualified = candidate.PublicKey != "" && !es.citizenRegistry.IsCitizen(candidate.PublicKey)
+    }
+}
+
+// withdrawable reports whether candidates may still leave the election
+func (e *Election) withdrawable() bool {
+    return e.nominating() && !e.ballotsReceived()
+}
+
+// withdraw moves the candidate standing with publicKey, on the ballot or
+// among the nominations, to the withdrawn candidates
+func (e *Election) withdraw(publicKey string) bool {
+    byKey := func(candidate Candidate) bool {
+        return candidate.PublicKey == publicKey
+    }
+    if index := slices.IndexFunc(e.Candidates, byKey); index >= 0 {
+        e.Withdrawn = append(e.Withdrawn, e.Candidates[index])
+        e.Candidates = slices.Delete(e.Candidates, index, index+1)
+        return true
+    }
+    if index := slices.IndexFunc(e.Nominations, byKey); index >= 0 {
+        e.Withdrawn = append(e.Withdrawn, e.Nominations[index])
+        e.Nominations = slices.Delete(e.Nominations, index, index+1)
+        return true
+    }
+    return false
+}
+
+// standing reports whether publicKey has stood in the election, whether
+// on the ballot, awaiting endorsements or withdrawn
+func (e *Election) standing(publicKey string) bool {
+    for _, list := range [][]Candidate{e.Candidates, e.Nominations, e.Withdrawn} {
+        for _, candidate := range list {
+            if candidate.PublicKey == publicKey {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// ballotsReceived reports whether any ballot, sealed or not, has been cast
+func (e *Election) ballotsReceived() bool {
+    return e.ballotsCast() > 0 || len(e.Commitments) > 0
+}
@@ -0,0 +1,216 @@
+package blockchain
+
+import (
+    "slices"
+    "testing"
+)
+
+func TestEndorsements(t *testing.T) {
+    type endorsement struct {
+        by string // "nominee", a citizen's name, or "stranger" for a non-citizen
+        ok bool
+    }
+    tests := []struct {
+        name         string
+        endorsements []endorsement
+        onBallot     bool
+    }{
+        {"none", nil, false},
+        {"one of two", []endorsement{{"Alice", true}}, false},
+        {"two of two", []endorsement{{"Alice", true}, {"Bob", true}}, true},
+        {"self-endorsement", []endorsement{{"Alice", true}, {"nominee", false}}, false},
+        {"endorsed twice", []endorsement{{"Alice", true}, {"Alice", false}}, false},
+        {"by a non-citizen", []endorsement{{"Alice", true}, {"stranger", false}}, false},
+        {"after joining the ballot", []endorsement{{"Alice", true}, {"Bob", true}, {"Carol", false}}, true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestState(t, RegistryPolicy{})
+            citizens := map[string]testKey{"stranger": newTestKey(t)}
+            for _, name := range []string{"Alice", "Bob", "Carol"} {
+                citizens[name], _ = s.citizen(name)
+            }
+            id := s.startElection(ElectionOptions{NominationDays: 3, Endorsements: 2})
+            nominee, candidateID := s.candidate(id, "Nominee")
+            citizens["nominee"] = nominee
+
+            for _, e := range tt.endorsements {
+                key := citizens[e.by]
+                err := s.apply(signedBy(t, key)(NewCandidateEndorsementTx(id, candidateID, key.Public, s.ctx.Timestamp)))
+                if (err == nil) != e.ok {
+                    t.Fatalf("endorsement by %s: error = %v, want ok = %v", e.by, err, e.ok)
+                }
+            }
+
+            election := s.election(id)
+            if onBallot := election.hasCandidate(candidateID); onBallot != tt.onBallot {
+                t.Errorf("on ballot = %v, want %v", onBallot, tt.onBallot)
+            }
+            if nominated := len(election.Nominations) == 1; nominated == tt.onBallot {
+                t.Errorf("%d nominations, want the nominee in exactly one list", len(election.Nominations))
+            }
+        })
+    }
+}
+
+func TestEndorsementsCloseWithNominations(t *testing.T) {
+    s := newTestState(t, RegistryPolicy{})
+    alice, _ := s.citizen("Alice")
+    id := s.startElection(ElectionOptions{NominationDays: 1, Endorsements: 1})
+    _, candidateID := s.candidate(id, "Nominee")
+    s.advance(secondsPerDay)
+
+    if err := s.apply(signedBy(t, alice)(NewCandidateEndorsementTx(id, candidateID, alice.Public, s.ctx.Timestamp))); err == nil {
+        t.Error("endorsement was accepted after nominations closed")
+    }
+    late := newTestKey(t)
+    if err := s.apply(signedBy(t, late)(NewCandidateRegistrationTx(id, "Late", late.Public, "", s.ctx.Timestamp))); err == nil {
+        t.Error("candidate registered after nominations closed")
+    }
+}
+
+func TestWithdrawal(t *testing.T) {
+    tests := []struct {
+        name    string
+        options ElectionOptions
+        before  func(s *testState, voter testKey, id, candidateID string) // runs before the withdrawal
+        ok      bool
+    }{
+        {"during nominations", ElectionOptions{NominationDays: 1}, nil, true},
+        {"while awaiting endorsements", ElectionOptions{NominationDays: 1, Endorsements: 1}, nil, true},
+        {"after voting opens", ElectionOptions{NominationDays: 1}, func(s *testState, voter testKey, id, candidateID string) {
+            s.advance(secondsPerDay)
+        }, false},
+        {"before the first ballot", ElectionOptions{}, nil, true},
+        {"after the first ballot", ElectionOptions{}, func(s *testState, voter testKey, id, candidateID string) {
+            s.mustApply(signedBy(s.t, voter)(NewVoteTx(id, voter.Public, candidateID, s.ctx.Timestamp)))
+        }, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestState(t, RegistryPolicy{})
+            voter, _ := s.citizen("Voter")
+            id := s.startElection(tt.options)
+            candidate, candidateID := s.candidate(id, "Candidate")
+            if tt.before != nil {
+                tt.before(s, voter, id, candidateID)
+            }
+
+            err := s.apply(signedBy(t, candidate)(NewCandidateWithdrawalTx(id, candidate.Public, s.ctx.Timestamp)))
+            if (err == nil) != tt.ok {
+                t.Fatalf("withdrawal error = %v, want ok = %v", err, tt.ok)
+            }
+            election := s.election(id)
+            withdrawn := slices.ContainsFunc(election.Withdrawn, func(c Candidate) bool { return c.ID == candidateID })
+            if withdrawn != tt.ok {
+                t.Fatalf("withdrawn = %v, want %v", withdrawn, tt.ok)
+            }
+            if !tt.ok {
+                return
+            }
+            if election.hasCandidate(candidateID) || len(election.Nominations) > 0 {
+                t.Error("withdrawn candidate is still standing")
+            }
+            // A withdrawn candidate cannot stand again
+            if err := s.apply(signedBy(t, candidate)(NewCandidateRegistrationTx(id, "Candidate", candidate.Public, "", s.ctx.Timestamp))); err == nil {
+                t.Error("withdrawn candidate registered again")
+            }
+        })
+    }
+}
+
+func TestSuspensionWithdrawsCandidate(t *testing.T) {
+    s := newTestState(t, RegistryPolicy{})
+    id := s.startElection(ElectionOptions{NominationDays: 1})
+    candidate, candidateID := s.candidate(id, "Candidate")
+    citizen, _ := s.citizenRegistry.GetCitizen(candidate.Public)
+
+    s.mustApply(signedBy(t, s.admin)(NewCitizenStatusChangeTx(s.admin.Public, TxCitizenSuspension, citizen.ID, "Under investigation", false, s.ctx.Timestamp)))
+    election := s.election(id)
+    if election.hasCandidate(candidateID) || len(election.Withdrawn) != 1 {
+        t.Error("suspended candidate stayed on the ballot")
+    }
+}
+
+func TestSuspendedCandidateCannotWin(t *testing.T) {
+    tests := []struct {
+        name      string
+        options   ElectionOptions
+        change    string // status change of the leading candidate after the ballots are cast
+        reinstate bool   // whether they are reinstated before the count
+        winner    string
+    }{
+        {"plurality", ElectionOptions{}, "", false, "Leader"},
+        {"suspended", ElectionOptions{}, TxCitizenSuspension, false, "Runner-up"},
+        {"revoked", ElectionOptions{}, TxCitizenRevocation, false, "Runner-up"},
+        {"reinstated", ElectionOptions{}, TxCitizenSuspension, true, "Leader"},
+        {"instant-runoff", ElectionOptions{Method: MethodInstantRunoff}, TxCitizenSuspension, false, "Runner-up"},
+        {"approval", ElectionOptions{Method: MethodApproval}, TxCitizenSuspension, false, "Runner-up"},
+        {"secret ballot", ElectionOptions{SecretBallot: true}, TxCitizenSuspension, false, "Runner-up"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestState(t, RegistryPolicy{})
+            var voters []testKey
+            for _, name := range []string{"Alice", "Bob", "Carol"} {
+                key, _ := s.citizen(name)
+                voters = append(voters, key)
+            }
+            id := s.startElection(tt.options)
+            leader, leaderID := s.candidate(id, "Leader")
+            _, runnerUpID := s.candidate(id, "Runner-up")
+            s.advance(10)
+
+            // Two ballots put the leader first, one the runner-up
+            var reveals []func()
+            for i, voter := range voters {
+                first, second := leaderID, runnerUpID
+                if i == 2 {
+                    first, second = runnerUpID, leaderID
+                }
+                ballot := Ballot{CandidateID: first}
+                switch tt.options.Method {
+                case MethodInstantRunoff:
+                    ballot = Ballot{Ranking: []string{first, second}}
+                case MethodApproval:
+                    ballot = Ballot{Approvals: []string{first}}
+                }
+                if !tt.options.SecretBallot {
+                    s.mustApply(signedBy(t, voter)(NewBallotTx(id, voter.Public, ballot, s.ctx.Timestamp)))
+                    continue
+                }
+                commitment, salt, err := NewBallot(id, voter.Public, tt.options.method(), &ballot)
+                if err != nil {
+                    t.Fatal(err)
+                }
+                s.mustApply(signedBy(t, voter)(NewVoteCommitTx(id, voter.Public, commitment, s.ctx.Timestamp)))
+                reveals = append(reveals, func() {
+                    s.mustApply(signedBy(t, voter)(NewVoteRevealTx(id, voter.Public, ballot, salt, s.ctx.Timestamp)))
+                })
+            }
+
+            citizen, _ := s.citizenRegistry.GetCitizen(leader.Public)
+            if tt.change != "" {
+                s.mustApply(signedBy(t, s.admin)(NewCitizenStatusChangeTx(s.admin.Public, tt.change, citizen.ID, "Under investigation", false, s.ctx.Timestamp)))
+            }
+            if tt.reinstate {
+                s.mustApply(signedBy(t, s.admin)(NewCitizenStatusChangeTx(s.admin.Public, TxCitizenReinstatement, citizen.ID, "", false, s.ctx.Timestamp)))
+            }
+            if tt.options.SecretBallot {
+                s.mustApply(signedBy(t, s.admin)(NewVotingCloseTx(s.admin.Public, id, s.ctx.Timestamp)))
+                for _, reveal := range reveals {
+                    reveal()
+                }
+            }
+            s.mustApply(signedBy(t, s.admin)(NewElectionEndTx(s.admin.Public, id, s.ctx.Timestamp)))
+
+            election := s.election(id)
+            if election.Winner == nil || election.Winner.Name != tt.winner {
+                t.Fatalf("winner = %v, want %s", election.Winner, tt.winner)
+            }
+            if disqualified := election.Candidates[0].Disqualified; disqualified != (tt.winner != "Leader") {
+                t.Errorf("leader disqualified = %v", disqualified)
+            }
+        })
+    }
+}
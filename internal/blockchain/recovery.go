@@ -0,0 +1,219 @@
+package blockchain
+
+import (
+    "errors"
+    "slices"
+)
+
+// Guardians are the citizens a citizen trusts to rebind their identity to
+// a new key if they lose theirs. Threshold of them must support the same
+// new key.
+type Guardians struct {
+    IDs        []string            `json:"ids"` // citizen IDs
+    Threshold  int                 `json:"threshold"`
+    Recoveries map[string][]string `json:"recoveries,omitempty"` // proposed key -> IDs of the guardians supporting it
+}
+
+// SetGuardians replaces the guardians of the citizen holding publicKey.
+// Recoveries the earlier guardians started are dropped.
+func (cr *CitizenRegistry) SetGuardians(publicKey string, guardianIDs []string, threshold int, ctx BlockContext) error {
+    cr.mu.Lock()
+    defer cr.mu.Unlock()
+
+    citizen, exists := cr.citizens[publicKey]
+    if !exists {
+        return errors.New("citizen not found")
+    }
+    if len(guardianIDs) == 0 {
+        return errors.New("at least one guardian is required")
+    }
+    if threshold < 1 || threshold > len(guardianIDs) {
+        return errors.New("threshold must be between 1 and the number of guardians")
+    }
+    for i, id := range guardianIDs {
+        guardian := cr.byID(id)
+        if guardian == nil || guardian.Status != Approved {
+            return errors.New("guardians must be approved citizens")
+        }
+        if id == citizen.ID {
+            return errors.New("citizens cannot be their own guardians")
+        }
+        if slices.Contains(guardianIDs[:i], id) {
+            return errors.New("guardian listed twice")
+        }
+    }
+
+    citizen.Guardians = &Guardians{
+        IDs:       append([]string(nil), guardianIDs...),
+        Threshold: threshold,
+    }
+    citizen.record(ActionGuardiansSet, publicKey, "", ctx)
+    return nil
+}
+
+// RotateKey moves the identity of the citizen holding oldKey to newKey
+func (cr *CitizenRegistry) RotateKey(oldKey, newKey string, ctx BlockContext) error {
+    cr.mu.Lock()
+    defer cr.mu.Unlock()
+
+    citizen, exists := cr.citizens[oldKey]
+    if !exists {
+        return errors.New("citizen not found")
+    }
+    if err := cr.checkNewKey(newKey); err != nil {
+        return err
+    }
+
+    cr.rebind(citizen, newKey)
+    citizen.record(ActionKeyRotated, oldKey, "", ctx)
+    return nil
+}
+
+// SupportRecovery records a guardian's support for moving a citizen who
+// lost their key to newKey. The identity moves once enough of the
+// citizen's guardians, still approved citizens, support the same key.
+func (cr *CitizenRegistry) SupportRecovery(citizenID, guardianKey, newKey string, ctx BlockContext) error {
+    cr.mu.Lock()
+    defer cr.mu.Unlock()
+
+    citizen := cr.byID(citizenID)
+    if citizen == nil {
+        return errors.New("citizen not found")
+    }
+    guardians := citizen.Guardians
+    guardian, exists := cr.citizens[guardianKey]
+    if guardians == nil || !exists || !slices.Contains(guardians.IDs, guardian.ID) {
+        return errors.New("not a guardian of this citizen")
+    }
+    if guardian.Status != Approved {
+        return errors.New("guardian must be an approved citizen")
+    }
+    if err := cr.checkNewKey(newKey); err != nil {
+        return err
+    }
+    if slices.Contains(guardians.Recoveries[newKey], guardian.ID) {
+        return errors.New("guardian already supports this recovery")
+    }
+
+    if guardians.Recoveries == nil {
+        guardians.Recoveries = make(map[string][]string)
+    }
+    guardians.Recoveries[newKey] = append(guardians.Recoveries[newKey], guardian.ID)
+
+    support := 0
+    for _, id := range guardians.Recoveries[newKey] {
+        if supporter := cr.byID(id); supporter != nil && supporter.Status == Approved {
+            support++
+        }
+    }
+    if support >= guardians.Threshold {
+        cr.rebind(citizen, newKey)
+        citizen.record(ActionKeyRecovered, guardianKey, "", ctx)
+    }
+    return nil
+}
+
+// checkNewKey verifies that a key is well-formed and has never belonged
+// to a citizen
+func (cr *CitizenRegistry) checkNewKey(publicKey string) error {
+    if err := ValidatePublicKey(publicKey); err != nil {
+        return err
+    }
+    if cr.keyUsed(publicKey) {
+        return errors.New("key is already in use")
+    }
+    return nil
+}
+
+// keyUsed reports whether publicKey is, or was, a citizen's key. A retired
+// key is never reused, so it always identifies the same citizen.
+func (cr *CitizenRegistry) keyUsed(publicKey string) bool {
+    _, current := cr.citizens[publicKey]
+    _, retired := cr.retiredKeys[publicKey]
+    return current || retired
+}
+
+// rebind moves a citizen to newKey, retiring their current key. Pending
+// recoveries are dropped, since the citizen holds a working key again.
+func (cr *CitizenRegistry) rebind(citizen *Citizen, newKey string) {
+    delete(cr.citizens, citizen.PublicKey)
+    cr.retiredKeys[citizen.PublicKey] = citizen.ID
+    citizen.PreviousKeys = append(citizen.PreviousKeys, citizen.PublicKey)
+    citizen.PublicKey = newKey
+    if citizen.Guardians != nil {
+        citizen.Guardians.Recoveries = nil
+    }
+    cr.citizens[newKey] = citizen
+    cr.ids[citizen.ID] = newKey
+}
+
+// rekey carries a citizen's entries in every election still running over
+// from oldKey to newKey. Finished elections keep the key each ballot was
+// cast with, which the citizen's PreviousKeys tie to them.
+func (es *ElectionSystem) rekey(oldKey, newKey string) {
+    es.mu.Lock()
+    defer es.mu.Unlock()
+
+    for _, id := range es.order {
+        if election := es.elections[id]; !election.finished() {
+            election.rekey(oldKey, newKey)
+        }
+    }
+}
+
+// rekey moves the ballots, candidacies, endorsements and delegations of
+// oldKey to newKey
+func (e *Election) rekey(oldKey, newKey string) {
+    moveKey(e.Votes, oldKey, newKey)
+    moveKey(e.Commitments, oldKey, newKey)
+    moveKey(e.Rankings, oldKey, newKey)
+    moveKey(e.Approvals, oldKey, newKey)
+    moveKey(e.Scores, oldKey, newKey)
+    moveKey(e.Ballots, oldKey, newKey)
+    moveKey(e.Delegations, oldKey, newKey)
+    for delegator, delegate := range e.Delegations {
+        if delegate == oldKey {
+            e.Delegations[delegator] = newKey
+        }
+    }
+
+    for _, list := range [][]Candidate{e.Candidates, e.Nominations, e.Withdrawn} {
+        for i := range list {
+            candidate := &list[i]
+            if candidate.PublicKey == oldKey {
+                candidate.PublicKey = newKey
+            }
+            // Endorsers may be shared with an earlier state, so they are
+            // copied rather than changed in place
+            if index := slices.Index(candidate.Endorsers, oldKey); index >= 0 {
+                candidate.Endorsers = slices.Clone(candidate.Endorsers)
+                candidate.Endorsers[index] = newKey
+            }
+        }
+    }
+}
+
+// moveKey moves the entry of oldKey in m, if any, to newKey
+func moveKey[V any](m map[string]V, oldKey, newKey string) {
+    if value, exists := m[oldKey]; exists {
+        delete(m, oldKey)
+        m[newKey] = value
+    }
+}
+
+// opensCommitment reports whether a reveal by the citizen holding
+// citizenPublicKey opens commitment. A citizen who changed keys since
+// committing reveals with their current key a ballot committed with an
+// earlier one.
+func (es *ElectionSystem) opensCommitment(electionID, citizenPublicKey, choice, salt, commitment string) bool {
+    keys := []string{citizenPublicKey}
+    if citizen, exists := es.citizenRegistry.GetCitizen(citizenPublicKey); exists {
+        keys = append(keys, citizen.PreviousKeys...)
+    }
+    for _, key := range keys {
+        if BallotCommitment(electionID, key, choice, salt) == commitment {
+            return true
+        }
+    }
+    return false
+}
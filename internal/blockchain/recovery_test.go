@@ -0,0 +1,266 @@
+package blockchain
+
+import (
+    "slices"
+    "testing"
+)
+
+// rotate has the citizen holding key move to newKey
+func (s *testState) rotate(key testKey, newKey string) error {
+    s.t.Helper()
+    return s.apply(signedBy(s.t, key)(NewKeyRotationTx(key.Public, newKey, s.ctx.Timestamp)))
+}
+
+// guard has the citizen holding key name the guardians with the given IDs
+func (s *testState) guard(key testKey, guardianIDs []string, threshold int) error {
+    s.t.Helper()
+    return s.apply(signedBy(s.t, key)(NewGuardianDesignationTx(key.Public, guardianIDs, threshold, s.ctx.Timestamp)))
+}
+
+// recover has a guardian support moving a citizen to newKey
+func (s *testState) recover(guardian testKey, citizenID, newKey string) error {
+    s.t.Helper()
+    return s.apply(signedBy(s.t, guardian)(NewKeyRecoveryTx(guardian.Public, citizenID, newKey, s.ctx.Timestamp)))
+}
+
+func TestKeyRotation(t *testing.T) {
+    tests := []struct {
+        name string
+        // newKey returns the key to rotate to, given the citizen's key and
+        // another citizen's
+        newKey func(s *testState, own, other testKey) string
+        ok     bool
+    }{
+        {"fresh key", func(s *testState, own, other testKey) string { return newTestKey(s.t).Public }, true},
+        {"own key", func(s *testState, own, other testKey) string { return own.Public }, false},
+        {"another citizen's key", func(s *testState, own, other testKey) string { return other.Public }, false},
+        {"malformed key", func(s *testState, own, other testKey) string { return "abcd" }, false},
+        {"another citizen's retired key", func(s *testState, own, other testKey) string {
+            if err := s.rotate(other, newTestKey(s.t).Public); err != nil {
+                s.t.Fatal(err)
+            }
+            return other.Public
+        }, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestState(t, RegistryPolicy{})
+            own, id := s.citizen("Citizen")
+            other, _ := s.citizen("Other")
+            newKey := tt.newKey(s, own, other)
+
+            err := s.rotate(own, newKey)
+            if (err == nil) != tt.ok {
+                t.Fatalf("rotation error = %v, want ok = %v", err, tt.ok)
+            }
+            citizen, _ := s.citizenRegistry.GetCitizenByID(id)
+            if rotated := slices.Contains(citizen.PreviousKeys, own.Public); rotated != tt.ok {
+                t.Errorf("key rotated = %v, want %v", rotated, tt.ok)
+            }
+        })
+    }
+}
+
+func TestRotatedKeyIsRetired(t *testing.T) {
+    s := newTestState(t, RegistryPolicy{})
+    old, id := s.citizen("Citizen")
+    current := newTestKey(t)
+    if err := s.rotate(old, current.Public); err != nil {
+        t.Fatal(err)
+    }
+
+    if s.citizenRegistry.IsCitizen(old.Public) {
+        t.Error("retired key still identifies a citizen")
+    }
+    if err := s.rotate(old, newTestKey(t).Public); err == nil {
+        t.Error("retired key rotated again")
+    }
+    if err := s.rotate(current, old.Public); err == nil {
+        t.Error("citizen rotated back to a retired key")
+    }
+    citizen, _ := s.citizenRegistry.GetCitizenByID(id)
+    if !slices.Equal(citizen.PreviousKeys, []string{old.Public}) {
+        t.Errorf("previous keys = %v, want the retired key", citizen.PreviousKeys)
+    }
+}
+
+func TestGuardianDesignation(t *testing.T) {
+    tests := []struct {
+        name      string
+        guardians func(ids map[string]string) []string
+        threshold int
+        ok        bool
+    }{
+        {"two of three", func(ids map[string]string) []string {
+            return []string{ids["First"], ids["Second"], ids["Third"]}
+        }, 2, true},
+        {"no guardians", func(ids map[string]string) []string { return nil }, 1, false},
+        {"zero threshold", func(ids map[string]string) []string { return []string{ids["First"]} }, 0, false},
+        {"threshold above the guardians", func(ids map[string]string) []string {
+            return []string{ids["First"], ids["Second"]}
+        }, 3, false},
+        {"self", func(ids map[string]string) []string { return []string{ids["First"], ids["Citizen"]} }, 1, false},
+        {"listed twice", func(ids map[string]string) []string { return []string{ids["First"], ids["First"]} }, 1, false},
+        {"pending guardian", func(ids map[string]string) []string { return []string{ids["First"], ids["Pending"]} }, 1, false},
+        {"unknown guardian", func(ids map[string]string) []string { return []string{"unknown"} }, 1, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestState(t, RegistryPolicy{})
+            ids := make(map[string]string)
+            var key testKey
+            key, ids["Citizen"] = s.citizen("Citizen")
+            for _, name := range []string{"First", "Second", "Third"} {
+                _, ids[name] = s.citizen(name)
+            }
+            _, ids["Pending"] = s.register("Pending")
+
+            if err := s.guard(key, tt.guardians(ids), tt.threshold); (err == nil) != tt.ok {
+                t.Errorf("designation error = %v, want ok = %v", err, tt.ok)
+            }
+        })
+    }
+}
+
+func TestRecovery(t *testing.T) {
+    tests := []struct {
+        name      string
+        threshold int
+        suspend   bool // whether the first supporter is suspended before the last supports
+        support   []int
+        recovered bool
+    }{
+        {"one of two required", 2, false, []int{0}, false},
+        {"two of three", 2, false, []int{0, 2}, true},
+        {"single guardian", 1, false, []int{1}, true},
+        {"suspended supporter", 2, true, []int{0, 1}, false},
+        {"suspended supporter replaced", 2, true, []int{0, 1, 2}, true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestState(t, RegistryPolicy{})
+            lost, id := s.citizen("Citizen")
+            var guardians []testKey
+            var guardianIDs []string
+            for _, name := range []string{"First", "Second", "Third"} {
+                key, guardianID := s.citizen(name)
+                guardians = append(guardians, key)
+                guardianIDs = append(guardianIDs, guardianID)
+            }
+            if err := s.guard(lost, guardianIDs, tt.threshold); err != nil {
+                t.Fatal(err)
+            }
+
+            newKey := newTestKey(t).Public
+            for i, guardian := range tt.support {
+                if tt.suspend && i == 1 {
+                    s.mustApply(signedBy(t, s.admin)(NewCitizenStatusChangeTx(s.admin.Public, TxCitizenSuspension, guardianIDs[tt.support[0]], "Under investigation", false, s.ctx.Timestamp)))
+                }
+                if err := s.recover(guardians[guardian], id, newKey); err != nil {
+                    t.Fatal(err)
+                }
+            }
+
+            citizen, _ := s.citizenRegistry.GetCitizenByID(id)
+            if recovered := citizen.PublicKey == newKey; recovered != tt.recovered {
+                t.Fatalf("recovered = %v, want %v", recovered, tt.recovered)
+            }
+            if s.citizenRegistry.IsCitizen(lost.Public) == tt.recovered {
+                t.Error("lost key is still valid after recovery, or retired without it")
+            }
+        })
+    }
+}
+
+func TestRecoveryRules(t *testing.T) {
+    tests := []struct {
+        name string
+        act  func(s *testState, guardian, stranger testKey, citizenID string) error
+    }{
+        {"support by a stranger", func(s *testState, guardian, stranger testKey, citizenID string) error {
+            return s.recover(stranger, citizenID, newTestKey(s.t).Public)
+        }},
+        {"support twice", func(s *testState, guardian, stranger testKey, citizenID string) error {
+            newKey := newTestKey(s.t).Public
+            s.recover(guardian, citizenID, newKey)
+            return s.recover(guardian, citizenID, newKey)
+        }},
+        {"key in use", func(s *testState, guardian, stranger testKey, citizenID string) error {
+            return s.recover(guardian, citizenID, stranger.Public)
+        }},
+        {"unknown citizen", func(s *testState, guardian, stranger testKey, citizenID string) error {
+            return s.recover(guardian, "unknown", newTestKey(s.t).Public)
+        }},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestState(t, RegistryPolicy{})
+            key, id := s.citizen("Citizen")
+            first, firstID := s.citizen("First")
+            _, secondID := s.citizen("Second")
+            stranger, _ := s.citizen("Stranger")
+            if err := s.guard(key, []string{firstID, secondID}, 2); err != nil {
+                t.Fatal(err)
+            }
+            if err := tt.act(s, first, stranger, id); err == nil {
+                t.Error("support was accepted")
+            }
+        })
+    }
+}
+
+func TestRecoveryRekeysBallots(t *testing.T) {
+    tests := []struct {
+        name    string
+        recover bool // whether guardians recover the key, rather than the citizen rotating it
+    }{
+        {"rotation", false},
+        {"recovery", true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestState(t, RegistryPolicy{})
+            voter, _ := s.citizen("Voter")
+            guardian, guardianID := s.citizen("Guardian")
+            if err := s.guard(voter, []string{guardianID}, 1); err != nil {
+                t.Fatal(err)
+            }
+            id := s.startElection(ElectionOptions{SecretBallot: true})
+            _, candidateID := s.candidate(id, "Candidate")
+            s.advance(10)
+
+            ballot := Ballot{CandidateID: candidateID}
+            commitment, salt, err := NewBallot(id, voter.Public, MethodPlurality, &ballot)
+            if err != nil {
+                t.Fatal(err)
+            }
+            s.mustApply(signedBy(t, voter)(NewVoteCommitTx(id, voter.Public, commitment, s.ctx.Timestamp)))
+
+            current := newTestKey(t)
+            if tt.recover {
+                err = s.recover(guardian, generateCitizenID("Voter", voter.Public), current.Public)
+            } else {
+                err = s.rotate(voter, current.Public)
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+            election := s.election(id)
+            if _, exists := election.Commitments[voter.Public]; exists {
+                t.Error("ballot is still held under the retired key")
+            }
+            if _, exists := election.Commitments[current.Public]; !exists {
+                t.Fatal("ballot was not moved to the new key")
+            }
+
+            s.mustApply(signedBy(t, s.admin)(NewVotingCloseTx(s.admin.Public, id, s.ctx.Timestamp)))
+            if err := s.apply(signedBy(t, voter)(NewVoteRevealTx(id, voter.Public, ballot, salt, s.ctx.Timestamp))); err == nil {
+                t.Error("retired key revealed the ballot")
+            }
+            s.mustApply(signedBy(t, current)(NewVoteRevealTx(id, current.Public, ballot, salt, s.ctx.Timestamp)))
+            if got := s.election(id).Votes[current.Public]; got != candidateID {
+                t.Errorf("revealed vote = %q, want %q", got, candidateID)
+            }
+        })
+    }
+}
@@ -0,0 +1,151 @@
+package blockchain
+
+import (
+    "errors"
+    "fmt"
+    "slices"
+    "strconv"
+    "strings"
+)
+
+// The choices of a yes/no referendum
+const (
+    ChoiceYes = "Yes"
+    ChoiceNo  = "No"
+)
+
+// ReferendumResult is the certified outcome of a referendum; its turnout
+// is the election's. A yes/no referendum passes if Yes carries; any other
+// passes if some choice carries, and Carried tells which.
+type ReferendumResult struct {
+    QuorumMet bool   `json:"quorumMet"`
+    Carried   string `json:"carried,omitempty"` // ID of the choice that carried, if any
+    Passed    bool   `json:"passed"`
+}
+
+// referendum reports whether the election asks a question instead of
+// electing candidates
+func (o *ElectionOptions) referendum() bool {
+    return o.Question != ""
+}
+
+// validateReferendum checks the question, choices and thresholds of a
+// referendum, and that no thresholds are set on a candidate election
+func (o *ElectionOptions) validateReferendum() error {
+    if !o.referendum() {
+        if len(o.Choices) > 0 || o.Quorum != 0 || o.Supermajority != "" {
+            return errors.New("choices, quorum and supermajority apply only to referendums")
+        }
+        return nil
+    }
+
+    if o.method() != MethodPlurality {
+        return errors.New("referendums are counted by plurality")
+    }
+    if o.NominationDays != 0 || o.NominationBlocks != 0 || o.Endorsements != 0 {
+        return errors.New("referendums have no nomination phase")
+    }
+    if len(o.Choices) == 1 {
+        return errors.New("a referendum needs at least two choices")
+    }
+    seen := make(map[string]bool, len(o.Choices))
+    for _, choice := range o.Choices {
+        if strings.TrimSpace(choice) == "" {
+            return errors.New("referendum choices cannot be blank")
+        }
+        if seen[choice] {
+            return fmt.Errorf("choice %q listed twice", choice)
+        }
+        seen[choice] = true
+    }
+
+    if o.Quorum < 0 || o.Quorum > 100 {
+        return errors.New("quorum must be between 0 and 100 percent")
+    }
+    if o.Supermajority != "" {
+        num, den, err := parseFraction(o.Supermajority)
+        if err != nil {
+            return err
+        }
+        if 2*num < den || num > den {
+            return errors.New("supermajority must be between 1/2 and 1")
+        }
+    }
+    return nil
+}
+
+// yesNo reports whether the referendum's choices are Yes and No, whether
+// given or by default
+func (o *ElectionOptions) yesNo() bool {
+    switch len(o.Choices) {
+    case 0:
+        return true
+    case 2:
+        return slices.Contains(o.Choices, ChoiceYes) && slices.Contains(o.Choices, ChoiceNo)
+    }
+    return false
+}
+
+// choiceCandidates returns the choices of a referendum as the candidates
+// its ballots pick from; without choices they are Yes and No
+func (e *Election) choiceCandidates() []Candidate {
+    choices := e.Choices
+    if len(choices) == 0 {
+        choices = []string{ChoiceYes, ChoiceNo}
+    }
+    candidates := make([]Candidate, len(choices))
+    for i, choice := range choices {
+        candidates[i] = Candidate{
+            ID:   generateCandidateID(choice, e.ID),
+            Name: choice,
+        }
+    }
+    return candidates
+}
+
+// referendumResult decides a referendum from its final counts. A choice
+// carries if turnout reached the quorum and it has more votes than any
+// other choice and, with a supermajority, at least that share of the
+// ballots. See ReferendumResult for when it passes.
+func (e *Election) referendumResult(voteCounts map[string]int) *ReferendumResult {
+    result := &ReferendumResult{}
+    leader, runnerUp := -1, 0
+    for i, candidate := range e.Candidates {
+        votes := voteCounts[candidate.ID]
+        switch {
+        case leader < 0 || votes > voteCounts[e.Candidates[leader].ID]:
+            if leader >= 0 {
+                runnerUp = voteCounts[e.Candidates[leader].ID]
+            }
+            leader = i
+        case votes > runnerUp:
+            runnerUp = votes
+        }
+    }
+    // A quorum is never met on an empty voter roll
+    result.QuorumMet = e.Quorum == 0 || (e.Eligible > 0 && e.Turnout*100 >= e.Quorum*e.Eligible)
+
+    top := voteCounts[e.Candidates[leader].ID]
+    carried := result.QuorumMet && top > runnerUp
+    if carried && e.Supermajority != "" {
+        // Validated when the referendum started
+        num, den, _ := parseFraction(e.Supermajority)
+        carried = top*den >= num*e.Turnout
+    }
+    if carried {
+        result.Carried = e.Candidates[leader].ID
+        result.Passed = !e.yesNo() || e.Candidates[leader].Name == ChoiceYes
+    }
+    return result
+}
+
+// parseFraction reads a fraction such as "2/3"
+func parseFraction(s string) (num, den int, err error) {
+    numerator, denominator, found := strings.Cut(s, "/")
+    num, numErr := strconv.Atoi(numerator)
+    den, denErr := strconv.Atoi(denominator)
+    if !found || numErr != nil || denErr != nil || num < 0 || den <= 0 {
+        return 0, 0, fmt.Errorf("%q is not a fraction such as 2/3", s)
+    }
+    return num, den, nil
+}
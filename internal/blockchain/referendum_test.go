@@ -0,0 +1,132 @@
+package blockchain
+
+import (
+    "fmt"
+    "testing"
+)
+
+func TestReferendumResult(t *testing.T) {
+    tests := []struct {
+        name      string
+        options   ElectionOptions
+        votes     []int // ballots for each choice; ten citizens are eligible
+        quorumMet bool
+        carried   int // index of the choice that carried, or -1
+        passed    bool
+    }{
+        {"yes passes", ElectionOptions{}, []int{3, 2}, true, 0, true},
+        {"no carries", ElectionOptions{}, []int{2, 3}, true, 1, false},
+        {"tie", ElectionOptions{}, []int{2, 2}, true, -1, false},
+        {"no ballots", ElectionOptions{}, []int{0, 0}, true, -1, false},
+        {"quorum missed", ElectionOptions{Quorum: 50}, []int{3, 1}, false, -1, false},
+        {"quorum met exactly", ElectionOptions{Quorum: 50}, []int{4, 1}, true, 0, true},
+        {"supermajority missed", ElectionOptions{Supermajority: "2/3"}, []int{5, 3}, true, -1, false},
+        {"supermajority met exactly", ElectionOptions{Supermajority: "2/3"}, []int{6, 3}, true, 0, true},
+        {"yes given", ElectionOptions{Choices: []string{ChoiceYes, ChoiceNo}}, []int{3, 2}, true, 0, true},
+        {"no given and carries", ElectionOptions{Choices: []string{ChoiceYes, ChoiceNo}}, []int{2, 3}, true, 1, false},
+        {"no listed first and carries", ElectionOptions{Choices: []string{ChoiceNo, ChoiceYes}}, []int{3, 2}, true, 0, false},
+        {"own choices", ElectionOptions{Choices: []string{"Red", "Green", "Blue"}}, []int{1, 3, 2}, true, 1, true},
+        {"own choices tied", ElectionOptions{Choices: []string{"Red", "Green", "Blue"}}, []int{1, 3, 3}, true, -1, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestState(t, RegistryPolicy{})
+            var voters []testKey
+            for i := 0; i < 10; i++ {
+                voter, _ := s.citizen(fmt.Sprint("Voter ", i))
+                voters = append(voters, voter)
+            }
+            options := tt.options
+            options.Question = "Adopt the constitution?"
+            id := s.startElection(options)
+            s.advance(10)
+
+            choices := s.election(id).Candidates
+            for choice, n := range tt.votes {
+                for i := 0; i < n; i++ {
+                    voter := voters[0]
+                    voters = voters[1:]
+                    s.mustApply(signedBy(t, voter)(NewVoteTx(id, voter.Public, choices[choice].ID, s.ctx.Timestamp)))
+                }
+            }
+            s.mustApply(signedBy(t, s.admin)(NewElectionEndTx(s.admin.Public, id, s.ctx.Timestamp)))
+
+            result := s.election(id).Result
+            if result == nil {
+                t.Fatal("referendum has no result")
+            }
+            want := ""
+            if tt.carried >= 0 {
+                want = choices[tt.carried].ID
+            }
+            if result.QuorumMet != tt.quorumMet || result.Carried != want || result.Passed != tt.passed {
+                t.Errorf("result = %+v, want quorum met %v, carried %q, passed %v", *result, tt.quorumMet, want, tt.passed)
+            }
+            if winner := s.election(id).Winner; (winner != nil) != (tt.carried >= 0) {
+                t.Errorf("winner = %v, want one only if a choice carried", winner)
+            }
+        })
+    }
+}
+
+func TestReferendumQuorumOnEmptyRoll(t *testing.T) {
+    tests := []struct {
+        name      string
+        quorum    int
+        quorumMet bool
+    }{
+        {"no quorum", 0, true},
+        {"quorum", 1, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestState(t, RegistryPolicy{})
+            s.citizen("Too young")
+
+            // Test citizens were born in 1990, so nobody is on the roll
+            id := s.startElection(ElectionOptions{Question: "Adopt the constitution?", Quorum: tt.quorum, MinimumAge: 100})
+            s.advance(10)
+            s.mustApply(signedBy(t, s.admin)(NewElectionEndTx(s.admin.Public, id, s.ctx.Timestamp)))
+
+            election := s.election(id)
+            if election.Eligible != 0 {
+                t.Fatalf("%d citizens eligible, want none", election.Eligible)
+            }
+            if result := election.Result; result.QuorumMet != tt.quorumMet || result.Passed {
+                t.Errorf("result = %+v, want quorum met %v and not passed", *result, tt.quorumMet)
+            }
+        })
+    }
+}
+
+func TestValidateReferendum(t *testing.T) {
+    question := "Adopt the constitution?"
+    tests := []struct {
+        name    string
+        options ElectionOptions
+        ok      bool
+    }{
+        {"yes or no", ElectionOptions{Question: question}, true},
+        {"own choices", ElectionOptions{Question: question, Choices: []string{"A", "B"}}, true},
+        {"thresholds", ElectionOptions{Question: question, Quorum: 40, Supermajority: "3/5"}, true},
+        {"one choice", ElectionOptions{Question: question, Choices: []string{"A"}}, false},
+        {"blank choice", ElectionOptions{Question: question, Choices: []string{"A", " "}}, false},
+        {"choice twice", ElectionOptions{Question: question, Choices: []string{"A", "A"}}, false},
+        {"quorum above 100", ElectionOptions{Question: question, Quorum: 101}, false},
+        {"negative quorum", ElectionOptions{Question: question, Quorum: -1}, false},
+        {"supermajority below half", ElectionOptions{Question: question, Supermajority: "1/3"}, false},
+        {"supermajority above one", ElectionOptions{Question: question, Supermajority: "4/3"}, false},
+        {"supermajority not a fraction", ElectionOptions{Question: question, Supermajority: "two thirds"}, false},
+        {"ranked", ElectionOptions{Question: question, Method: MethodInstantRunoff}, false},
+        {"nominations", ElectionOptions{Question: question, NominationDays: 1}, false},
+        {"choices without a question", ElectionOptions{Choices: []string{"A", "B"}}, false},
+        {"quorum without a question", ElectionOptions{Quorum: 10}, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if err := tt.options.validateReferendum(); (err == nil) != tt.ok {
+                t.Errorf("validateReferendum() = %v, want ok = %v", err, tt.ok)
+            }
+        })
+    }
+}
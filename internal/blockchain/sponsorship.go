@@ -0,0 +1,88 @@
+package blockchain
+
+import (
+    "errors"
+    "fmt"
+    "slices"
+)
+
+// secondsPerDay converts the day counts of a RegistryPolicy to block time
+const secondsPerDay = 24 * 60 * 60
+
+// Sponsorship is a citizen's vouch for an applicant
+type Sponsorship struct {
+    CitizenID string `json:"citizenId"` // the applicant
+    Date      int64  `json:"date"`
+}
+
+// VouchForCitizen records the vouch of the approved citizen holding
+// sponsorKey for a pending applicant. The applicant is approved once as
+// many citizens as the registry policy requires have vouched for them,
+// unless they are a suspected duplicate, which only the admins approve.
+func (cr *CitizenRegistry) VouchForCitizen(citizenID, sponsorKey string, ctx BlockContext) error {
+    cr.mu.Lock()
+    defer cr.mu.Unlock()
+
+    if cr.policy.SponsorThreshold == 0 {
+        return errors.New("citizens cannot be approved by sponsorship on this network")
+    }
+    sponsor, exists := cr.citizens[sponsorKey]
+    if !exists || sponsor.Status != Approved {
+        return errors.New("sponsor must be an approved citizen")
+    }
+    if ctx.Timestamp < sponsor.BarredUntil {
+        return errors.New("sponsor's vouching rights are suspended")
+    }
+    if sponsor.recentSponsorships(ctx.Timestamp-int64(cr.policy.SponsorPeriodDays)*secondsPerDay) >= cr.policy.SponsorLimit {
+        return fmt.Errorf("a citizen may vouch for at most %d applicants in %d days", cr.policy.SponsorLimit, cr.policy.SponsorPeriodDays)
+    }
+
+    applicant := cr.byID(citizenID)
+    if applicant == nil {
+        return errors.New("citizen not found")
+    }
+    if applicant.Status != Pending {
+        return errors.New("citizen already processed")
+    }
+    if slices.Contains(applicant.Sponsors, sponsor.ID) {
+        return errors.New("citizen has already vouched for this applicant")
+    }
+
+    applicant.Sponsors = append(applicant.Sponsors, sponsor.ID)
+    sponsor.Sponsored = append(sponsor.Sponsored, Sponsorship{CitizenID: citizenID, Date: ctx.Timestamp})
+    if len(applicant.Sponsors) >= cr.policy.SponsorThreshold && len(applicant.DuplicateOf) == 0 {
+        applicant.approve(sponsorKey, "sponsored", ctx)
+    }
+    return nil
+}
+
+// recentSponsorships returns how many applicants the citizen vouched for
+// since the given time
+func (c *Citizen) recentSponsorships(since int64) int {
+    count := 0
+    for _, sponsorship := range c.Sponsored {
+        if sponsorship.Date > since {
+            count++
+        }
+    }
+    return count
+}
+
+// sanctionSponsors suspends the vouching rights of every citizen who
+// vouched for a citizen revoked for fraud. A sanction never shortens one
+// already in force.
+func (cr *CitizenRegistry) sanctionSponsors(citizenID, adminKey string, ctx BlockContext) {
+    cr.mu.Lock()
+    defer cr.mu.Unlock()
+
+    revoked := cr.byID(citizenID)
+    until := ctx.Timestamp + int64(cr.policy.SponsorPenaltyDays)*secondsPerDay
+    for _, sponsorID := range revoked.Sponsors {
+        sponsor := cr.byID(sponsorID)
+        if sponsor == nil {
+            continue
+        }
+        sponsor.BarredUntil = max(sponsor.BarredUntil, until)
+        sponsor.record(ActionVouchingSuspended, adminKey, "vouched for "+citizenID+", revoked for fraud", ctx)
+    }
+}
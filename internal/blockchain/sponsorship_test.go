@@ -0,0 +1,188 @@
+package blockchain
+
+import "testing"
+
+// vouch has sponsor vouch for the applicant with the given citizen ID
+func (s *testState) vouch(sponsor testKey, citizenID string) error {
+    s.t.Helper()
+    return s.apply(signedBy(s.t, sponsor)(NewCitizenVouchTx(sponsor.Public, citizenID, s.ctx.Timestamp)))
+}
+
+func TestSponsorshipThreshold(t *testing.T) {
+    tests := []struct {
+        name      string
+        threshold int // policy SponsorThreshold
+        sponsors  int
+        want      CitizenStatus
+    }{
+        {"one of two sponsors", 2, 1, Pending},
+        {"two of two sponsors", 2, 2, Approved},
+        {"single sponsor", 1, 1, Approved},
+        {"two of three sponsors", 3, 2, Pending},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestState(t, RegistryPolicy{SponsorThreshold: tt.threshold})
+            var sponsors []testKey
+            for _, name := range []string{"First", "Second", "Third"}[:tt.sponsors] {
+                key, _ := s.citizen(name)
+                sponsors = append(sponsors, key)
+            }
+            _, id := s.register("Applicant")
+            for _, sponsor := range sponsors {
+                if err := s.vouch(sponsor, id); err != nil {
+                    t.Fatal(err)
+                }
+            }
+            if got := s.status(id); got != tt.want {
+                t.Errorf("status = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestVouchRules(t *testing.T) {
+    tests := []struct {
+        name      string
+        threshold int
+        // act has a sponsor vouch for an applicant after any setup
+        act func(s *testState, sponsor testKey, applicantID string) error
+        ok  bool
+    }{
+        {"approved sponsor", 2, func(s *testState, sponsor testKey, applicantID string) error {
+            return s.vouch(sponsor, applicantID)
+        }, true},
+        {"sponsorship disabled", 0, func(s *testState, sponsor testKey, applicantID string) error {
+            return s.vouch(sponsor, applicantID)
+        }, false},
+        {"pending sponsor", 2, func(s *testState, sponsor testKey, applicantID string) error {
+            pending, _ := s.register("Pending")
+            return s.vouch(pending, applicantID)
+        }, false},
+        {"suspended sponsor", 2, func(s *testState, sponsor testKey, applicantID string) error {
+            sponsorID := generateCitizenID("Sponsor", sponsor.Public)
+            s.mustApply(signedBy(s.t, s.admin)(NewCitizenStatusChangeTx(s.admin.Public, TxCitizenSuspension, sponsorID, "Under investigation", false, s.ctx.Timestamp)))
+            return s.vouch(sponsor, applicantID)
+        }, false},
+        {"twice for the same applicant", 2, func(s *testState, sponsor testKey, applicantID string) error {
+            s.vouch(sponsor, applicantID)
+            return s.vouch(sponsor, applicantID)
+        }, false},
+        {"approved applicant", 2, func(s *testState, sponsor testKey, applicantID string) error {
+            s.mustApply(signedBy(s.t, s.admin)(NewCitizenApprovalTx(s.admin.Public, applicantID, s.ctx.Timestamp)))
+            return s.vouch(sponsor, applicantID)
+        }, false},
+        {"unknown applicant", 2, func(s *testState, sponsor testKey, applicantID string) error {
+            return s.vouch(sponsor, "unknown")
+        }, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestState(t, RegistryPolicy{SponsorThreshold: tt.threshold})
+            sponsor, _ := s.citizen("Sponsor")
+            _, applicantID := s.register("Applicant")
+            if err := tt.act(s, sponsor, applicantID); (err == nil) != tt.ok {
+                t.Errorf("vouch error = %v, want ok = %v", err, tt.ok)
+            }
+        })
+    }
+}
+
+func TestSponsorshipLimit(t *testing.T) {
+    tests := []struct {
+        name  string
+        limit int // policy SponsorLimit
+        days  int // policy SponsorPeriodDays
+        wait  int64
+        ok    bool
+    }{
+        {"within the default period", 0, 0, 29 * secondsPerDay, false},
+        {"after the default period", 0, 0, 30 * secondsPerDay, true},
+        {"within a longer period", 0, 60, 59 * secondsPerDay, false},
+        {"after a shorter period", 0, 7, 7 * secondsPerDay, true},
+        {"under a higher limit", 4, 0, 0, true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestState(t, RegistryPolicy{SponsorThreshold: 2, SponsorLimit: tt.limit, SponsorPeriodDays: tt.days})
+            sponsor, _ := s.citizen("Sponsor")
+            for _, name := range []string{"First", "Second", "Third"} {
+                _, id := s.register(name)
+                if err := s.vouch(sponsor, id); err != nil {
+                    t.Fatal(err)
+                }
+            }
+
+            s.advance(tt.wait)
+            _, id := s.register("Fourth")
+            if err := s.vouch(sponsor, id); (err == nil) != tt.ok {
+                t.Errorf("fourth vouch error = %v, want ok = %v", err, tt.ok)
+            }
+        })
+    }
+}
+
+func TestSuspectedDuplicateNeedsAdmin(t *testing.T) {
+    s := newTestState(t, RegistryPolicy{SponsorThreshold: 1})
+    sponsor, _ := s.citizen("Sponsor")
+    s.citizen("Abebe Bikila")
+    _, id := s.register("Abebe  bikila")
+
+    citizen, _ := s.citizenRegistry.GetCitizenByID(id)
+    if len(citizen.DuplicateOf) == 0 {
+        t.Fatal("registration was not flagged as a duplicate")
+    }
+    if err := s.vouch(sponsor, id); err != nil {
+        t.Fatal(err)
+    }
+    if got := s.status(id); got != Pending {
+        t.Fatalf("status after vouching = %v, want pending", got)
+    }
+    s.mustApply(signedBy(t, s.admin)(NewCitizenApprovalTx(s.admin.Public, id, s.ctx.Timestamp)))
+    if got := s.status(id); got != Approved {
+        t.Errorf("status after admin approval = %v, want approved", got)
+    }
+}
+
+func TestFraudBarsSponsors(t *testing.T) {
+    tests := []struct {
+        name  string
+        fraud bool
+        wait  int64
+        ok    bool // whether the sponsors may vouch again
+    }{
+        {"revoked without fraud", false, 0, true},
+        {"revoked for fraud", true, 0, false},
+        {"within the penalty", true, 364 * secondsPerDay, false},
+        {"after the penalty", true, 365 * secondsPerDay, true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestState(t, RegistryPolicy{SponsorThreshold: 2, SponsorLimit: 10, SponsorPeriodDays: 1})
+            first, firstID := s.citizen("First")
+            second, _ := s.citizen("Second")
+            bystander, _ := s.citizen("Bystander")
+            _, id := s.register("Impostor")
+            for _, sponsor := range []testKey{first, second} {
+                if err := s.vouch(sponsor, id); err != nil {
+                    t.Fatal(err)
+                }
+            }
+            s.mustApply(signedBy(t, s.admin)(NewCitizenStatusChangeTx(s.admin.Public, TxCitizenRevocation, id, "Forged documents", tt.fraud, s.ctx.Timestamp)))
+
+            sponsor, _ := s.citizenRegistry.GetCitizenByID(firstID)
+            if barred := sponsor.BarredUntil > s.ctx.Timestamp; barred != tt.fraud {
+                t.Errorf("barred = %v, want %v", barred, tt.fraud)
+            }
+
+            s.advance(tt.wait)
+            _, applicantID := s.register("Applicant")
+            if err := s.vouch(bystander, applicantID); err != nil {
+                t.Errorf("citizen who did not vouch was barred: %v", err)
+            }
+            if err := s.vouch(first, applicantID); (err == nil) != tt.ok {
+                t.Errorf("vouch error = %v, want ok = %v", err, tt.ok)
+            }
+        })
+    }
+}
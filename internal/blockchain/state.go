@@ -0,0 +1,833 @@
+package blockchain
+
+import (
+    "encoding/json"
+    "fmt"
+)
+
+// Transaction types understood by the state machine
+const (
+    TxCitizenRegistration   = "CITIZEN_REGISTRATION"
+    TxCitizenApproval       = "CITIZEN_APPROVAL"
+    TxElectionStart         = "ELECTION_START"
+    TxCandidateRegistration = "CANDIDATE_REGISTRATION"
+    TxVoteCast              = "VOTE_CAST"
+    TxElectionEnd           = "ELECTION_END"
+    TxVoteCommit            = "VOTE_COMMIT"
+    TxVotingClose           = "VOTING_CLOSE"
+    TxVoteReveal            = "VOTE_REVEAL"
+    TxTrusteeDealing        = "TRUSTEE_DEALING"
+    TxEncryptedVote         = "ENCRYPTED_VOTE"
+    TxTallyDecryption       = "TALLY_DECRYPTION"
+    TxCandidateEndorsement  = "CANDIDATE_ENDORSEMENT"
+    TxCandidateWithdrawal   = "CANDIDATE_WITHDRAWAL"
+    TxDelegation            = "DELEGATION"
+    TxDelegationRevocation  = "DELEGATION_REVOCATION"
+    TxCitizenRejection      = "CITIZEN_REJECTION"
+    TxCitizenSuspension     = "CITIZEN_SUSPENSION"
+    TxCitizenReinstatement  = "CITIZEN_REINSTATEMENT"
+    TxCitizenRevocation     = "CITIZEN_REVOCATION"
+    TxCitizenAppeal         = "CITIZEN_APPEAL"
+    TxAppealDecision        = "APPEAL_DECISION"
+    TxAdminProposal         = "ADMIN_PROPOSAL"
+    TxAdminApproval         = "ADMIN_APPROVAL"
+    TxCitizenVouch          = "CITIZEN_VOUCH"
+    TxGuardianDesignation   = "GUARDIAN_DESIGNATION"
+    TxKeyRotation           = "KEY_ROTATION"
+    TxKeyRecovery           = "KEY_RECOVERY"
+)
+
+// CitizenRegistrationData is the payload of a CITIZEN_REGISTRATION transaction
+type CitizenRegistrationData struct {
+    CitizenID    string `json:"citizenID"`
+    Name         string `json:"name"`
+    DateOfBirth  string `json:"dateOfBirth"`
+    PublicKey    string `json:"publicKey"`
+    DocumentHash string `json:"documentHash,omitempty"` // see DocumentHash
+}
+
+// CitizenApprovalData is the payload of a CITIZEN_APPROVAL transaction; the
+// approving admin is tx.From
+type CitizenApprovalData struct {
+    CitizenID string `json:"citizenID"`
+}
+
+// CitizenStatusChangeData is the payload of the CITIZEN_REJECTION,
+// CITIZEN_SUSPENSION, CITIZEN_REINSTATEMENT and CITIZEN_REVOCATION
+// transactions; the deciding admin is tx.From. Fraud marks a revocation
+// for fraud, which sanctions the citizen's sponsors.
+type CitizenStatusChangeData struct {
+    CitizenID string `json:"citizenID"`
+    Reason    string `json:"reason"`
+    Fraud     bool   `json:"fraud,omitempty"`
+}
+
+// CitizenAppealData is the payload of a CITIZEN_APPEAL transaction, with
+// which tx.From appeals their rejection, suspension or revocation
+type CitizenAppealData struct {
+    Statement string `json:"statement"`
+}
+
+// AppealDecisionData is the payload of an APPEAL_DECISION transaction,
+// with which the admin tx.From grants or denies a citizen's appeal
+type AppealDecisionData struct {
+    CitizenID string `json:"citizenID"`
+    Grant     bool   `json:"grant"`
+}
+
+// AdminProposalData is the payload of an ADMIN_PROPOSAL transaction, with
+// which the admin tx.From proposes to add or remove an admin. The proposal
+// ID is the ID of the transaction itself.
+type AdminProposalData struct {
+    Action    string `json:"action"`
+    PublicKey string `json:"publicKey"`
+}
+
+// CitizenVouchData is the payload of a CITIZEN_VOUCH transaction, with
+// which the approved citizen tx.From vouches for a pending applicant
+type CitizenVouchData struct {
+    CitizenID string `json:"citizenID"`
+}
+
+// GuardianDesignationData is the payload of a GUARDIAN_DESIGNATION
+// transaction, with which the citizen tx.From names the guardians who may
+// recover their identity
+type GuardianDesignationData struct {
+    Guardians []string `json:"guardians"` // citizen IDs
+    Threshold int      `json:"threshold"`
+}
+
+// KeyRotationData is the payload of a KEY_ROTATION transaction, which the
+// citizen signs with the key they are retiring, tx.From
+type KeyRotationData struct {
+    NewKey string `json:"newKey"`
+}
+
+// KeyRecoveryData is the payload of a KEY_RECOVERY transaction, with which
+// the guardian tx.From supports moving a citizen to a new key
+type KeyRecoveryData struct {
+    CitizenID string `json:"citizenID"`
+    NewKey    string `json:"newKey"`
+}
+
+// AdminApprovalData is the payload of an ADMIN_APPROVAL transaction, with
+// which the admin tx.From approves a proposal
+type AdminApprovalData struct {
+    ProposalID string `json:"proposalID"`
+}
+
+// ElectionStartData is the payload of an ELECTION_START transaction, which
+// must be sent by an admin. The election ID is the ID of the transaction itself.
+type ElectionStartData struct {
+    Name         string `json:"name"`
+    DurationDays int    `json:"durationDays"`
+    ElectionOptions
+}
+
+// CandidateRegistrationData is the payload of a CANDIDATE_REGISTRATION
+// transaction, sent by the candidate
+type CandidateRegistrationData struct {
+    ElectionID  string `json:"electionID"`
+    CandidateID string `json:"candidateID"`
+    Name        string `json:"name"`
+    PublicKey   string `json:"publicKey"`
+    Platform    string `json:"platform"`
+}
+
+// CandidateEndorsementData is the payload of a CANDIDATE_ENDORSEMENT
+// transaction; the endorsing citizen is tx.From
+type CandidateEndorsementData struct {
+    ElectionID  string `json:"electionID"`
+    CandidateID string `json:"candidateID"`
+}
+
+// CandidateWithdrawalData is the payload of a CANDIDATE_WITHDRAWAL
+// transaction, sent by the candidate
+type CandidateWithdrawalData struct {
+    ElectionID string `json:"electionID"`
+}
+
+// DelegationData is the payload of a DELEGATION transaction, with which
+// tx.From lends their vote to the citizen Delegate in one election, the
+// elections of one category, or every election
+type DelegationData struct {
+    Delegate   string `json:"delegate"`
+    ElectionID string `json:"electionID,omitempty"`
+    Category   string `json:"category,omitempty"`
+}
+
+// DelegationRevocationData is the payload of a DELEGATION_REVOCATION
+// transaction, which withdraws tx.From's delegation in a scope
+type DelegationRevocationData struct {
+    ElectionID string `json:"electionID,omitempty"`
+    Category   string `json:"category,omitempty"`
+}
+
+// VoteCastData is the payload of a VOTE_CAST transaction; the voter is
+// tx.From
+type VoteCastData struct {
+    ElectionID string `json:"electionID"`
+    Ballot
+}
+
+// VoteCommitData is the payload of a VOTE_COMMIT transaction, which casts
+// tx.From's sealed ballot in a secret ballot election
+type VoteCommitData struct {
+    ElectionID string `json:"electionID"`
+    Commitment string `json:"commitment"`
+}
+
+// VotingCloseData is the payload of a VOTING_CLOSE transaction, with which
+// an admin opens the reveal phase of a secret ballot election
+type VotingCloseData struct {
+    ElectionID string `json:"electionID"`
+}
+
+// VoteRevealData is the payload of a VOTE_REVEAL transaction, which opens
+// tx.From's sealed ballot
+type VoteRevealData struct {
+    ElectionID string `json:"electionID"`
+    Ballot
+    Salt string `json:"salt"`
+}
+
+// TrusteeDealingData is the payload of a TRUSTEE_DEALING transaction, sent
+// by a trustee of an encrypted election
+type TrusteeDealingData struct {
+    ElectionID string         `json:"electionID"`
+    Dealing    TrusteeDealing `json:"dealing"`
+}
+
+// EncryptedVoteData is the payload of an ENCRYPTED_VOTE transaction; the
+// voter is tx.From
+type EncryptedVoteData struct {
+    ElectionID string          `json:"electionID"`
+    Ballot     EncryptedBallot `json:"ballot"`
+}
+
+// TallyDecryptionData is the payload of a TALLY_DECRYPTION transaction,
+// sent by a trustee once an encrypted election has ended
+type TallyDecryptionData struct {
+    ElectionID string          `json:"electionID"`
+    Share      DecryptionShare `json:"share"`
+}
+
+// ElectionEndData is the payload of an ELECTION_END transaction, which must
+// be sent by an admin
+type ElectionEndData struct {
+    ElectionID string `json:"electionID"`
+}
+
+// BlockContext carries the block-level values a transaction may depend on.
+// Transactions must never read the wall clock, so that replaying the chain
+// produces the same state on every node.
+type BlockContext struct {
+    Height    int64
+    Timestamp int64
+}
+
+// State is the citizen registry and election system derived from the chain.
+// It only changes by applying committed blocks in order.
+type State struct {
+    citizenRegistry *CitizenRegistry
+    electionSystem  *ElectionSystem
+    applied         map[string]bool // IDs of the transactions applied so far
+}
+
+// NewState creates the state that precedes the first block after genesis
+func NewState(genesis *Genesis) *State {
+    registry := NewCitizenRegistry(genesis.Admins, genesis.RegistryPolicy)
+    return &State{
+        citizenRegistry: registry,
+        electionSystem:  NewElectionSystem(registry),
+        applied:         make(map[string]bool),
+    }
+}
+
+// Clone returns an independent deep copy of the state
+func (s *State) Clone() *State {
+    registry := s.citizenRegistry.clone()
+    applied := make(map[string]bool, len(s.applied))
+    for id := range s.applied {
+        applied[id] = true
+    }
+    return &State{
+        citizenRegistry: registry,
+        electionSystem:  s.electionSystem.clone(registry),
+        applied:         applied,
+    }
+}
+
+// ApplyBlock applies every transaction of block in order. On error the state
+// may be partially modified, so callers apply blocks to a clone.
+func (s *State) ApplyBlock(block *Block) error {
+    ctx := BlockContext{Height: block.Index, Timestamp: block.Timestamp}
+    s.beginBlock(ctx)
+    for i := range block.Transactions {
+        tx := &block.Transactions[i]
+        if err := s.ApplyTransaction(tx, ctx); err != nil {
+            return fmt.Errorf("transaction %s: %v", tx.ID, err)
+        }
+    }
+    return nil
+}
+
+// beginBlock makes the transitions that are due at the block described by
+// ctx, before any of its transactions apply
+func (s *State) beginBlock(ctx BlockContext) {
+    s.electionSystem.advance(ctx)
+}
+
+// ApplyTransaction executes a single transaction against the state. A
+// transaction either applies completely or returns an error without
+// changing anything. Every transaction must be signed by its sender, who is
+// the actor the state machine authorizes, and applies at most once: a
+// signed transaction replayed later is rejected.
+func (s *State) ApplyTransaction(tx *Transaction, ctx BlockContext) error {
+    if err := tx.verify(); err != nil {
+        return err
+    }
+    if s.applied[tx.ID] {
+        return fmt.Errorf("transaction %s has already been applied", tx.ID)
+    }
+    if err := s.apply(tx, ctx); err != nil {
+        return err
+    }
+    s.applied[tx.ID] = true
+    return nil
+}
+
+// apply executes a verified transaction
+func (s *State) apply(tx *Transaction, ctx BlockContext) error {
+    txType, _ := tx.Data["type"].(string)
+    switch txType {
+    case TxCitizenRegistration:
+        var data CitizenRegistrationData
+        if err := decodeTxData(tx, &data); err != nil {
+            return err
+        }
+        if data.PublicKey != tx.From {
+            return fmt.Errorf("citizens must register their own public key")
+        }
+        if data.CitizenID != generateCitizenID(data.Name, data.PublicKey) {
+            return fmt.Errorf("citizen ID does not match name and public key")
+        }
+        _, err := s.citizenRegistry.RegisterCitizen(data.Name, data.DateOfBirth, data.DocumentHash, data.PublicKey, ctx)
+        return err
+
+    case TxCitizenApproval:
+        var data CitizenApprovalData
+        if err := decodeTxData(tx, &data); err != nil {
+            return err
+        }
+        return s.citizenRegistry.ApproveCitizen(data.CitizenID, tx.From, ctx)
+
+    case TxCitizenRejection, TxCitizenSuspension, TxCitizenReinstatement, TxCitizenRevocation:
+        var data CitizenStatusChangeData
+        if err := decodeTxData(tx, &data); err != nil {
+            return err
+        }
+        return s.changeCitizenStatus(txType, data, tx.From, ctx)
+
+    case TxCitizenAppeal:
+        var data CitizenAppealData
+        if err := decodeTxData(tx, &data); err != nil {
+            return err
+        }
+        return s.citizenRegistry.FileAppeal(tx.From, data.Statement, ctx)
+
+    case TxAppealDecision:
+        var data AppealDecisionData
+        if err := decodeTxData(tx, &data); err != nil {
+            return err
+        }
+        return s.citizenRegistry.DecideAppeal(data.CitizenID, tx.From, data.Grant, ctx)
+
+    case TxAdminProposal:
+        var data AdminProposalData
+        if err := decodeTxData(tx, &data); err != nil {
+            return err
+        }
+        return s.citizenRegistry.ProposeAdminChange(tx.ID, tx.From, data.Action, data.PublicKey, ctx)
+
+    case TxAdminApproval:
+        var data AdminApprovalData
+        if err := decodeTxData(tx, &data); err != nil {
+            return err
+        }
+        return s.citizenRegistry.ApproveAdminChange(data.ProposalID, tx.From, ctx)
+
+    case TxCitizenVouch:
+        var data CitizenVouchData
+        if err := decodeTxData(tx, &data); err != nil {
+            return err
+        }
+        return s.citizenRegistry.VouchForCitizen(data.CitizenID, tx.From, ctx)
+
+    case TxGuardianDesignation:
+        var data GuardianDesignationData
+        if err := decodeTxData(tx, &data); err != nil {
+            return err
+        }
+        return s.citizenRegistry.SetGuardians(tx.From, data.Guardians, data.Threshold, ctx)
+
+    case TxKeyRotation:
+        var data KeyRotationData
+        if err := decodeTxData(tx, &data); err != nil {
+            return err
+        }
+        if err := s.citizenRegistry.RotateKey(tx.From, data.NewKey, ctx); err != nil {
+            return err
+        }
+        s.electionSystem.rekey(tx.From, data.NewKey)
+        return nil
+
+    case TxKeyRecovery:
+        var data KeyRecoveryData
+        if err := decodeTxData(tx, &data); err != nil {
+            return err
+        }
+        return s.recoverKey(data, tx.From, ctx)
+
+    case TxElectionStart:
+        var data ElectionStartData
+        if err := decodeTxData(tx, &data); err != nil {
+            return err
+        }
+        if !s.citizenRegistry.IsAdmin(tx.From) {
+            return fmt.Errorf("only admins may start elections")
+        }
+        return s.electionSystem.StartElection(tx.ID, data.Name, ctx, data.DurationDays, data.ElectionOptions)
+
+    case TxCandidateRegistration:
+        var data CandidateRegistrationData
+        if err := decodeTxData(tx, &data); err != nil {
+            return err
+        }
+        if data.PublicKey != tx.From {
+            return fmt.Errorf("candidates must register their own public key")
+        }
+        if data.CandidateID != generateCandidateID(data.Name, data.PublicKey) {
+            return fmt.Errorf("candidate ID does not match name and public key")
+        }
+        return s.electionSystem.RegisterCandidate(data.ElectionID, data.Name, data.PublicKey, data.Platform)
+
+    case TxCandidateEndorsement:
+        var data CandidateEndorsementData
+        if err := decodeTxData(tx, &data); err != nil {
+            return err
+        }
+        return s.electionSystem.EndorseCandidate(data.ElectionID, data.CandidateID, tx.From)
+
+    case TxCandidateWithdrawal:
+        var data CandidateWithdrawalData
+        if err := decodeTxData(tx, &data); err != nil {
+            return err
+        }
+        return s.electionSystem.WithdrawCandidate(data.ElectionID, tx.From)
+
+    case TxDelegation:
+        var data DelegationData
+        if err := decodeTxData(tx, &data); err != nil {
+            return err
+        }
+        return s.electionSystem.Delegate(tx.From, Delegation{
+            Delegate:   data.Delegate,
+            ElectionID: data.ElectionID,
+            Category:   data.Category,
+        }, ctx)
+
+    case TxDelegationRevocation:
+        var data DelegationRevocationData
+        if err := decodeTxData(tx, &data); err != nil {
+            return err
+        }
+        return s.electionSystem.RevokeDelegation(tx.From, Delegation{
+            ElectionID: data.ElectionID,
+            Category:   data.Category,
+        })
+
+    case TxVoteCast:
+        var data VoteCastData
+        if err := decodeTxData(tx, &data); err != nil {
+            return err
+        }
+        return s.electionSystem.CastVote(data.ElectionID, tx.From, &data.Ballot)
+
+    case TxVoteCommit:
+        var data VoteCommitData
+        if err := decodeTxData(tx, &data); err != nil {
+            return err
+        }
+        return s.electionSystem.CommitVote(data.ElectionID, tx.From, data.Commitment)
+
+    case TxVotingClose:
+        var data VotingCloseData
+        if err := decodeTxData(tx, &data); err != nil {
+            return err
+        }
+        if !s.citizenRegistry.IsAdmin(tx.From) {
+            return fmt.Errorf("only admins may close voting")
+        }
+        return s.electionSystem.CloseVoting(data.ElectionID)
+
+    case TxVoteReveal:
+        var data VoteRevealData
+        if err := decodeTxData(tx, &data); err != nil {
+            return err
+        }
+        return s.electionSystem.RevealVote(data.ElectionID, tx.From, &data.Ballot, data.Salt)
+
+    case TxTrusteeDealing:
+        var data TrusteeDealingData
+        if err := decodeTxData(tx, &data); err != nil {
+            return err
+        }
+        return s.electionSystem.SubmitDealing(data.ElectionID, tx.From, &data.Dealing)
+
+    case TxEncryptedVote:
+        var data EncryptedVoteData
+        if err := decodeTxData(tx, &data); err != nil {
+            return err
+        }
+        return s.electionSystem.CastEncryptedVote(data.ElectionID, tx.From, tx.ID, &data.Ballot)
+
+    case TxTallyDecryption:
+        var data TallyDecryptionData
+        if err := decodeTxData(tx, &data); err != nil {
+            return err
+        }
+        return s.electionSystem.SubmitDecryption(data.ElectionID, tx.From, &data.Share)
+
+    case TxElectionEnd:
+        var data ElectionEndData
+        if err := decodeTxData(tx, &data); err != nil {
+            return err
+        }
+        if !s.citizenRegistry.IsAdmin(tx.From) {
+            return fmt.Errorf("only admins may end elections")
+        }
+        return s.electionSystem.EndElection(data.ElectionID)
+
+    case "":
+        // Plain value transfers carry no state changes yet
+        return nil
+    }
+    return fmt.Errorf("unknown transaction type %q", txType)
+}
+
+// changeCitizenStatus applies an admin's decision on a citizen's status. A
+// suspended or revoked citizen also stops standing in every election they
+// can still withdraw from.
+func (s *State) changeCitizenStatus(txType string, data CitizenStatusChangeData, adminKey string, ctx BlockContext) error {
+    if data.Fraud && txType != TxCitizenRevocation {
+        return fmt.Errorf("only a revocation can be for fraud")
+    }
+
+    var err error
+    switch txType {
+    case TxCitizenRejection:
+        err = s.citizenRegistry.RejectCitizen(data.CitizenID, adminKey, data.Reason, ctx)
+    case TxCitizenSuspension:
+        err = s.citizenRegistry.SuspendCitizen(data.CitizenID, adminKey, data.Reason, ctx)
+    case TxCitizenReinstatement:
+        err = s.citizenRegistry.ReinstateCitizen(data.CitizenID, adminKey, data.Reason, ctx)
+    case TxCitizenRevocation:
+        err = s.citizenRegistry.RevokeCitizen(data.CitizenID, adminKey, data.Reason, data.Fraud, ctx)
+    }
+    if err != nil {
+        return err
+    }
+
+    if txType == TxCitizenSuspension || txType == TxCitizenRevocation {
+        citizen, _ := s.citizenRegistry.GetCitizenByID(data.CitizenID)
+        s.electionSystem.disqualify(citizen.PublicKey)
+    }
+    return nil
+}
+
+// recoverKey applies a guardian's support for a citizen's recovery, and
+// carries the citizen's election entries over to the new key if it
+// completes the recovery
+func (s *State) recoverKey(data KeyRecoveryData, guardianKey string, ctx BlockContext) error {
+    citizen, exists := s.citizenRegistry.GetCitizenByID(data.CitizenID)
+    if !exists {
+        return fmt.Errorf("citizen not found")
+    }
+    oldKey := citizen.PublicKey
+    if err := s.citizenRegistry.SupportRecovery(data.CitizenID, guardianKey, data.NewKey, ctx); err != nil {
+        return err
+    }
+    if citizen.PublicKey != oldKey {
+        s.electionSystem.rekey(oldKey, citizen.PublicKey)
+    }
+    return nil
+}
+
+// NewCitizenRegistrationTx builds the unsigned transaction with which the
+// owner of publicKey applies for citizenship. Only the hash of the
+// identity document number, which may be empty, goes on the chain.
+func NewCitizenRegistrationTx(name, dateOfBirth, documentNumber, publicKey string, timestamp int64) (*Transaction, error) {
+    return newDataTransaction(publicKey, "CITIZEN_REGISTRY", TxCitizenRegistration, timestamp, CitizenRegistrationData{
+        CitizenID:    generateCitizenID(name, publicKey),
+        Name:         name,
+        DateOfBirth:  dateOfBirth,
+        PublicKey:    publicKey,
+        DocumentHash: DocumentHash(documentNumber),
+    })
+}
+
+// NewCitizenApprovalTx builds the unsigned transaction with which an admin
+// approves a pending citizen
+func NewCitizenApprovalTx(approverKey, citizenID string, timestamp int64) (*Transaction, error) {
+    return newDataTransaction(approverKey, citizenID, TxCitizenApproval, timestamp, CitizenApprovalData{
+        CitizenID: citizenID,
+    })
+}
+
+// NewCitizenStatusChangeTx builds the unsigned transaction with which an
+// admin rejects, suspends, reinstates or revokes a citizen; txType is one
+// of TxCitizenRejection, TxCitizenSuspension, TxCitizenReinstatement and
+// TxCitizenRevocation. fraud may only be set on a revocation.
+func NewCitizenStatusChangeTx(adminKey, txType, citizenID, reason string, fraud bool, timestamp int64) (*Transaction, error) {
+    return newDataTransaction(adminKey, citizenID, txType, timestamp, CitizenStatusChangeData{
+        CitizenID: citizenID,
+        Reason:    reason,
+        Fraud:     fraud,
+    })
+}
+
+// NewCitizenVouchTx builds the unsigned transaction with which an approved
+// citizen vouches for a pending applicant
+func NewCitizenVouchTx(sponsorKey, citizenID string, timestamp int64) (*Transaction, error) {
+    return newDataTransaction(sponsorKey, citizenID, TxCitizenVouch, timestamp, CitizenVouchData{
+        CitizenID: citizenID,
+    })
+}
+
+// NewGuardianDesignationTx builds the unsigned transaction with which a
+// citizen names their guardians
+func NewGuardianDesignationTx(publicKey string, guardians []string, threshold int, timestamp int64) (*Transaction, error) {
+    return newDataTransaction(publicKey, "CITIZEN_REGISTRY", TxGuardianDesignation, timestamp, GuardianDesignationData{
+        Guardians: guardians,
+        Threshold: threshold,
+    })
+}
+
+// NewKeyRotationTx builds the unsigned transaction with which the citizen
+// holding oldKey moves to newKey; it must be signed with oldKey
+func NewKeyRotationTx(oldKey, newKey string, timestamp int64) (*Transaction, error) {
+    return newDataTransaction(oldKey, "CITIZEN_REGISTRY", TxKeyRotation, timestamp, KeyRotationData{
+        NewKey: newKey,
+    })
+}
+
+// NewKeyRecoveryTx builds the unsigned transaction with which a guardian
+// supports moving a citizen to newKey
+func NewKeyRecoveryTx(guardianKey, citizenID, newKey string, timestamp int64) (*Transaction, error) {
+    return newDataTransaction(guardianKey, citizenID, TxKeyRecovery, timestamp, KeyRecoveryData{
+        CitizenID: citizenID,
+        NewKey:    newKey,
+    })
+}
+
+// NewCitizenAppealTx builds the unsigned transaction with which the owner
+// of publicKey appeals the decision on their citizenship
+func NewCitizenAppealTx(publicKey, statement string, timestamp int64) (*Transaction, error) {
+    return newDataTransaction(publicKey, "CITIZEN_REGISTRY", TxCitizenAppeal, timestamp, CitizenAppealData{
+        Statement: statement,
+    })
+}
+
+// NewAppealDecisionTx builds the unsigned transaction with which an admin
+// grants or denies a citizen's appeal
+func NewAppealDecisionTx(adminKey, citizenID string, grant bool, timestamp int64) (*Transaction, error) {
+    return newDataTransaction(adminKey, citizenID, TxAppealDecision, timestamp, AppealDecisionData{
+        CitizenID: citizenID,
+        Grant:     grant,
+    })
+}
+
+// NewAdminProposalTx builds the unsigned transaction with which an admin
+// proposes to add or remove the admin publicKey; action is AdminAdd or
+// AdminRemove. Its ID becomes the proposal ID.
+func NewAdminProposalTx(adminKey, action, publicKey string, timestamp int64) (*Transaction, error) {
+    return newDataTransaction(adminKey, "CITIZEN_REGISTRY", TxAdminProposal, timestamp, AdminProposalData{
+        Action:    action,
+        PublicKey: publicKey,
+    })
+}
+
+// NewAdminApprovalTx builds the unsigned transaction with which an admin
+// approves a proposed admin change
+func NewAdminApprovalTx(adminKey, proposalID string, timestamp int64) (*Transaction, error) {
+    return newDataTransaction(adminKey, "CITIZEN_REGISTRY", TxAdminApproval, timestamp, AdminApprovalData{
+        ProposalID: proposalID,
+    })
+}
+
+// NewElectionStartTx builds the unsigned transaction with which an admin
+// starts an election. Its ID becomes the election ID.
+func NewElectionStartTx(adminKey, name string, durationDays int, options ElectionOptions, timestamp int64) (*Transaction, error) {
+    return newDataTransaction(adminKey, "ELECTION", TxElectionStart, timestamp, ElectionStartData{
+        Name:            name,
+        DurationDays:    durationDays,
+        ElectionOptions: options,
+    })
+}
+
+// NewCandidateRegistrationTx builds the unsigned transaction with which the
+// owner of publicKey stands as a candidate in an election
+func NewCandidateRegistrationTx(electionID, name, publicKey, platform string, timestamp int64) (*Transaction, error) {
+    return newDataTransaction(publicKey, "ELECTION", TxCandidateRegistration, timestamp, CandidateRegistrationData{
+        ElectionID:  electionID,
+        CandidateID: generateCandidateID(name, publicKey),
+        Name:        name,
+        PublicKey:   publicKey,
+        Platform:    platform,
+    })
+}
+
+// NewCandidateEndorsementTx builds the unsigned transaction with which an
+// approved citizen endorses a nominee
+func NewCandidateEndorsementTx(electionID, candidateID, endorserKey string, timestamp int64) (*Transaction, error) {
+    return newDataTransaction(endorserKey, "ELECTION", TxCandidateEndorsement, timestamp, CandidateEndorsementData{
+        ElectionID:  electionID,
+        CandidateID: candidateID,
+    })
+}
+
+// NewCandidateWithdrawalTx builds the unsigned transaction with which a
+// candidate withdraws from an election
+func NewCandidateWithdrawalTx(electionID, candidateKey string, timestamp int64) (*Transaction, error) {
+    return newDataTransaction(candidateKey, "ELECTION", TxCandidateWithdrawal, timestamp, CandidateWithdrawalData{
+        ElectionID: electionID,
+    })
+}
+
+// NewDelegationTx builds the unsigned transaction with which delegatorKey
+// lends their vote to the citizen delegateID. At most one of electionID and
+// category scopes it; with neither it applies to every election.
+func NewDelegationTx(delegatorKey, delegateID, electionID, category string, timestamp int64) (*Transaction, error) {
+    return newDataTransaction(delegatorKey, "ELECTION", TxDelegation, timestamp, DelegationData{
+        Delegate:   delegateID,
+        ElectionID: electionID,
+        Category:   category,
+    })
+}
+
+// NewDelegationRevocationTx builds the unsigned transaction withdrawing
+// delegatorKey's delegation in the scope of electionID or category
+func NewDelegationRevocationTx(delegatorKey, electionID, category string, timestamp int64) (*Transaction, error) {
+    return newDataTransaction(delegatorKey, "ELECTION", TxDelegationRevocation, timestamp, DelegationRevocationData{
+        ElectionID: electionID,
+        Category:   category,
+    })
+}
+
+// NewVoteTx builds the unsigned transaction casting voterKey's vote for a
+// candidate in a plurality election
+func NewVoteTx(electionID, voterKey, candidateID string, timestamp int64) (*Transaction, error) {
+    return NewBallotTx(electionID, voterKey, Ballot{CandidateID: candidateID}, timestamp)
+}
+
+// NewBallotTx builds the unsigned transaction casting voterKey's ballot,
+// which must be of the kind the election's voting method takes
+func NewBallotTx(electionID, voterKey string, ballot Ballot, timestamp int64) (*Transaction, error) {
+    return newDataTransaction(voterKey, "ELECTION", TxVoteCast, timestamp, VoteCastData{
+        ElectionID: electionID,
+        Ballot:     ballot,
+    })
+}
+
+// NewVoteCommitTx builds the unsigned transaction casting voterKey's sealed
+// ballot; see NewBallot
+func NewVoteCommitTx(electionID, voterKey, commitment string, timestamp int64) (*Transaction, error) {
+    return newDataTransaction(voterKey, "ELECTION", TxVoteCommit, timestamp, VoteCommitData{
+        ElectionID: electionID,
+        Commitment: commitment,
+    })
+}
+
+// NewVotingCloseTx builds the unsigned transaction with which an admin opens
+// the reveal phase of a secret ballot election
+func NewVotingCloseTx(adminKey, electionID string, timestamp int64) (*Transaction, error) {
+    return newDataTransaction(adminKey, "ELECTION", TxVotingClose, timestamp, VotingCloseData{
+        ElectionID: electionID,
+    })
+}
+
+// NewVoteRevealTx builds the unsigned transaction opening voterKey's sealed
+// ballot
+func NewVoteRevealTx(electionID, voterKey string, ballot Ballot, salt string, timestamp int64) (*Transaction, error) {
+    return newDataTransaction(voterKey, "ELECTION", TxVoteReveal, timestamp, VoteRevealData{
+        ElectionID: electionID,
+        Ballot:     ballot,
+        Salt:       salt,
+    })
+}
+
+// NewTrusteeDealingTx builds the unsigned transaction publishing a
+// trustee's dealing; see DealTrusteeShares
+func NewTrusteeDealingTx(electionID, trusteeKey string, dealing TrusteeDealing, timestamp int64) (*Transaction, error) {
+    return newDataTransaction(trusteeKey, "ELECTION", TxTrusteeDealing, timestamp, TrusteeDealingData{
+        ElectionID: electionID,
+        Dealing:    dealing,
+    })
+}
+
+// NewEncryptedVoteTx builds the unsigned transaction casting voterKey's
+// encrypted ballot; see EncryptBallot
+func NewEncryptedVoteTx(electionID, voterKey string, ballot EncryptedBallot, timestamp int64) (*Transaction, error) {
+    return newDataTransaction(voterKey, "ELECTION", TxEncryptedVote, timestamp, EncryptedVoteData{
+        ElectionID: electionID,
+        Ballot:     ballot,
+    })
+}
+
+// NewTallyDecryptionTx builds the unsigned transaction publishing a
+// trustee's partial decryption of the tally; see DecryptTallyShare
+func NewTallyDecryptionTx(electionID, trusteeKey string, share DecryptionShare, timestamp int64) (*Transaction, error) {
+    return newDataTransaction(trusteeKey, "ELECTION", TxTallyDecryption, timestamp, TallyDecryptionData{
+        ElectionID: electionID,
+        Share:      share,
+    })
+}
+
+// NewElectionEndTx builds the unsigned transaction with which an admin ends
+// an election; the winner is determined when it is committed
+func NewElectionEndTx(adminKey, electionID string, timestamp int64) (*Transaction, error) {
+    return newDataTransaction(adminKey, "ELECTION", TxElectionEnd, timestamp, ElectionEndData{
+        ElectionID: electionID,
+    })
+}
+
+// newDataTransaction builds a transaction whose Data is the JSON form of
+// payload plus its type
+func newDataTransaction(from, to, txType string, timestamp int64, payload interface{}) (*Transaction, error) {
+    raw, err := json.Marshal(payload)
+    if err != nil {
+        return nil, err
+    }
+
+    tx := NewTransactionAt(from, to, 0, timestamp)
+    if err := json.Unmarshal(raw, &tx.Data); err != nil {
+        return nil, err
+    }
+    tx.Data["type"] = txType
+    tx.ID = calculateTransactionHash(tx)
+    return tx, nil
+}
+
+// decodeTxData decodes tx.Data into a typed payload. Data read back from
+// storage or the network is generic JSON, so it is round-tripped.
+func decodeTxData(tx *Transaction, out interface{}) error {
+    raw, err := json.Marshal(tx.Data)
+    if err != nil {
+        return err
+    }
+    if err := json.Unmarshal(raw, out); err != nil {
+        return fmt.Errorf("invalid transaction data: %v", err)
+    }
+    return nil
+}
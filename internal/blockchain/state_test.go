@@ -0,0 +1,71 @@
+package blockchain
+
+import "testing"
+
+func TestReplayedTransactionIsRejected(t *testing.T) {
+    s := newTestState(t, RegistryPolicy{})
+    _, id := s.citizen("Abebe Bikila")
+
+    suspension := signedBy(t, s.admin)(NewCitizenStatusChangeTx(s.admin.Public, TxCitizenSuspension, id, "Under investigation", false, s.ctx.Timestamp))
+    s.mustApply(suspension)
+    s.advance(10)
+    s.mustApply(signedBy(t, s.admin)(NewCitizenStatusChangeTx(s.admin.Public, TxCitizenReinstatement, id, "", false, s.ctx.Timestamp)))
+    s.advance(10)
+
+    if err := s.apply(suspension); err == nil {
+        t.Fatal("replayed suspension was applied")
+    }
+    if got := s.status(id); got != Approved {
+        t.Fatalf("status after replay = %v, want approved", got)
+    }
+}
+
+func TestFailedTransactionMayBeRetried(t *testing.T) {
+    s := newTestState(t, RegistryPolicy{})
+    key := newTestKey(t)
+    id := generateCitizenID("Derartu Tulu", key.Public)
+
+    // An approval that fails while the applicant is unknown is not spent
+    approval := signedBy(t, s.admin)(NewCitizenApprovalTx(s.admin.Public, id, s.ctx.Timestamp))
+    if err := s.apply(approval); err == nil {
+        t.Fatal("approval of an unknown citizen was applied")
+    }
+    s.mustApply(signedBy(t, key)(NewCitizenRegistrationTx("Derartu Tulu", "1972-06-21", "", key.Public, s.ctx.Timestamp)))
+    s.mustApply(approval)
+    if !s.citizenRegistry.IsCitizen(key.Public) {
+        t.Fatal("citizen was not approved")
+    }
+}
+
+func TestChainRejectsCommittedTransaction(t *testing.T) {
+    admin := newTestKey(t)
+    chain := NewChain(&Genesis{Timestamp: testStartTime, Admins: []string{admin.Public}})
+    citizen := newTestKey(t)
+
+    registration := signedBy(t, citizen)(NewCitizenRegistrationTx("Haile Gebrselassie", "1973-04-18", "", citizen.Public, testStartTime))
+    if err := chain.SubmitTransaction(registration); err != nil {
+        t.Fatal(err)
+    }
+    block, err := chain.ProposeBlock(admin.Public, 0, testStartTime+1)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := chain.AppendBlock(block); err != nil {
+        t.Fatal(err)
+    }
+
+    if err := chain.SubmitTransaction(registration); err == nil {
+        t.Fatal("committed transaction was accepted into the pool again")
+    }
+
+    // A block that carries the transaction again is invalid too
+    replay, err := chain.ProposeBlock(admin.Public, 0, testStartTime+2)
+    if err != nil {
+        t.Fatal(err)
+    }
+    replay.Transactions = append(replay.Transactions, *registration)
+    replay.seal()
+    if err := chain.ValidateBlock(replay); err == nil {
+        t.Fatal("block replaying a committed transaction was valid")
+    }
+}
@@ -0,0 +1,66 @@
+package blockchain
+
+import (
+    "sync"
+)
+
+// Store persists the blocks of a chain so a node can reload them on startup
+type Store interface {
+    // LoadBlocks returns every stored block in chain order
+    LoadBlocks() ([]*Block, error)
+    // AppendBlock durably appends a block after the current head
+    AppendBlock(block *Block) error
+    // TruncateBlocks durably drops every block above height, so that a
+    // chain can switch to another branch
+    TruncateBlocks(height int64) error
+    // Close releases any resources held by the store
+    Close() error
+}
+
+// MemoryStore keeps blocks in memory only; everything is lost on restart
+type MemoryStore struct {
+    blocks []*Block
+    mu     sync.Mutex
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+    return &MemoryStore{
+        blocks: make([]*Block, 0),
+    }
+}
+
+// LoadBlocks returns all blocks held by the store
+func (ms *MemoryStore) LoadBlocks() ([]*Block, error) {
+    ms.mu.Lock()
+    defer ms.mu.Unlock()
+
+    blocks := make([]*Block, len(ms.blocks))
+    copy(blocks, ms.blocks)
+    return blocks, nil
+}
+
+// AppendBlock adds a block to the store
+func (ms *MemoryStore) AppendBlock(block *Block) error {
+    ms.mu.Lock()
+    defer ms.mu.Unlock()
+
+    ms.blocks = append(ms.blocks, block)
+    return nil
+}
+
+// TruncateBlocks drops the blocks above height
+func (ms *MemoryStore) TruncateBlocks(height int64) error {
+    ms.mu.Lock()
+    defer ms.mu.Unlock()
+
+    if height+1 < int64(len(ms.blocks)) {
+        ms.blocks = ms.blocks[:height+1]
+    }
+    return nil
+}
+
+// Close is a no-op for the in-memory store
+func (ms *MemoryStore) Close() error {
+    return nil
+}
@@ -3,64 +3,141 @@ package blockchain
 import (
     "crypto/sha256"
     "encoding/hex"
+    "fmt"
     "time"
 )
 
 // Transaction represents a blockchain transaction
 type Transaction struct {
+    Version   int                    `json:"version"`
     ID        string                 `json:"id"`
     From      string                 `json:"from"`
     To        string                 `json:"to"`
-    Amount    float64               `json:"amount"`
-    Timestamp int64                 `json:"timestamp"`
-    Signature string                `json:"signature,omitempty"`
+    Amount    float64                `json:"amount"`
+    Timestamp int64                  `json:"timestamp"`
+    Nonce     string                 `json:"nonce,omitempty"`
+    Signature string                 `json:"signature,omitempty"`
     Data      map[string]interface{} `json:"data,omitempty"`
 }
 
 // NewTransaction creates a new transaction
 func NewTransaction(from, to string, amount float64) *Transaction {
+    return NewTransactionAt(from, to, amount, time.Now().Unix())
+}
+
+// NewTransactionAt creates a new transaction with the given timestamp, as
+// chosen by the client that signs it
+func NewTransactionAt(from, to string, amount float64, timestamp int64) *Transaction {
     tx := &Transaction{
+        Version:   TransactionVersion,
         From:      from,
         To:        to,
         Amount:    amount,
-        Timestamp: time.Now().Unix(),
+        Timestamp: timestamp,
         Data:      make(map[string]interface{}),
     }
     tx.ID = calculateTransactionHash(tx)
     return tx
 }
 
-// calculateTransactionHash generates a hash for the transaction
+// calculateTransactionHash returns the transaction ID: the hex SHA-256 of its
+// canonical encoding, or "" if the transaction cannot be encoded
 func calculateTransactionHash(tx *Transaction) string {
-    record := tx.From + tx.To + string(tx.Timestamp) + string(int64(tx.Amount*100000000))
-    h := sha256.New()
-    h.Write([]byte(record))
-    return hex.EncodeToString(h.Sum(nil))
+    id, _ := hashTransaction(tx)
+    return id
+}
+
+func hashTransaction(tx *Transaction) (string, error) {
+    encoded, err := encodeTransaction(tx)
+    if err != nil {
+        return "", err
+    }
+    hash := sha256.Sum256(encoded)
+    return hex.EncodeToString(hash[:]), nil
+}
+
+// SetNonce sets the sender-chosen nonce that makes otherwise identical
+// transactions distinct, and updates the ID accordingly
+func (tx *Transaction) SetNonce(nonce string) {
+    tx.Nonce = nonce
+    tx.ID = calculateTransactionHash(tx)
+}
+
+// SigningPayload returns the bytes the sender signs: the canonical encoding
+// of every field except the ID, which is derived from them, and the
+// signature itself
+func (tx *Transaction) SigningPayload() []byte {
+    payload, _ := encodeTransaction(tx)
+    return payload
+}
+
+// data returns tx.Data, treating a missing map like an empty one: empty Data
+// is omitted from JSON and comes back as nil
+func (tx *Transaction) data() map[string]interface{} {
+    if tx.Data == nil {
+        return map[string]interface{}{}
+    }
+    return tx.Data
 }
 
-// SignTransaction adds a signature to the transaction (simplified version)
-func SignTransaction(tx *Transaction, privateKey string) {
-    // In a real implementation, this would use proper cryptographic signing
-    h := sha256.New()
-    h.Write([]byte(tx.ID + privateKey))
-    tx.Signature = hex.EncodeToString(h.Sum(nil))
+// Sign signs the transaction with the sender's private key, which must
+// belong to tx.From
+func (tx *Transaction) Sign(privateKey string) error {
+    publicKey, err := PublicKeyFromPrivate(privateKey)
+    if err != nil {
+        return err
+    }
+    if publicKey != tx.From {
+        return fmt.Errorf("private key does not belong to sender %s", tx.From)
+    }
+
+    payload, err := encodeTransaction(tx)
+    if err != nil {
+        return err
+    }
+    signature, err := Sign(privateKey, payload)
+    if err != nil {
+        return err
+    }
+    tx.Signature = signature
+    return nil
 }
 
-// VerifyTransaction checks if the transaction signature is valid (simplified version)
-func VerifyTransaction(tx *Transaction, publicKey string) bool {
-    // In a real implementation, this would verify the cryptographic signature
-    return tx.Signature != ""
+// VerifySignature checks the signature against the sender's public key
+func (tx *Transaction) VerifySignature() bool {
+    return VerifySignature(tx.From, tx.SigningPayload(), tx.Signature)
+}
+
+// verify checks that the transaction ID matches its contents and that the
+// sender signed it
+func (tx *Transaction) verify() error {
+    id, err := hashTransaction(tx)
+    if err != nil {
+        return err
+    }
+    if tx.ID != id {
+        return fmt.Errorf("transaction ID does not match its contents")
+    }
+    if err := ValidatePublicKey(tx.From); err != nil {
+        return fmt.Errorf("invalid sender: %v", err)
+    }
+    if !tx.VerifySignature() {
+        return fmt.Errorf("invalid transaction signature")
+    }
+    return nil
 }
 
 // TransactionPool manages pending transactions
 type TransactionPool struct {
     transactions map[string]*Transaction
+    order        []string // IDs in arrival order, so blocks replay as submitted
 }
 
 // NewTransactionPool creates a new transaction pool
 func NewTransactionPool() *TransactionPool {
     return &TransactionPool{
         transactions: make(map[string]*Transaction),
+        order:        make([]string, 0),
     }
 }
 
@@ -76,6 +153,7 @@ func (tp *TransactionPool) AddTransaction(tx *Transaction) bool {
     }
     
     tp.transactions[tx.ID] = tx
+    tp.order = append(tp.order, tx.ID)
     return true
 }
 
@@ -85,23 +163,33 @@ func (tp *TransactionPool) GetTransaction(id string) (*Transaction, bool) {
     return tx, exists
 }
 
-// GetAllTransactions returns all transactions in the pool
+// GetAllTransactions returns all transactions in the pool in arrival order
 func (tp *TransactionPool) GetAllTransactions() []*Transaction {
     txs := make([]*Transaction, 0, len(tp.transactions))
-    for _, tx := range tp.transactions {
-        txs = append(txs, tx)
+    for _, id := range tp.order {
+        txs = append(txs, tp.transactions[id])
     }
     return txs
 }
 
 // RemoveTransaction removes a transaction from the pool
 func (tp *TransactionPool) RemoveTransaction(id string) {
+    if _, exists := tp.transactions[id]; !exists {
+        return
+    }
     delete(tp.transactions, id)
+    for i, pending := range tp.order {
+        if pending == id {
+            tp.order = append(tp.order[:i], tp.order[i+1:]...)
+            break
+        }
+    }
 }
 
 // Clear removes all transactions from the pool
 func (tp *TransactionPool) Clear() {
     tp.transactions = make(map[string]*Transaction)
+    tp.order = make([]string, 0)
 }
 
 // Size returns the number of transactions in the pool
@@ -0,0 +1,76 @@
+package blockchain
+
+import "testing"
+
+func TestTransactionSignature(t *testing.T) {
+    sender := newTestKey(t)
+    other := newTestKey(t)
+    tests := []struct {
+        name   string
+        tamper func(tx *Transaction)
+        ok     bool
+    }{
+        {"signed by the sender", func(tx *Transaction) {}, true},
+        {"unsigned", func(tx *Transaction) { tx.Signature = "" }, false},
+        {"truncated signature", func(tx *Transaction) { tx.Signature = tx.Signature[:64] }, false},
+        {"signature not hex", func(tx *Transaction) { tx.Signature = "zz" + tx.Signature[2:] }, false},
+        {"data changed", func(tx *Transaction) { tx.Data["name"] = "Someone Else" }, false},
+        {"data changed with a new ID", func(tx *Transaction) {
+            tx.Data["name"] = "Someone Else"
+            tx.ID = calculateTransactionHash(tx)
+        }, false},
+        {"nonce changed", func(tx *Transaction) { tx.SetNonce("other") }, false},
+        {"sender replaced", func(tx *Transaction) {
+            tx.From = other.Public
+            tx.ID = calculateTransactionHash(tx)
+        }, false},
+        {"re-signed by the new sender", func(tx *Transaction) {
+            tx.From = other.Public
+            tx.ID = calculateTransactionHash(tx)
+            signature, err := Sign(other.Private, tx.SigningPayload())
+            if err != nil {
+                t.Fatal(err)
+            }
+            tx.Signature = signature
+        }, true},
+        {"malformed sender", func(tx *Transaction) {
+            tx.From = "abcd"
+            tx.ID = calculateTransactionHash(tx)
+        }, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tx := signedBy(t, sender)(NewCitizenRegistrationTx("Abebe Bikila", "1932-08-07", "", sender.Public, testStartTime))
+            tt.tamper(tx)
+            if err := tx.verify(); (err == nil) != tt.ok {
+                t.Errorf("verify() = %v, want ok = %v", err, tt.ok)
+            }
+        })
+    }
+}
+
+func TestSignRequiresSenderKey(t *testing.T) {
+    sender := newTestKey(t)
+    other := newTestKey(t)
+    tx, err := NewCitizenRegistrationTx("Abebe Bikila", "1932-08-07", "", sender.Public, testStartTime)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := tx.Sign(other.Private); err == nil {
+        t.Error("transaction was signed with a key other than the sender's")
+    }
+    if err := tx.Sign("not a key"); err == nil {
+        t.Error("transaction was signed with a malformed key")
+    }
+}
+
+func TestChainRejectsForgedTransaction(t *testing.T) {
+    chain := NewChain(&Genesis{Timestamp: testStartTime})
+    sender := newTestKey(t)
+    tx := signedBy(t, sender)(NewCitizenRegistrationTx("Abebe Bikila", "1932-08-07", "", sender.Public, testStartTime))
+    tx.Data["name"] = "Someone Else"
+    tx.ID = calculateTransactionHash(tx)
+    if err := chain.SubmitTransaction(tx); err == nil {
+        t.Error("transaction with a forged signature was accepted")
+    }
+}
@@ -0,0 +1,89 @@
+package blockchain
+
+import (
+    "errors"
+    "slices"
+    "sort"
+    "time"
+)
+
+// dateOfBirthLayout is the layout of Citizen.DateOfBirth
+const dateOfBirthLayout = "2006-01-02"
+
+// validateRoll checks the voter roll options of an election starting in
+// the block described by ctx
+func (o *ElectionOptions) validateRoll(ctx BlockContext) error {
+    if o.RollHeight < 0 || o.RollHeight > ctx.Height {
+        return errors.New("the voter roll must be frozen at a block no later than the election's start")
+    }
+    if o.MinimumAge < 0 {
+        return errors.New("minimum age cannot be negative")
+    }
+    return nil
+}
+
+// voterRoll returns the IDs, sorted, of the citizens eligible to vote in an
+// election starting at the block timestamp startDate: those approved as of
+// the block at rollHeight and at least minimumAge years old on startDate.
+// Statuses are rebuilt from the citizens' histories, so a change after
+// rollHeight does not move anyone on or off the roll. A citizen whose date
+// of birth cannot be read is only eligible without a minimum age.
+func (cr *CitizenRegistry) voterRoll(rollHeight int64, minimumAge int, startDate int64) []string {
+    cr.mu.RLock()
+    defer cr.mu.RUnlock()
+
+    start := time.Unix(startDate, 0).UTC()
+    roll := make([]string, 0)
+    for _, citizen := range cr.citizens {
+        if status, registered := citizen.statusAt(rollHeight); !registered || status != Approved {
+            continue
+        }
+        if minimumAge > 0 {
+            born, err := time.Parse(dateOfBirthLayout, citizen.DateOfBirth)
+            if err != nil || born.AddDate(minimumAge, 0, 0).After(start) {
+                continue
+            }
+        }
+        roll = append(roll, citizen.ID)
+    }
+    sort.Strings(roll)
+    return roll
+}
+
+// statusAt returns the citizen's status as of the block at height, from
+// their history, and false if they had not registered by then
+func (c *Citizen) statusAt(height int64) (CitizenStatus, bool) {
+    var status CitizenStatus
+    registered := false
+    for _, change := range c.History {
+        if change.Height > height {
+            break
+        }
+        status, registered = change.Status, true
+    }
+    return status, registered
+}
+
+// onRoll reports whether the citizen with the given ID may vote in the
+// election
+func (e *Election) onRoll(citizenID string) bool {
+    _, found := slices.BinarySearch(e.VoterRoll, citizenID)
+    return found
+}
+
+// ballotsCast returns the number of ballots the election counted
+func (e *Election) ballotsCast() int {
+    if e.encrypted() {
+        return len(e.Ballots)
+    }
+    return len(e.Votes) + len(e.Rankings) + len(e.Approvals) + len(e.Scores)
+}
+
+// recordTurnout publishes the turnout of the completed election against
+// its voter roll. Citizens whose delegated votes were counted took part.
+func (e *Election) recordTurnout() {
+    e.Turnout = e.ballotsCast() + e.DelegatedWeight
+    if e.Eligible > 0 {
+        e.TurnoutPercent = float64(e.Turnout) * 100 / float64(e.Eligible)
+    }
+}
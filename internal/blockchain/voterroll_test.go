@@ -0,0 +1,91 @@
+package blockchain
+
+import (
+    "slices"
+    "testing"
+)
+
+func TestVoterRollAsOfRollHeight(t *testing.T) {
+    tests := []struct {
+        name   string
+        before []string // status changes before the roll height
+        after  []string // status changes between the roll height and the start
+        onRoll bool
+    }{
+        {"approved", nil, nil, true},
+        {"suspended after the roll", nil, []string{TxCitizenSuspension}, true},
+        {"revoked after the roll", nil, []string{TxCitizenRevocation}, true},
+        {"suspended at the roll", []string{TxCitizenSuspension}, nil, false},
+        {"reinstated after the roll", []string{TxCitizenSuspension}, []string{TxCitizenReinstatement}, false},
+        {"reinstated before the roll", []string{TxCitizenSuspension, TxCitizenReinstatement}, nil, true},
+        {"revoked at the roll", []string{TxCitizenRevocation}, nil, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestState(t, RegistryPolicy{})
+            _, id := s.citizen("Voter")
+            change := func(txType string) {
+                s.advance(10)
+                s.mustApply(signedBy(t, s.admin)(NewCitizenStatusChangeTx(s.admin.Public, txType, id, "Decision", false, s.ctx.Timestamp)))
+            }
+            for _, txType := range tt.before {
+                change(txType)
+            }
+            s.advance(10)
+            rollHeight := s.ctx.Height
+            for _, txType := range tt.after {
+                change(txType)
+            }
+            s.advance(10)
+
+            election := s.election(s.startElection(ElectionOptions{RollHeight: rollHeight}))
+            if got := slices.Contains(election.VoterRoll, id); got != tt.onRoll {
+                t.Errorf("on roll = %v, want %v", got, tt.onRoll)
+            }
+        })
+    }
+}
+
+func TestVoterRollExcludesLaterApprovals(t *testing.T) {
+    s := newTestState(t, RegistryPolicy{})
+    _, early := s.citizen("Early")
+    _, pending := s.register("Pending")
+    s.advance(10)
+    rollHeight := s.ctx.Height
+    s.advance(10)
+    s.mustApply(signedBy(t, s.admin)(NewCitizenApprovalTx(s.admin.Public, pending, s.ctx.Timestamp)))
+    s.citizen("Late")
+
+    election := s.election(s.startElection(ElectionOptions{RollHeight: rollHeight}))
+    if !slices.Equal(election.VoterRoll, []string{early}) {
+        t.Errorf("voter roll = %v, want only %s", election.VoterRoll, early)
+    }
+}
+
+func TestVoterRollMinimumAge(t *testing.T) {
+    tests := []struct {
+        name        string
+        dateOfBirth string
+        minimumAge  int
+        onRoll      bool
+    }{
+        {"old enough", "1990-01-01", 18, true},
+        {"too young", "2020-01-01", 18, false},
+        {"unreadable date without a minimum age", "sometime", 0, true},
+        {"unreadable date with a minimum age", "sometime", 18, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestState(t, RegistryPolicy{})
+            key := newTestKey(t)
+            id := generateCitizenID("Voter", key.Public)
+            s.mustApply(signedBy(t, key)(NewCitizenRegistrationTx("Voter", tt.dateOfBirth, "", key.Public, s.ctx.Timestamp)))
+            s.mustApply(signedBy(t, s.admin)(NewCitizenApprovalTx(s.admin.Public, id, s.ctx.Timestamp)))
+
+            election := s.election(s.startElection(ElectionOptions{MinimumAge: tt.minimumAge}))
+            if got := slices.Contains(election.VoterRoll, id); got != tt.onRoll {
+                t.Errorf("on roll = %v, want %v", got, tt.onRoll)
+            }
+        })
+    }
+}
@@ -0,0 +1,828 @@
+package consensus
+
+import (
+    "fmt"
+    "log"
+    "sort"
+    "sync"
+    "time"
+    "virtual_ethiopia_dap/internal/blockchain"
+)
+
+// Consensus message types
+const (
+    MsgProposal  = "proposal"
+    MsgPrevote   = "prevote"
+    MsgPrecommit = "precommit"
+    MsgCommit    = "commit" // a finalized block with its commit certificate
+)
+
+// Message is a consensus message exchanged between BFT validators
+type Message struct {
+    Type     string            `json:"type"`
+    Proposal *Proposal         `json:"proposal,omitempty"`
+    Vote     *Vote             `json:"vote,omitempty"`
+    Block    *blockchain.Block `json:"block,omitempty"`
+}
+
+// Proposal offers a block for a height and round. ValidRound is the round in
+// which the block already gathered a prevote quorum, or -1 for a fresh block.
+type Proposal struct {
+    Height     int64             `json:"height"`
+    Round      int64             `json:"round"`
+    ValidRound int64             `json:"validRound"`
+    Block      *blockchain.Block `json:"block"`
+    Proposer   string            `json:"proposer"`
+    Signature  string            `json:"signature"`
+}
+
+// Vote is a prevote or precommit. An empty BlockHash is a vote for nil.
+type Vote struct {
+    Type      string `json:"type"`
+    Height    int64  `json:"height"`
+    Round     int64  `json:"round"`
+    BlockHash string `json:"blockHash"`
+    Validator string `json:"validator"`
+    Signature string `json:"signature"`
+}
+
+// Transport delivers consensus messages to the other validators
+type Transport interface {
+    Broadcast(msg *Message)
+}
+
+func proposalSignBytes(p *Proposal) []byte {
+    return []byte(fmt.Sprintf("%s|%d|%d|%d|%s", MsgProposal, p.Height, p.Round, p.ValidRound, p.Block.HashHex()))
+}
+
+func voteSignBytes(voteType string, height, round int64, blockHash string) []byte {
+    return []byte(fmt.Sprintf("%s|%d|%d|%s", voteType, height, round, blockHash))
+}
+
+// resendInterval is how often a validator re-gossips its current round
+// messages, standing in for acknowledgements on a lossy network
+const resendInterval = 500 * time.Millisecond
+
+// maxRoundsAhead is how far past its current round a validator accepts
+// proposals and votes. Honest validators only move on when a round times
+// out, so messages for rounds further ahead are dropped, not stored.
+const maxRoundsAhead = 32
+
+type step int
+
+const (
+    stepPropose step = iota
+    stepPrevote
+    stepPrecommit
+)
+
+type timeoutEvent struct {
+    height int64
+    round  int64
+    step   step
+}
+
+// BFT is a Tendermint-style consensus engine. Each height runs rounds of
+// propose, prevote and precommit; a block is final once more than two thirds
+// of the validators precommit it, and their signatures are stored on the
+// block as its commit certificate. Rounds that do not reach a decision time
+// out and move to the next proposer.
+type BFT struct {
+    chain      *blockchain.Chain
+    config     Config
+    validators []string
+    publicKey  string
+    transport  Transport
+    broadcast  func(block *blockchain.Block)
+    inbox      chan *Message
+    timeouts   chan timeoutEvent
+    stop       chan struct{}
+    wg         sync.WaitGroup
+    mu         sync.Mutex
+
+    // Round state, only touched by the run loop
+    height        int64
+    round         int64
+    step          step
+    started       bool
+    lockedRound   int64
+    lockedBlock   *blockchain.Block
+    validRound    int64
+    validBlock    *blockchain.Block
+    proposals     map[int64]*Proposal
+    prevotes      map[int64]map[string]*Vote
+    precommits    map[int64]map[string]*Vote
+    validity      map[string]bool
+    timersSet     map[timeoutEvent]bool
+    polSeen       map[int64]bool
+    future        []*Message
+    queue         []*Message
+    lastCommit    time.Time
+    lastResend    time.Time
+    helpedHeights map[int64]time.Time
+}
+
+// NewBFT creates a BFT engine and installs it as the chain's block verifier
+func NewBFT(chain *blockchain.Chain, config Config) (*BFT, error) {
+    engine := &BFT{
+        chain:         chain,
+        config:        config,
+        validators:    chain.Genesis().Validators,
+        inbox:         make(chan *Message, 1024),
+        timeouts:      make(chan timeoutEvent, 64),
+        helpedHeights: make(map[int64]time.Time),
+    }
+
+    if config.SlotTimeout <= 0 {
+        return nil, fmt.Errorf("slot timeout must be positive")
+    }
+
+    if config.PrivateKey != "" {
+        publicKey, err := blockchain.PublicKeyFromPrivate(config.PrivateKey)
+        if err != nil {
+            return nil, err
+        }
+        if !containsValidator(engine.validators, publicKey) {
+            return nil, fmt.Errorf("key %s is not in the validator set", publicKey)
+        }
+        engine.publicKey = publicKey
+    }
+
+    engine.enterHeight(chain.Height() + 1)
+    chain.SetBlockVerifier(engine)
+    return engine, nil
+}
+
+// quorum is the number of validators that make up more than two thirds
+func (b *BFT) quorum() int {
+    return len(b.validators)*2/3 + 1
+}
+
+// skipThreshold is the number of validators that must be in a later round
+// before this node jumps there, i.e. at least one honest validator
+func (b *BFT) skipThreshold() int {
+    return (len(b.validators)-1)/3 + 1
+}
+
+// Proposer returns the validator expected to propose at height and round
+func (b *BFT) Proposer(height, round int64) string {
+    return proposerAt(b.validators, height, round)
+}
+
+// SetTransport registers how consensus messages reach other validators
+func (b *BFT) SetTransport(transport Transport) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.transport = transport
+}
+
+// SetBroadcaster registers the function used to announce committed blocks
+func (b *BFT) SetBroadcaster(broadcast func(block *blockchain.Block)) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.broadcast = broadcast
+}
+
+// Start runs the consensus loop. Nodes without a validator key still run it
+// so that they follow commit messages, but never vote.
+func (b *BFT) Start() error {
+    b.stop = make(chan struct{})
+    b.wg.Add(1)
+    go b.run()
+    if b.publicKey != "" {
+        log.Printf("BFT validator %s started", b.publicKey)
+    }
+    return nil
+}
+
+// Stop halts the consensus loop
+func (b *BFT) Stop() {
+    if b.stop == nil {
+        return
+    }
+    close(b.stop)
+    b.wg.Wait()
+    b.stop = nil
+}
+
+// HandleMessage queues a consensus message received from a peer
+func (b *BFT) HandleMessage(msg *Message) {
+    select {
+    case b.inbox <- msg:
+    default:
+        log.Printf("Consensus inbox full, dropping %s message", msg.Type)
+    }
+}
+
+// HandleBlock appends a committed block from a peer; VerifyBlock checks its
+// commit certificate
+func (b *BFT) HandleBlock(block *blockchain.Block) error {
+    if err := b.chain.AppendBlock(block); err != nil {
+        return err
+    }
+    b.HandleMessage(&Message{Type: MsgCommit})
+    return nil
+}
+
+// VerifyBlock accepts only blocks carrying a valid commit certificate from
+// more than two thirds of the validators. A block is proposed no later than
+// the round that commits it, and the certificate is checked before the
+// rounds are used for anything else.
+func (b *BFT) VerifyBlock(prev, block *blockchain.Block) error {
+    if block.Round < 0 {
+        return fmt.Errorf("negative round")
+    }
+    if block.Commit != nil && block.Round > block.Commit.Round {
+        return fmt.Errorf("block round %d is after its commit round %d", block.Round, block.Commit.Round)
+    }
+    if err := b.verifyCommit(block); err != nil {
+        return err
+    }
+    expected := b.Proposer(block.Index, block.Round)
+    if block.Proposer != expected {
+        return fmt.Errorf("proposer %s is not the expected proposer %s for round %d", block.Proposer, expected, block.Round)
+    }
+    if !block.VerifySignature() {
+        return fmt.Errorf("invalid proposer signature")
+    }
+    if block.Timestamp > time.Now().Add(maxClockDrift).Unix() {
+        return fmt.Errorf("block timestamp is too far in the future")
+    }
+    return nil
+}
+
+func (b *BFT) verifyCommit(block *blockchain.Block) error {
+    if block.Commit == nil {
+        return fmt.Errorf("missing commit certificate")
+    }
+
+    signBytes := voteSignBytes(MsgPrecommit, block.Index, block.Commit.Round, block.HashHex())
+    signers := make(map[string]bool)
+    for _, sig := range block.Commit.Signatures {
+        if signers[sig.Validator] || !containsValidator(b.validators, sig.Validator) {
+            continue
+        }
+        if blockchain.VerifySignature(sig.Validator, signBytes, sig.Signature) {
+            signers[sig.Validator] = true
+        }
+    }
+    if len(signers) < b.quorum() {
+        return fmt.Errorf("commit certificate has %d valid signatures, need %d", len(signers), b.quorum())
+    }
+    return nil
+}
+
+func (b *BFT) run() {
+    defer b.wg.Done()
+
+    ticker := time.NewTicker(200 * time.Millisecond)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-b.stop:
+            return
+        case msg := <-b.inbox:
+            b.handleMessage(msg)
+        case timeout := <-b.timeouts:
+            b.handleTimeout(timeout)
+        case <-ticker.C:
+            b.tick()
+        }
+        b.drainQueue()
+    }
+}
+
+// drainQueue processes messages this node sent to itself
+func (b *BFT) drainQueue() {
+    for len(b.queue) > 0 {
+        msg := b.queue[0]
+        b.queue = b.queue[1:]
+        b.handleMessage(msg)
+    }
+}
+
+// enterHeight resets the round state for a new height
+func (b *BFT) enterHeight(height int64) {
+    b.height = height
+    b.round = 0
+    b.step = stepPropose
+    b.started = false
+    b.lockedRound = -1
+    b.lockedBlock = nil
+    b.validRound = -1
+    b.validBlock = nil
+    b.proposals = make(map[int64]*Proposal)
+    b.prevotes = make(map[int64]map[string]*Vote)
+    b.precommits = make(map[int64]map[string]*Vote)
+    b.validity = make(map[string]bool)
+    b.timersSet = make(map[timeoutEvent]bool)
+    b.polSeen = make(map[int64]bool)
+    b.lastCommit = time.Now()
+
+    future := b.future
+    b.future = nil
+    for _, msg := range future {
+        b.queue = append(b.queue, msg)
+    }
+}
+
+// tick follows blocks committed through other paths (sync, commit messages)
+// and starts a height once there is something to agree on
+func (b *BFT) tick() {
+    if head := b.chain.Height(); head >= b.height {
+        b.enterHeight(head + 1)
+    }
+    if b.publicKey == "" {
+        return
+    }
+    if b.started {
+        if time.Since(b.lastResend) >= resendInterval {
+            b.resendRound()
+        }
+        return
+    }
+    if time.Since(b.lastCommit) < b.config.BlockInterval {
+        return
+    }
+    if b.chain.PendingCount() > 0 || b.chain.TransitionDue(time.Now().Unix()) || len(b.proposals) > 0 || len(b.prevotes) > 0 || len(b.precommits) > 0 {
+        b.startRound(0)
+    }
+}
+
+func (b *BFT) startRound(round int64) {
+    b.started = true
+    b.round = round
+    b.step = stepPropose
+
+    if b.publicKey != "" && b.Proposer(b.height, round) == b.publicKey {
+        if err := b.propose(); err != nil {
+            log.Printf("Failed to propose block %d round %d: %v", b.height, round, err)
+        }
+    }
+    b.scheduleTimeout(stepPropose)
+    b.evaluate()
+}
+
+func (b *BFT) propose() error {
+    block := b.validBlock
+    if block == nil {
+        prev, err := b.chain.GetLatestBlock()
+        if err != nil {
+            return err
+        }
+        timestamp := time.Now().Unix()
+        if timestamp < prev.Timestamp {
+            timestamp = prev.Timestamp
+        }
+        block, err = b.chain.ProposeBlock(b.publicKey, b.round, timestamp)
+        if err != nil {
+            return err
+        }
+        if err := block.Sign(b.config.PrivateKey); err != nil {
+            return err
+        }
+    }
+
+    proposal := &Proposal{
+        Height:     b.height,
+        Round:      b.round,
+        ValidRound: b.validRound,
+        Block:      block,
+        Proposer:   b.publicKey,
+    }
+    signature, err := blockchain.Sign(b.config.PrivateKey, proposalSignBytes(proposal))
+    if err != nil {
+        return err
+    }
+    proposal.Signature = signature
+    b.send(&Message{Type: MsgProposal, Proposal: proposal})
+    return nil
+}
+
+func (b *BFT) vote(voteType, blockHash string) {
+    if b.publicKey == "" {
+        return
+    }
+    signature, err := blockchain.Sign(b.config.PrivateKey, voteSignBytes(voteType, b.height, b.round, blockHash))
+    if err != nil {
+        log.Printf("Failed to sign %s: %v", voteType, err)
+        return
+    }
+    b.send(&Message{Type: voteType, Vote: &Vote{
+        Type:      voteType,
+        Height:    b.height,
+        Round:     b.round,
+        BlockHash: blockHash,
+        Validator: b.publicKey,
+        Signature: signature,
+    }})
+}
+
+// send broadcasts msg to peers and queues it for this node as well
+func (b *BFT) send(msg *Message) {
+    b.mu.Lock()
+    transport := b.transport
+    b.mu.Unlock()
+
+    if transport != nil {
+        transport.Broadcast(msg)
+    }
+    b.queue = append(b.queue, msg)
+}
+
+// timeout returns how long a step may take in the current round. Timeouts
+// grow with the round so that a slow network eventually gets enough time.
+func (b *BFT) timeout(s step) time.Duration {
+    base := b.config.SlotTimeout
+    if s != stepPropose {
+        base /= 2
+    }
+    return base + time.Duration(b.round)*base/2
+}
+
+func (b *BFT) scheduleTimeout(s step) {
+    event := timeoutEvent{height: b.height, round: b.round, step: s}
+    if b.timersSet[event] {
+        return
+    }
+    b.timersSet[event] = true
+    stop := b.stop
+    time.AfterFunc(b.timeout(s), func() {
+        select {
+        case b.timeouts <- event:
+        case <-stop:
+        }
+    })
+}
+
+func (b *BFT) handleTimeout(event timeoutEvent) {
+    if event.height != b.height || event.round != b.round {
+        return
+    }
+    switch event.step {
+    case stepPropose:
+        if b.step == stepPropose {
+            b.vote(MsgPrevote, "")
+            b.step = stepPrevote
+        }
+    case stepPrevote:
+        if b.step == stepPrevote {
+            b.vote(MsgPrecommit, "")
+            b.step = stepPrecommit
+        }
+    case stepPrecommit:
+        b.startRound(b.round + 1)
+    }
+    b.evaluate()
+}
+
+// resendRound re-gossips this node's current proposal and all of its votes
+// at this height, so that peers who lost them can still reach a quorum,
+// including the prevotes that justify re-proposing a block from an earlier
+// round
+func (b *BFT) resendRound() {
+    b.lastResend = time.Now()
+    b.mu.Lock()
+    transport := b.transport
+    b.mu.Unlock()
+    if transport == nil {
+        return
+    }
+
+    if proposal, ok := b.proposals[b.round]; ok && proposal.Proposer == b.publicKey {
+        transport.Broadcast(&Message{Type: MsgProposal, Proposal: proposal})
+    }
+    for round := int64(0); round <= b.round; round++ {
+        if vote, ok := b.prevotes[round][b.publicKey]; ok {
+            transport.Broadcast(&Message{Type: MsgPrevote, Vote: vote})
+        }
+        if vote, ok := b.precommits[round][b.publicKey]; ok {
+            transport.Broadcast(&Message{Type: MsgPrecommit, Vote: vote})
+        }
+    }
+}
+
+func (b *BFT) handleMessage(msg *Message) {
+    switch msg.Type {
+    case MsgCommit:
+        b.handleCommit(msg)
+        return
+    case MsgProposal:
+        if msg.Proposal == nil || !b.acceptHeight(msg.Proposal.Height, msg) {
+            return
+        }
+        if err := b.addProposal(msg.Proposal); err != nil {
+            log.Printf("Rejected proposal for %d/%d: %v", msg.Proposal.Height, msg.Proposal.Round, err)
+            return
+        }
+    case MsgPrevote, MsgPrecommit:
+        if msg.Vote == nil || !b.acceptHeight(msg.Vote.Height, msg) {
+            return
+        }
+        if err := b.addVote(msg.Type, msg.Vote); err != nil {
+            log.Printf("Rejected %s for %d/%d: %v", msg.Type, msg.Vote.Height, msg.Vote.Round, err)
+            return
+        }
+    default:
+        return
+    }
+
+    if !b.started && b.publicKey != "" {
+        b.startRound(0)
+        return
+    }
+    b.evaluate()
+}
+
+// acceptHeight reports whether a message is for the current height. Messages
+// for the next height are buffered; a peer still on an older height is sent
+// the block it is missing.
+func (b *BFT) acceptHeight(height int64, msg *Message) bool {
+    switch {
+    case height == b.height:
+        return true
+    case height == b.height+1:
+        if len(b.future) < 1024 {
+            b.future = append(b.future, msg)
+        }
+    case height < b.height:
+        b.helpPeer(height)
+    }
+    return false
+}
+
+// helpPeer rebroadcasts the committed block at height, at most once a second
+func (b *BFT) helpPeer(height int64) {
+    if last, ok := b.helpedHeights[height]; ok && time.Since(last) < time.Second {
+        return
+    }
+    block, ok := b.chain.GetBlock(height)
+    if !ok || block.Commit == nil {
+        return
+    }
+    b.helpedHeights[height] = time.Now()
+    for h := range b.helpedHeights {
+        if h < height-16 {
+            delete(b.helpedHeights, h)
+        }
+    }
+
+    b.mu.Lock()
+    transport := b.transport
+    b.mu.Unlock()
+    if transport != nil {
+        transport.Broadcast(&Message{Type: MsgCommit, Block: block})
+    }
+}
+
+func (b *BFT) handleCommit(msg *Message) {
+    if msg.Block != nil && msg.Block.Index == b.chain.Height()+1 {
+        if err := b.chain.AppendBlock(msg.Block); err != nil {
+            log.Printf("Rejected committed block %d: %v", msg.Block.Index, err)
+            return
+        }
+        b.announce(msg.Block)
+    }
+    if head := b.chain.Height(); head >= b.height {
+        b.enterHeight(head + 1)
+    }
+}
+
+// checkRound rejects a round outside the window this node keeps messages for
+func (b *BFT) checkRound(round int64) error {
+    if round < 0 || round > b.round+maxRoundsAhead {
+        return fmt.Errorf("round %d is outside the window of round %d", round, b.round)
+    }
+    return nil
+}
+
+func (b *BFT) addProposal(p *Proposal) error {
+    if err := b.checkRound(p.Round); err != nil {
+        return err
+    }
+    if _, exists := b.proposals[p.Round]; exists {
+        return nil
+    }
+    if p.ValidRound < -1 || (p.ValidRound != -1 && p.ValidRound >= p.Round) {
+        return fmt.Errorf("invalid rounds")
+    }
+    if p.Proposer != b.Proposer(p.Height, p.Round) {
+        return fmt.Errorf("%s is not the proposer", p.Proposer)
+    }
+    if p.Block == nil || p.Block.Index != p.Height {
+        return fmt.Errorf("proposal block does not match height")
+    }
+    if !blockchain.VerifySignature(p.Proposer, proposalSignBytes(p), p.Signature) {
+        return fmt.Errorf("invalid proposal signature")
+    }
+    block := p.Block
+    if block.Round < 0 || block.Round > p.Round || block.Proposer != b.Proposer(block.Index, block.Round) || !block.VerifySignature() {
+        return fmt.Errorf("invalid block header")
+    }
+    if p.ValidRound == -1 && block.Round != p.Round {
+        return fmt.Errorf("fresh proposal carries a block from another round")
+    }
+    b.proposals[p.Round] = p
+    return nil
+}
+
+func (b *BFT) addVote(voteType string, v *Vote) error {
+    if v.Type != voteType {
+        return fmt.Errorf("malformed vote")
+    }
+    if err := b.checkRound(v.Round); err != nil {
+        return err
+    }
+    if !containsValidator(b.validators, v.Validator) {
+        return fmt.Errorf("%s is not a validator", v.Validator)
+    }
+    if !blockchain.VerifySignature(v.Validator, voteSignBytes(v.Type, v.Height, v.Round, v.BlockHash), v.Signature) {
+        return fmt.Errorf("invalid vote signature")
+    }
+
+    votes := b.prevotes
+    if voteType == MsgPrecommit {
+        votes = b.precommits
+    }
+    if votes[v.Round] == nil {
+        votes[v.Round] = make(map[string]*Vote)
+    }
+    // Only the first vote of a validator counts; equivocations are ignored
+    if _, exists := votes[v.Round][v.Validator]; !exists {
+        votes[v.Round][v.Validator] = v
+    }
+    return nil
+}
+
+// countVotes returns how many votes in a round are for blockHash, and how
+// many votes there are in total
+func countVotes(votes map[string]*Vote, blockHash string) (matching, total int) {
+    for _, v := range votes {
+        total++
+        if v.BlockHash == blockHash {
+            matching++
+        }
+    }
+    return matching, total
+}
+
+// isValid checks a proposed block against the chain, caching the result
+func (b *BFT) isValid(block *blockchain.Block) bool {
+    hash := block.HashHex()
+    if valid, ok := b.validity[hash]; ok {
+        return valid
+    }
+    valid := b.chain.ValidateBlock(block) == nil && block.Timestamp <= time.Now().Add(maxClockDrift).Unix()
+    b.validity[hash] = valid
+    return valid
+}
+
+// evaluate applies the Tendermint upon-rules to the current round state
+func (b *BFT) evaluate() {
+    if !b.started {
+        return
+    }
+
+    // Decide as soon as any round has a proposal with a precommit quorum
+    for round, proposal := range b.proposals {
+        matching, _ := countVotes(b.precommits[round], proposal.Block.HashHex())
+        if matching >= b.quorum() && b.isValid(proposal.Block) {
+            b.commit(round, proposal.Block)
+            return
+        }
+    }
+
+    // Skip ahead if enough validators are already in a later round
+    if round, ok := b.roundAhead(); ok {
+        b.startRound(round)
+        return
+    }
+
+    proposal := b.proposals[b.round]
+    if b.step == stepPropose && proposal != nil {
+        hash := proposal.Block.HashHex()
+        if proposal.ValidRound == -1 {
+            if b.isValid(proposal.Block) && (b.lockedRound == -1 || b.lockedBlock.HashHex() == hash) {
+                b.vote(MsgPrevote, hash)
+            } else {
+                b.vote(MsgPrevote, "")
+            }
+            b.step = stepPrevote
+        } else if matching, _ := countVotes(b.prevotes[proposal.ValidRound], hash); matching >= b.quorum() {
+            if b.isValid(proposal.Block) && (b.lockedRound <= proposal.ValidRound || b.lockedBlock.HashHex() == hash) {
+                b.vote(MsgPrevote, hash)
+            } else {
+                b.vote(MsgPrevote, "")
+            }
+            b.step = stepPrevote
+        }
+    }
+
+    if proposal != nil && b.step >= stepPrevote && !b.polSeen[b.round] {
+        hash := proposal.Block.HashHex()
+        if matching, _ := countVotes(b.prevotes[b.round], hash); matching >= b.quorum() && b.isValid(proposal.Block) {
+            b.polSeen[b.round] = true
+            if b.step == stepPrevote {
+                b.lockedRound = b.round
+                b.lockedBlock = proposal.Block
+                b.vote(MsgPrecommit, hash)
+                b.step = stepPrecommit
+            }
+            b.validRound = b.round
+            b.validBlock = proposal.Block
+        }
+    }
+
+    if b.step == stepPrevote {
+        if nilVotes, _ := countVotes(b.prevotes[b.round], ""); nilVotes >= b.quorum() {
+            b.vote(MsgPrecommit, "")
+            b.step = stepPrecommit
+        }
+    }
+
+    // Timeouts are armed on entering a step rather than on seeing a quorum
+    // of votes, so that lost votes can only slow a round down, never stall it
+    if b.step >= stepPrevote {
+        b.scheduleTimeout(stepPrevote)
+    }
+    if b.step >= stepPrecommit {
+        b.scheduleTimeout(stepPrecommit)
+    }
+}
+
+// roundAhead returns the lowest later round in which at least
+// skipThreshold validators have sent a message
+func (b *BFT) roundAhead() (int64, bool) {
+    senders := make(map[int64]map[string]bool)
+    note := func(round int64, validator string) {
+        if round <= b.round {
+            return
+        }
+        if senders[round] == nil {
+            senders[round] = make(map[string]bool)
+        }
+        senders[round][validator] = true
+    }
+    for round, p := range b.proposals {
+        note(round, p.Proposer)
+    }
+    for round, votes := range b.prevotes {
+        for validator := range votes {
+            note(round, validator)
+        }
+    }
+    for round, votes := range b.precommits {
+        for validator := range votes {
+            note(round, validator)
+        }
+    }
+
+    var best int64 = -1
+    for round, validators := range senders {
+        if len(validators) >= b.skipThreshold() && (best == -1 || round < best) {
+            best = round
+        }
+    }
+    return best, best != -1
+}
+
+// commit finalizes block with the precommits gathered in round
+func (b *BFT) commit(round int64, block *blockchain.Block) {
+    hash := block.HashHex()
+    signatures := make([]blockchain.CommitSig, 0)
+    for _, v := range b.precommits[round] {
+        if v.BlockHash == hash {
+            signatures = append(signatures, blockchain.CommitSig{Validator: v.Validator, Signature: v.Signature})
+        }
+    }
+    sort.Slice(signatures, func(i, j int) bool {
+        return signatures[i].Validator < signatures[j].Validator
+    })
+
+    committed := *block
+    committed.Commit = &blockchain.CommitCertificate{Round: round, Signatures: signatures}
+    if err := b.chain.AppendBlock(&committed); err != nil {
+        log.Printf("Failed to commit block %d: %v", block.Index, err)
+        return
+    }
+    log.Printf("Committed block %d (round %d) with %d transactions", committed.Index, round, len(committed.Transactions))
+
+    b.mu.Lock()
+    transport := b.transport
+    b.mu.Unlock()
+    if transport != nil {
+        transport.Broadcast(&Message{Type: MsgCommit, Block: &committed})
+    }
+    b.announce(&committed)
+    b.enterHeight(committed.Index + 1)
+}
+
+func (b *BFT) announce(block *blockchain.Block) {
+    b.mu.Lock()
+    broadcast := b.broadcast
+    b.mu.Unlock()
+    if broadcast != nil {
+        broadcast(block)
+    }
+}
@@ -0,0 +1,134 @@
+package consensus
+
+import (
+    "math"
+    "testing"
+    "time"
+    "virtual_ethiopia_dap/internal/blockchain"
+)
+
+// newTestBFT returns an engine that is not started, run by the first of four
+// validators, with the private keys of all of them
+func newTestBFT(t *testing.T) (*BFT, []string, []string) {
+    t.Helper()
+    publicKeys, privateKeys, genesis := testValidators(t, 4)
+    engine, err := NewBFT(blockchain.NewChain(genesis), Config{PrivateKey: privateKeys[0], SlotTimeout: time.Second})
+    if err != nil {
+        t.Fatal(err)
+    }
+    return engine, publicKeys, privateKeys
+}
+
+// signedProposal returns a fresh proposal for height 1 and round, signed by
+// the validator at index
+func signedProposal(t *testing.T, engine *BFT, publicKeys, privateKeys []string, index int, round int64) *Proposal {
+    t.Helper()
+    block, err := engine.chain.ProposeBlock(publicKeys[index], round, time.Now().Unix())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := block.Sign(privateKeys[index]); err != nil {
+        t.Fatal(err)
+    }
+    proposal := &Proposal{Height: 1, Round: round, ValidRound: -1, Block: block, Proposer: publicKeys[index]}
+    if proposal.Signature, err = blockchain.Sign(privateKeys[index], proposalSignBytes(proposal)); err != nil {
+        t.Fatal(err)
+    }
+    return proposal
+}
+
+func TestAddProposalRoundWindow(t *testing.T) {
+    engine, publicKeys, privateKeys := newTestBFT(t)
+    index := func(round int64) int {
+        for i, key := range publicKeys {
+            if key == engine.Proposer(1, round) {
+                return i
+            }
+        }
+        t.Fatalf("no proposer for round %d", round)
+        return -1
+    }
+
+    tests := []struct {
+        name  string
+        round int64
+        ok    bool
+    }{
+        {"current round", 0, true},
+        {"within the window", maxRoundsAhead, true},
+        {"past the window", maxRoundsAhead + 1, false},
+        {"largest round", math.MaxInt64, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            proposal := signedProposal(t, engine, publicKeys, privateKeys, index(tt.round), tt.round)
+            if err := engine.addProposal(proposal); (err == nil) != tt.ok {
+                t.Errorf("addProposal(round %d) error = %v, want ok = %v", tt.round, err, tt.ok)
+            }
+        })
+    }
+}
+
+func TestAddVoteRoundWindow(t *testing.T) {
+    engine, publicKeys, privateKeys := newTestBFT(t)
+    tests := []struct {
+        name  string
+        round int64
+        ok    bool
+    }{
+        {"current round", 0, true},
+        {"within the window", maxRoundsAhead, true},
+        {"past the window", maxRoundsAhead + 1, false},
+        {"largest round", math.MaxInt64, false},
+        {"negative round", -1, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            vote := &Vote{Type: MsgPrevote, Height: 1, Round: tt.round, Validator: publicKeys[1]}
+            signature, err := blockchain.Sign(privateKeys[1], voteSignBytes(vote.Type, vote.Height, vote.Round, vote.BlockHash))
+            if err != nil {
+                t.Fatal(err)
+            }
+            vote.Signature = signature
+            if err := engine.addVote(MsgPrevote, vote); (err == nil) != tt.ok {
+                t.Errorf("addVote(round %d) error = %v, want ok = %v", tt.round, err, tt.ok)
+            }
+        })
+    }
+    if len(engine.prevotes) != 2 {
+        t.Errorf("prevotes stored for %d rounds, want 2", len(engine.prevotes))
+    }
+}
+
+func TestVerifyBlockRounds(t *testing.T) {
+    engine, publicKeys, privateKeys := newTestBFT(t)
+    prev, err := engine.chain.GetLatestBlock()
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        name        string
+        round       int64
+        commitRound int64
+    }{
+        {"largest round", math.MaxInt64, math.MaxInt64},
+        {"round after commit", 5, 4},
+        {"negative round", math.MinInt64, 0},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            block, err := engine.chain.ProposeBlock(publicKeys[0], tt.round, time.Now().Unix())
+            if err != nil {
+                t.Fatal(err)
+            }
+            if err := block.Sign(privateKeys[0]); err != nil {
+                t.Fatal(err)
+            }
+            block.Commit = &blockchain.CommitCertificate{Round: tt.commitRound}
+            if err := engine.VerifyBlock(prev, block); err == nil {
+                t.Error("block was accepted")
+            }
+        })
+    }
+}
@@ -0,0 +1,59 @@
+// Package consensus decides which validator may produce each block and
+// checks blocks received from other nodes before they are appended.
+package consensus
+
+import (
+    "time"
+    "virtual_ethiopia_dap/internal/blockchain"
+)
+
+// Engine drives block production for a node
+type Engine interface {
+    // Start begins producing blocks when this node is the expected proposer
+    Start() error
+    // Stop halts block production
+    Stop()
+    // HandleBlock validates and appends a block received from a peer
+    HandleBlock(block *blockchain.Block) error
+    // SetBroadcaster registers the function used to announce new blocks
+    SetBroadcaster(broadcast func(block *blockchain.Block))
+}
+
+// Config holds the settings shared by the consensus engines
+type Config struct {
+    // PrivateKey is this node's validator key; nodes without one only follow the chain
+    PrivateKey string
+    // BlockInterval is the minimum time between a block and its successor
+    BlockInterval time.Duration
+    // SlotTimeout is how long a proposer has before the next validator takes over
+    SlotTimeout time.Duration
+}
+
+// DefaultConfig returns the block timings used when none are configured
+func DefaultConfig() Config {
+    return Config{
+        BlockInterval: 5 * time.Second,
+        SlotTimeout:   10 * time.Second,
+    }
+}
+
+// proposerAt picks the proposer for a height and round: validators take turns
+// by height, and every failed round hands the slot to the next validator.
+// Height and round are reduced separately so that their sum cannot overflow.
+func proposerAt(validators []string, height, round int64) string {
+    n := int64(len(validators))
+    index := (height%n + round%n) % n
+    if index < 0 {
+        index += n
+    }
+    return validators[index]
+}
+
+func containsValidator(validators []string, publicKey string) bool {
+    for _, validator := range validators {
+        if validator == publicKey {
+            return true
+        }
+    }
+    return false
+}
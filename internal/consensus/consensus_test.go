@@ -0,0 +1,51 @@
+package consensus
+
+import (
+    "math"
+    "testing"
+    "time"
+    "virtual_ethiopia_dap/internal/blockchain"
+)
+
+func TestProposerAt(t *testing.T) {
+    validators := []string{"a", "b", "c"}
+    tests := []struct {
+        name   string
+        height int64
+        round  int64
+        want   string
+    }{
+        {"first height", 1, 0, "b"},
+        {"next round", 1, 1, "c"},
+        {"wraps around", 2, 1, "a"},
+        {"largest round", 1, math.MaxInt64, "c"},
+        {"largest height and round", math.MaxInt64, math.MaxInt64, "c"},
+        {"negative round", 1, -2, "c"},
+        {"smallest round", 0, math.MinInt64, "b"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := proposerAt(validators, tt.height, tt.round); got != tt.want {
+                t.Errorf("proposerAt(%d, %d) = %s, want %s", tt.height, tt.round, got, tt.want)
+            }
+        })
+    }
+}
+
+// testValidators returns the key pairs of n validators and a genesis naming
+// them, starting at now
+func testValidators(t *testing.T, n int) ([]string, []string, *blockchain.Genesis) {
+    t.Helper()
+    genesis := &blockchain.Genesis{Timestamp: time.Now().Unix()}
+    var publicKeys, privateKeys []string
+    for i := 0; i < n; i++ {
+        publicKey, privateKey, err := blockchain.GenerateKeyPair()
+        if err != nil {
+            t.Fatal(err)
+        }
+        publicKeys = append(publicKeys, publicKey)
+        privateKeys = append(privateKeys, privateKey)
+    }
+    genesis.Validators = publicKeys
+    return publicKeys, privateKeys, genesis
+}
@@ -0,0 +1,318 @@
+package consensus
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "math/rand"
+    "sync"
+    "testing"
+    "time"
+    "virtual_ethiopia_dap/internal/blockchain"
+)
+
+// FaultMode describes how a validator in the harness misbehaves
+type FaultMode int
+
+const (
+    // FaultNone is an honest validator
+    FaultNone FaultMode = iota
+    // FaultSilent never delivers any message to its peers
+    FaultSilent
+    // FaultEquivocate sends every peer a different, validly signed vote for
+    // a made-up block instead of its real votes
+    FaultEquivocate
+)
+
+// HarnessConfig configures an in-process BFT network
+type HarnessConfig struct {
+    // Validators is the number of validators in the network
+    Validators int
+    // DropRate is the probability that any single message delivery is lost
+    DropRate float64
+    // MaxDelay bounds the random delivery delay of each message
+    MaxDelay time.Duration
+    // Faulty maps validator indexes to their misbehaviour
+    Faulty map[int]FaultMode
+    // Seed makes message loss reproducible
+    Seed int64
+    // Timing is used by every engine; PrivateKey is ignored
+    Timing Config
+}
+
+// HarnessNode is one validator of the harness
+type HarnessNode struct {
+    Index  int
+    Chain  *blockchain.Chain
+    Engine *BFT
+    Fault  FaultMode
+
+    privateKey string
+}
+
+// Harness runs several BFT validators in one process over a simulated
+// network that can lose and delay messages, so that safety and liveness can
+// be exercised without real sockets.
+type Harness struct {
+    Nodes  []*HarnessNode
+    config HarnessConfig
+    rng    *rand.Rand
+    mu     sync.Mutex
+}
+
+// NewHarness creates the validators, a shared genesis and their chains
+func NewHarness(config HarnessConfig) (*Harness, error) {
+    if config.Validators < 1 {
+        return nil, fmt.Errorf("harness needs at least one validator")
+    }
+
+    genesis := &blockchain.Genesis{Timestamp: time.Now().Unix()}
+    keys := make([]string, config.Validators)
+    for i := range keys {
+        publicKey, privateKey, err := blockchain.GenerateKeyPair()
+        if err != nil {
+            return nil, err
+        }
+        keys[i] = privateKey
+        genesis.Validators = append(genesis.Validators, publicKey)
+    }
+
+    harness := &Harness{
+        config: config,
+        rng:    rand.New(rand.NewSource(config.Seed)),
+    }
+    for i, privateKey := range keys {
+        timing := config.Timing
+        timing.PrivateKey = privateKey
+        chain := blockchain.NewChain(genesis)
+        engine, err := NewBFT(chain, timing)
+        if err != nil {
+            return nil, err
+        }
+        node := &HarnessNode{
+            Index:      i,
+            Chain:      chain,
+            Engine:     engine,
+            Fault:      config.Faulty[i],
+            privateKey: privateKey,
+        }
+        engine.SetTransport(&harnessTransport{harness: harness, from: node})
+        harness.Nodes = append(harness.Nodes, node)
+    }
+    return harness, nil
+}
+
+// Start starts every engine
+func (h *Harness) Start() error {
+    for _, node := range h.Nodes {
+        if err := node.Engine.Start(); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// Stop stops every engine
+func (h *Harness) Stop() {
+    for _, node := range h.Nodes {
+        node.Engine.Stop()
+    }
+}
+
+// Submit adds a transaction to the pool of every node, standing in for
+// transaction gossip
+func (h *Harness) Submit(tx *blockchain.Transaction) error {
+    for _, node := range h.Nodes {
+        if err := node.Chain.SubmitTransaction(tx); err != nil && err.Error() != "transaction already pending" {
+            return fmt.Errorf("node %d: %v", node.Index, err)
+        }
+    }
+    return nil
+}
+
+// Honest returns the nodes without a fault
+func (h *Harness) Honest() []*HarnessNode {
+    honest := make([]*HarnessNode, 0, len(h.Nodes))
+    for _, node := range h.Nodes {
+        if node.Fault == FaultNone {
+            honest = append(honest, node)
+        }
+    }
+    return honest
+}
+
+// WaitForHeight blocks until every honest node has committed height
+func (h *Harness) WaitForHeight(height int64, timeout time.Duration) error {
+    deadline := time.Now().Add(timeout)
+    for {
+        lowest := int64(-1)
+        for _, node := range h.Honest() {
+            if current := node.Chain.Height(); lowest == -1 || current < lowest {
+                lowest = current
+            }
+        }
+        if lowest >= height {
+            return nil
+        }
+        if time.Now().After(deadline) {
+            return fmt.Errorf("honest nodes reached height %d, wanted %d", lowest, height)
+        }
+        time.Sleep(50 * time.Millisecond)
+    }
+}
+
+// CheckAgreement verifies that honest nodes committed identical blocks at
+// every common height and that each block carries a valid commit certificate
+func (h *Harness) CheckAgreement() error {
+    honest := h.Honest()
+    if len(honest) == 0 {
+        return nil
+    }
+
+    reference := honest[0]
+    for height := int64(1); height <= reference.Chain.Height(); height++ {
+        block, _ := reference.Chain.GetBlock(height)
+        if err := reference.Engine.verifyCommit(block); err != nil {
+            return fmt.Errorf("block %d on node %d: %v", height, reference.Index, err)
+        }
+        for _, node := range honest[1:] {
+            other, ok := node.Chain.GetBlock(height)
+            if ok && !bytes.Equal(other.Hash, block.Hash) {
+                return fmt.Errorf("nodes %d and %d committed different blocks at height %d", reference.Index, node.Index, height)
+            }
+        }
+    }
+    return nil
+}
+
+// harnessTransport delivers one node's messages to all other nodes
+type harnessTransport struct {
+    harness *Harness
+    from    *HarnessNode
+}
+
+func (t *harnessTransport) Broadcast(msg *Message) {
+    h := t.harness
+    if t.from.Fault == FaultSilent {
+        return
+    }
+
+    for _, to := range h.Nodes {
+        if to == t.from {
+            continue
+        }
+
+        outgoing := msg
+        if t.from.Fault == FaultEquivocate && msg.Vote != nil {
+            outgoing = t.forgeVote(msg.Vote, to.Index)
+        }
+
+        h.mu.Lock()
+        dropped := h.rng.Float64() < h.config.DropRate
+        var delay time.Duration
+        if h.config.MaxDelay > 0 {
+            delay = time.Duration(h.rng.Int63n(int64(h.config.MaxDelay)))
+        }
+        h.mu.Unlock()
+        if dropped {
+            continue
+        }
+
+        // Round-trip through JSON so nodes never share pointers, as on a real network
+        raw, err := json.Marshal(outgoing)
+        if err != nil {
+            continue
+        }
+        engine := to.Engine
+        time.AfterFunc(delay, func() {
+            var delivered Message
+            if json.Unmarshal(raw, &delivered) == nil {
+                engine.HandleMessage(&delivered)
+            }
+        })
+    }
+}
+
+// forgeVote replaces a vote with a signed vote for a block that only this
+// recipient is told about
+func (t *harnessTransport) forgeVote(vote *Vote, recipient int) *Message {
+    fake := sha256.Sum256([]byte(fmt.Sprintf("%d|%d|%d", vote.Height, vote.Round, recipient)))
+    forged := *vote
+    forged.BlockHash = hex.EncodeToString(fake[:])
+    signature, err := blockchain.Sign(t.from.privateKey, voteSignBytes(forged.Type, forged.Height, forged.Round, forged.BlockHash))
+    if err == nil {
+        forged.Signature = signature
+    }
+    return &Message{Type: forged.Type, Vote: &forged}
+}
+
+// registration returns a signed registration of a new citizen, used to give
+// the validators something to agree on
+func registration(t *testing.T, name string) *blockchain.Transaction {
+    t.Helper()
+    publicKey, privateKey, err := blockchain.GenerateKeyPair()
+    if err != nil {
+        t.Fatal(err)
+    }
+    tx, err := blockchain.NewCitizenRegistrationTx(name, "1990-01-01", "", publicKey, time.Now().Unix())
+    if err != nil {
+        t.Fatal(err)
+    }
+    tx.SetNonce(publicKey[:16])
+    if err := tx.Sign(privateKey); err != nil {
+        t.Fatal(err)
+    }
+    return tx
+}
+
+func TestHarness(t *testing.T) {
+    const heights = 3
+    tests := []struct {
+        name     string
+        dropRate float64
+        maxDelay time.Duration
+        faulty   map[int]FaultMode
+    }{
+        {"honest", 0, 0, nil},
+        {"lossy network", 0.2, 20 * time.Millisecond, nil},
+        {"silent validator", 0, 0, map[int]FaultMode{1: FaultSilent}},
+        {"equivocating validator", 0, 0, map[int]FaultMode{2: FaultEquivocate}},
+        {"equivocating validator on a lossy network", 0.1, 20 * time.Millisecond, map[int]FaultMode{3: FaultEquivocate}},
+    }
+    for i, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            t.Parallel()
+            harness, err := NewHarness(HarnessConfig{
+                Validators: 4,
+                DropRate:   tt.dropRate,
+                MaxDelay:   tt.maxDelay,
+                Faulty:     tt.faulty,
+                Seed:       int64(i + 1),
+                Timing:     Config{BlockInterval: 50 * time.Millisecond, SlotTimeout: 400 * time.Millisecond},
+            })
+            if err != nil {
+                t.Fatal(err)
+            }
+            if err := harness.Start(); err != nil {
+                t.Fatal(err)
+            }
+            defer harness.Stop()
+
+            for height := int64(1); height <= heights; height++ {
+                if err := harness.Submit(registration(t, fmt.Sprintf("Citizen %d", height))); err != nil {
+                    t.Fatal(err)
+                }
+                // Liveness: the honest nodes keep committing despite the faults
+                if err := harness.WaitForHeight(height, 30*time.Second); err != nil {
+                    t.Fatal(err)
+                }
+            }
+            // Safety: no two honest nodes committed different blocks at a height
+            if err := harness.CheckAgreement(); err != nil {
+                t.Fatal(err)
+            }
+        })
+    }
+}
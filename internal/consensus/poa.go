@@ -0,0 +1,197 @@
+package consensus
+
+import (
+    "fmt"
+    "log"
+    "sync"
+    "time"
+    "virtual_ethiopia_dap/internal/blockchain"
+)
+
+// maxClockDrift bounds how far in the future a block timestamp may be
+const maxClockDrift = 15 * time.Second
+
+// PoA is a Proof-of-Authority engine. Validators from the genesis
+// configuration take turns proposing blocks: the proposer at a height is
+// chosen round-robin, and each missed slot (no block within SlotTimeout)
+// moves the height to the next round and therefore the next validator.
+type PoA struct {
+    chain      *blockchain.Chain
+    config     Config
+    validators []string
+    publicKey  string
+    broadcast  func(block *blockchain.Block)
+    stop       chan struct{}
+    wg         sync.WaitGroup
+    mu         sync.Mutex
+}
+
+// NewPoA creates a Proof-of-Authority engine and installs it as the chain's
+// block verifier
+func NewPoA(chain *blockchain.Chain, config Config) (*PoA, error) {
+    engine := &PoA{
+        chain:      chain,
+        config:     config,
+        validators: chain.Genesis().Validators,
+    }
+
+    if config.BlockInterval < time.Second || config.SlotTimeout < time.Second {
+        return nil, fmt.Errorf("block interval and slot timeout must be at least one second")
+    }
+
+    if config.PrivateKey != "" {
+        publicKey, err := blockchain.PublicKeyFromPrivate(config.PrivateKey)
+        if err != nil {
+            return nil, err
+        }
+        if !containsValidator(engine.validators, publicKey) {
+            return nil, fmt.Errorf("key %s is not in the validator set", publicKey)
+        }
+        engine.publicKey = publicKey
+    }
+
+    chain.SetBlockVerifier(engine)
+    return engine, nil
+}
+
+// Proposer returns the validator expected to propose at height and round
+func (p *PoA) Proposer(height, round int64) string {
+    return proposerAt(p.validators, height, round)
+}
+
+// SetBroadcaster registers the function used to announce new blocks
+func (p *PoA) SetBroadcaster(broadcast func(block *blockchain.Block)) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.broadcast = broadcast
+}
+
+// Start begins the proposer loop; non-validators only verify blocks
+func (p *PoA) Start() error {
+    if p.publicKey == "" {
+        log.Println("No validator key configured, following the chain only")
+        return nil
+    }
+
+    p.stop = make(chan struct{})
+    p.wg.Add(1)
+    go p.run()
+    log.Printf("PoA validator %s started", p.publicKey)
+    return nil
+}
+
+// Stop halts the proposer loop
+func (p *PoA) Stop() {
+    if p.stop == nil {
+        return
+    }
+    close(p.stop)
+    p.wg.Wait()
+    p.stop = nil
+}
+
+// HandleBlock appends a block from a peer; AppendBlock calls back into
+// VerifyBlock to enforce the proposer rules
+func (p *PoA) HandleBlock(block *blockchain.Block) error {
+    return p.chain.AppendBlock(block)
+}
+
+// VerifyBlock enforces the PoA rules: the block must come from the expected
+// proposer for its round, carry that proposer's signature, and not be
+// produced before its slot opened. The round is capped by the time elapsed
+// since prev before it is used to pick the proposer.
+func (p *PoA) VerifyBlock(prev, block *blockchain.Block) error {
+    if block.Round < 0 {
+        return fmt.Errorf("negative round")
+    }
+    if block.Timestamp > time.Now().Add(maxClockDrift).Unix() {
+        return fmt.Errorf("block timestamp is too far in the future")
+    }
+    if block.Timestamp < prev.Timestamp {
+        return fmt.Errorf("block timestamp is before its predecessor")
+    }
+    if block.Round > p.currentRound(prev, block.Timestamp) {
+        return fmt.Errorf("block produced before round %d opened", block.Round)
+    }
+    expected := p.Proposer(block.Index, block.Round)
+    if block.Proposer != expected {
+        return fmt.Errorf("proposer %s is not the expected proposer %s for round %d", block.Proposer, expected, block.Round)
+    }
+    if !block.VerifySignature() {
+        return fmt.Errorf("invalid proposer signature")
+    }
+    return nil
+}
+
+// currentRound returns the round that is open at now on top of prev, or -1
+// if the block interval has not elapsed yet
+func (p *PoA) currentRound(prev *blockchain.Block, now int64) int64 {
+    interval := int64(p.config.BlockInterval / time.Second)
+    timeout := int64(p.config.SlotTimeout / time.Second)
+    elapsed := now - prev.Timestamp - interval
+    if elapsed < 0 {
+        return -1
+    }
+    return elapsed / timeout
+}
+
+func (p *PoA) run() {
+    defer p.wg.Done()
+
+    ticker := time.NewTicker(500 * time.Millisecond)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-p.stop:
+            return
+        case <-ticker.C:
+            if err := p.tryPropose(); err != nil {
+                log.Printf("Failed to propose block: %v", err)
+            }
+        }
+    }
+}
+
+// tryPropose produces a block if this node owns the currently open slot.
+// Empty blocks are only produced to carry an election phase transition;
+// otherwise the slot simply passes.
+func (p *PoA) tryPropose() error {
+    prev, err := p.chain.GetLatestBlock()
+    if err != nil {
+        return err
+    }
+
+    now := time.Now().Unix()
+    round := p.currentRound(prev, now)
+    if round < 0 || p.Proposer(prev.Index+1, round) != p.publicKey {
+        return nil
+    }
+    due := p.chain.TransitionDue(now)
+    if p.chain.PendingCount() == 0 && !due {
+        return nil
+    }
+
+    block, err := p.chain.ProposeBlock(p.publicKey, round, now)
+    if err != nil {
+        return err
+    }
+    if len(block.Transactions) == 0 && !due {
+        return nil
+    }
+    if err := block.Sign(p.config.PrivateKey); err != nil {
+        return err
+    }
+    if err := p.chain.AppendBlock(block); err != nil {
+        return err
+    }
+    log.Printf("Produced block %d (round %d) with %d transactions", block.Index, round, len(block.Transactions))
+
+    p.mu.Lock()
+    broadcast := p.broadcast
+    p.mu.Unlock()
+    if broadcast != nil {
+        broadcast(block)
+    }
+    return nil
+}
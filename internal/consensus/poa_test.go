@@ -0,0 +1,58 @@
+package consensus
+
+import (
+    "math"
+    "testing"
+    "time"
+    "virtual_ethiopia_dap/internal/blockchain"
+)
+
+func TestPoAVerifyBlockRounds(t *testing.T) {
+    publicKeys, privateKeys, genesis := testValidators(t, 3)
+    genesis.Timestamp = time.Now().Unix() - 60
+    chain := blockchain.NewChain(genesis)
+    engine, err := NewPoA(chain, Config{BlockInterval: 5 * time.Second, SlotTimeout: 10 * time.Second})
+    if err != nil {
+        t.Fatal(err)
+    }
+    prev, err := chain.GetLatestBlock()
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        name    string
+        round   int64
+        elapsed int64 // seconds after prev
+        ok      bool
+    }{
+        {"first slot", 0, 5, true},
+        {"round opened", 2, 25, true},
+        {"before the block interval", 0, 4, false},
+        {"before the round opened", 2, 24, false},
+        {"largest round", math.MaxInt64, 30, false},
+        {"negative round", -1, 30, false},
+        {"before its predecessor", 0, math.MinInt64 / 2, false},
+        {"too far in the future", 0, 3600, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            index := 0
+            for i, key := range publicKeys {
+                if key == engine.Proposer(1, tt.round) {
+                    index = i
+                }
+            }
+            block, err := chain.ProposeBlock(publicKeys[index], tt.round, prev.Timestamp+tt.elapsed)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if err := block.Sign(privateKeys[index]); err != nil {
+                t.Fatal(err)
+            }
+            if err := engine.VerifyBlock(prev, block); (err == nil) != tt.ok {
+                t.Errorf("VerifyBlock error = %v, want ok = %v", err, tt.ok)
+            }
+        })
+    }
+}
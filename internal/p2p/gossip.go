@@ -0,0 +1,171 @@
+package p2p
+
+import (
+    "encoding/json"
+    "log"
+    "sync"
+    "time"
+    "virtual_ethiopia_dap/internal/blockchain"
+)
+
+// MsgTransactions carries transactions for the receiver's pool
+const MsgTransactions = "transactions"
+
+const (
+    // seenTTL is how long a transaction ID is remembered after it was seen
+    seenTTL = 10 * time.Minute
+    // maxSeen bounds the seen cache; the oldest entries are dropped first
+    maxSeen = 100000
+    // maxTransactionsPerMessage caps the pool sent to a newly connected peer
+    maxTransactionsPerMessage = 500
+)
+
+// Transactions is a batch of gossiped transactions
+type Transactions struct {
+    Transactions []*blockchain.Transaction `json:"transactions"`
+}
+
+// TxGossip propagates pooled transactions across the network. Every
+// transaction accepted into the local pool is sent to all peers; a peer that
+// accepts it relays it onwards. Each node remembers the transaction IDs it has
+// already handled, so a transaction is forwarded at most once per node and the
+// flood stops once every node has seen it. A received ID is only remembered
+// once its transaction is accepted into the pool, which checks the ID and
+// the signature, so a peer cannot suppress a transaction by sending a forgery
+// under its ID. A newly connected peer is sent the current pool so that it
+// can propose blocks with the same transactions.
+type TxGossip struct {
+    network *Network
+    chain   *blockchain.Chain
+
+    seen      map[string]time.Time
+    order     []string        // seen IDs, oldest first
+    receiving map[string]bool // IDs of received transactions being submitted
+    mu        sync.Mutex
+}
+
+// NewTxGossip creates the gossip for chain's pool, registers its message
+// handler and subscribes to locally submitted transactions
+func NewTxGossip(network *Network, chain *blockchain.Chain) *TxGossip {
+    g := &TxGossip{
+        network:   network,
+        chain:     chain,
+        seen:      make(map[string]time.Time),
+        order:     make([]string, 0),
+        receiving: make(map[string]bool),
+    }
+
+    network.OnConnect(g.sendPool)
+    network.Handle(MsgTransactions, g.handleTransactions)
+    chain.SetTransactionListener(g.Announce)
+    return g
+}
+
+// Announce sends a newly pooled transaction to every peer unless it has been
+// gossiped already. Transactions received from a peer are relayed by
+// handleTransactions instead, without echoing them to that peer.
+func (g *TxGossip) Announce(tx *blockchain.Transaction) {
+    if g.isReceiving(tx.ID) || !g.markSeen(tx.ID) {
+        return
+    }
+    message := Transactions{Transactions: []*blockchain.Transaction{tx}}
+    if err := g.network.Broadcast(MsgTransactions, message); err != nil {
+        log.Printf("Failed to broadcast transaction %s: %v", tx.ID, err)
+    }
+}
+
+// markSeen records id and reports whether it was new
+func (g *TxGossip) markSeen(id string) bool {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+
+    now := time.Now()
+    for len(g.order) > 0 {
+        oldest := g.order[0]
+        if len(g.order) < maxSeen && now.Sub(g.seen[oldest]) < seenTTL {
+            break
+        }
+        delete(g.seen, oldest)
+        g.order = g.order[1:]
+    }
+
+    if _, seen := g.seen[id]; seen {
+        return false
+    }
+    g.seen[id] = now
+    g.order = append(g.order, id)
+    return true
+}
+
+// isSeen reports whether id has been handled already
+func (g *TxGossip) isSeen(id string) bool {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+    _, seen := g.seen[id]
+    return seen
+}
+
+// setReceiving marks id as a received transaction being submitted, or clears it
+func (g *TxGossip) setReceiving(id string, receiving bool) {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+    if receiving {
+        g.receiving[id] = true
+    } else {
+        delete(g.receiving, id)
+    }
+}
+
+func (g *TxGossip) isReceiving(id string) bool {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+    return g.receiving[id]
+}
+
+func (g *TxGossip) sendPool(peer *Peer) {
+    pending := g.chain.PendingTransactions()
+    if len(pending) == 0 {
+        return
+    }
+    if len(pending) > maxTransactionsPerMessage {
+        pending = pending[:maxTransactionsPerMessage]
+    }
+    if err := peer.Send(MsgTransactions, Transactions{Transactions: pending}); err != nil {
+        log.Printf("Failed to send pool to %s: %v", peer.ID(), err)
+    }
+}
+
+func (g *TxGossip) handleTransactions(peer *Peer, data json.RawMessage) {
+    var message Transactions
+    if err := json.Unmarshal(data, &message); err != nil {
+        log.Printf("Invalid transactions from %s: %v", peer.ID(), err)
+        return
+    }
+
+    relay := make([]*blockchain.Transaction, 0, len(message.Transactions))
+    for _, tx := range message.Transactions {
+        if tx == nil || g.isSeen(tx.ID) {
+            continue
+        }
+        // Announce is called back for accepted transactions but skips those
+        // being received, so relaying happens here without echoing to peer.
+        // Rejected transactions stay unseen, as their ID proves nothing.
+        g.setReceiving(tx.ID, true)
+        err := g.chain.SubmitTransaction(tx)
+        g.setReceiving(tx.ID, false)
+        if err != nil {
+            log.Printf("Rejected transaction %s from %s: %v", tx.ID, peer.ID(), err)
+            continue
+        }
+        if g.markSeen(tx.ID) {
+            relay = append(relay, tx)
+        }
+    }
+
+    if len(relay) == 0 {
+        return
+    }
+    if err := g.network.BroadcastExcept(MsgTransactions, Transactions{Transactions: relay}, peer); err != nil {
+        log.Printf("Failed to relay transactions: %v", err)
+    }
+}
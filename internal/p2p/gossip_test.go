@@ -0,0 +1,100 @@
+package p2p
+
+import (
+    "encoding/json"
+    "testing"
+    "time"
+    "virtual_ethiopia_dap/internal/blockchain"
+)
+
+// newTestGossip returns the gossip of a node connected to two peers that
+// never drain their send queues
+func newTestGossip(t *testing.T) (*TxGossip, *Peer, *Peer) {
+    t.Helper()
+    network := NewNetwork()
+    sender, other := NewPeer("10.0.0.1", "3000"), NewPeer("10.0.0.2", "3000")
+    network.peers[sender.ID()] = sender
+    network.peers[other.ID()] = other
+    chain := blockchain.NewChain(&blockchain.Genesis{Timestamp: time.Now().Unix()})
+    return NewTxGossip(network, chain), sender, other
+}
+
+// testRegistration returns a signed registration of a new citizen
+func testRegistration(t *testing.T, name string) *blockchain.Transaction {
+    t.Helper()
+    publicKey, privateKey, err := blockchain.GenerateKeyPair()
+    if err != nil {
+        t.Fatal(err)
+    }
+    tx, err := blockchain.NewCitizenRegistrationTx(name, "1990-01-01", "", publicKey, time.Now().Unix())
+    if err != nil {
+        t.Fatal(err)
+    }
+    tx.SetNonce(publicKey[:16])
+    if err := tx.Sign(privateKey); err != nil {
+        t.Fatal(err)
+    }
+    return tx
+}
+
+// deliver hands the node a transactions message from peer
+func deliver(t *testing.T, g *TxGossip, peer *Peer, txs ...*blockchain.Transaction) {
+    t.Helper()
+    raw, err := json.Marshal(Transactions{Transactions: txs})
+    if err != nil {
+        t.Fatal(err)
+    }
+    g.handleTransactions(peer, raw)
+}
+
+func TestForgeryDoesNotSuppressTransaction(t *testing.T) {
+    tests := []struct {
+        name  string
+        forge func(tx *blockchain.Transaction)
+    }{
+        {"altered contents", func(tx *blockchain.Transaction) { tx.Data["name"] = "Someone Else" }},
+        {"altered signature", func(tx *blockchain.Transaction) { tx.Signature = tx.Signature[2:] + "00" }},
+        {"unsigned", func(tx *blockchain.Transaction) { tx.Signature = "" }},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            g, sender, other := newTestGossip(t)
+            tx := testRegistration(t, "Tirunesh Dibaba")
+
+            forged := *tx
+            forged.Data = make(map[string]interface{})
+            for key, value := range tx.Data {
+                forged.Data[key] = value
+            }
+            tt.forge(&forged)
+            deliver(t, g, sender, &forged)
+            if len(other.outbox) != 0 {
+                t.Fatal("forged transaction was relayed")
+            }
+
+            deliver(t, g, sender, tx)
+            if g.chain.PendingCount() != 1 {
+                t.Fatal("transaction was not pooled after its forgery")
+            }
+            if len(other.outbox) != 1 || len(sender.outbox) != 0 {
+                t.Fatalf("relayed to %d other peers and echoed %d times, want 1 and 0", len(other.outbox), len(sender.outbox))
+            }
+
+            // Once accepted, the transaction is not relayed again
+            deliver(t, g, sender, tx)
+            if len(other.outbox) != 1 {
+                t.Fatal("accepted transaction was relayed twice")
+            }
+        })
+    }
+}
+
+func TestAnnounceLocalTransaction(t *testing.T) {
+    g, sender, other := newTestGossip(t)
+    if err := g.chain.SubmitTransaction(testRegistration(t, "Kenenisa Bekele")); err != nil {
+        t.Fatal(err)
+    }
+    if len(sender.outbox) != 1 || len(other.outbox) != 1 {
+        t.Fatalf("announced to %d and %d peers, want both", len(sender.outbox), len(other.outbox))
+    }
+}
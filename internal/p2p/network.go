@@ -3,26 +3,52 @@ package p2p
 import (
     "encoding/json"
     "fmt"
+    "log"
     "net"
     "sync"
+    "time"
 )
 
+// redialInterval is how long to wait before dialing a configured peer again
+const redialInterval = 5 * time.Second
+
+// Handler processes one message received from a peer
+type Handler func(peer *Peer, data json.RawMessage)
+
 // Network represents the P2P network functionality
 type Network struct {
-    peers     map[string]net.Conn
+    peers     map[string]*Peer
+    handlers  map[string]Handler
+    onConnect []func(peer *Peer)
     mu        sync.RWMutex
     listener  net.Listener
     isRunning bool
+    stop      chan struct{}
 }
 
 // NewNetwork creates a new P2P network instance
 func NewNetwork() *Network {
     return &Network{
-        peers:     make(map[string]net.Conn),
+        peers:     make(map[string]*Peer),
+        handlers:  make(map[string]Handler),
         isRunning: false,
     }
 }
 
+// Handle registers a handler for messages of the given type
+func (n *Network) Handle(messageType string, handler Handler) {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    n.handlers[messageType] = handler
+}
+
+// OnConnect registers a callback run for every new peer connection
+func (n *Network) OnConnect(callback func(peer *Peer)) {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    n.onConnect = append(n.onConnect, callback)
+}
+
 // Start initializes the P2P network
 func (n *Network) Start(port string) error {
     listener, err := net.Listen("tcp", ":"+port)
@@ -30,8 +56,11 @@ func (n *Network) Start(port string) error {
         return fmt.Errorf("failed to start P2P network: %v", err)
     }
 
+    n.mu.Lock()
     n.listener = listener
     n.isRunning = true
+    n.stop = make(chan struct{})
+    n.mu.Unlock()
 
     go n.listen()
     return nil
@@ -46,54 +75,116 @@ func (n *Network) Stop() error {
         return nil
     }
 
+    n.isRunning = false
+    close(n.stop)
     if err := n.listener.Close(); err != nil {
         return fmt.Errorf("failed to close listener: %v", err)
     }
 
-    for _, conn := range n.peers {
-        conn.Close()
+    for _, peer := range n.peers {
+        peer.Disconnect()
     }
 
-    n.peers = make(map[string]net.Conn)
-    n.isRunning = false
+    n.peers = make(map[string]*Peer)
     return nil
 }
 
 // Connect establishes a connection with a peer
-func (n *Network) Connect(address string) error {
-    conn, err := net.Dial("tcp", address)
+func (n *Network) Connect(address string) (*Peer, error) {
+    host, port, err := net.SplitHostPort(address)
     if err != nil {
-        return fmt.Errorf("failed to connect to peer: %v", err)
+        return nil, fmt.Errorf("invalid peer address %q: %v", address, err)
     }
 
-    n.mu.Lock()
-    n.peers[address] = conn
-    n.mu.Unlock()
+    peer := NewPeer(host, port)
+    if err := peer.Connect(); err != nil {
+        return nil, fmt.Errorf("failed to connect to peer: %v", err)
+    }
 
-    go n.handlePeer(conn)
-    return nil
+    n.addPeer(peer)
+    return peer, nil
+}
+
+// Maintain keeps a connection to address open, redialing whenever it drops
+// or the peer is not up yet
+func (n *Network) Maintain(address string) {
+    go func() {
+        for {
+            peer, err := n.Connect(address)
+            if err == nil {
+                select {
+                case <-peer.done:
+                case <-n.stop:
+                    return
+                }
+            }
+
+            select {
+            case <-time.After(redialInterval):
+            case <-n.stop:
+                return
+            }
+        }
+    }()
+}
+
+// Peers returns the currently connected peers
+func (n *Network) Peers() []*Peer {
+    n.mu.RLock()
+    defer n.mu.RUnlock()
+
+    peers := make([]*Peer, 0, len(n.peers))
+    for _, peer := range n.peers {
+        peers = append(peers, peer)
+    }
+    return peers
 }
 
 // listen handles incoming connections
 func (n *Network) listen() {
-    for n.isRunning {
+    for {
         conn, err := n.listener.Accept()
         if err != nil {
-            if n.isRunning {
-                fmt.Printf("Failed to accept connection: %v\n", err)
+            n.mu.RLock()
+            running := n.isRunning
+            n.mu.RUnlock()
+            if !running {
+                return
             }
+            log.Printf("Failed to accept connection: %v", err)
             continue
         }
 
-        go n.handlePeer(conn)
+        n.addPeer(newPeerFromConn(conn))
     }
 }
 
-// handlePeer processes messages from a peer
-func (n *Network) handlePeer(conn net.Conn) {
-    defer conn.Close()
+// addPeer registers a connected peer and starts serving it
+func (n *Network) addPeer(peer *Peer) {
+    n.mu.Lock()
+    n.peers[peer.ID()] = peer
+    callbacks := n.onConnect
+    n.mu.Unlock()
 
-    decoder := json.NewDecoder(conn)
+    go peer.writeLoop()
+    go n.handlePeer(peer)
+    for _, callback := range callbacks {
+        callback(peer)
+    }
+}
+
+// handlePeer processes messages from a peer until the connection closes
+func (n *Network) handlePeer(peer *Peer) {
+    defer func() {
+        peer.Disconnect()
+        n.mu.Lock()
+        if n.peers[peer.ID()] == peer {
+            delete(n.peers, peer.ID())
+        }
+        n.mu.Unlock()
+    }()
+
+    decoder := json.NewDecoder(peer.Conn)
     for {
         var message struct {
             Type string          `json:"type"`
@@ -104,49 +195,39 @@ func (n *Network) handlePeer(conn net.Conn) {
             return
         }
 
-        // Handle different message types here
-        switch message.Type {
-        case "block":
-            n.handleBlockMessage(message.Data)
-        case "transaction":
-            n.handleTransactionMessage(message.Data)
+        n.mu.RLock()
+        handler, registered := n.handlers[message.Type]
+        n.mu.RUnlock()
+        if registered {
+            handler(peer, message.Data)
         }
     }
 }
 
-func (n *Network) handleBlockMessage(data json.RawMessage) {
-    // Implement block handling
-    fmt.Println("Received block message")
+// Broadcast sends a message to all peers
+func (n *Network) Broadcast(messageType string, data interface{}) error {
+    return n.broadcast(messageType, data, nil)
 }
 
-func (n *Network) handleTransactionMessage(data json.RawMessage) {
-    // Implement transaction handling
-    fmt.Println("Received transaction message")
+// BroadcastExcept sends a message to all peers other than except, typically
+// the peer the message came from
+func (n *Network) BroadcastExcept(messageType string, data interface{}, except *Peer) error {
+    return n.broadcast(messageType, data, except)
 }
 
-// Broadcast sends a message to all peers
-func (n *Network) Broadcast(messageType string, data interface{}) error {
-    message := struct {
-        Type string      `json:"type"`
-        Data interface{} `json:"data"`
-    }{
-        Type: messageType,
-        Data: data,
-    }
-
-    messageJSON, err := json.Marshal(message)
+func (n *Network) broadcast(messageType string, data interface{}, except *Peer) error {
+    raw, err := encodeMessage(messageType, data)
     if err != nil {
         return err
     }
 
-    n.mu.RLock()
-    defer n.mu.RUnlock()
-
-    for _, conn := range n.peers {
-        if _, err := conn.Write(append(messageJSON, '\n')); err != nil {
-            fmt.Printf("Failed to send to peer: %v\n", err)
+    for _, peer := range n.Peers() {
+        if peer == except {
+            continue
+        }
+        if err := peer.enqueue(raw); err != nil {
+            log.Printf("Failed to send to peer: %v", err)
         }
     }
-
     return nil
-}
\ No newline at end of file
+}
@@ -1,14 +1,24 @@
 package p2p
 
 import (
+    "encoding/json"
+    "fmt"
     "net"
+    "sync"
 )
 
+// sendQueueSize bounds the messages waiting to be written to one peer
+const sendQueueSize = 256
+
 // Peer represents a node in the P2P network
 type Peer struct {
     Address string
     Port    string
     Conn    net.Conn
+
+    outbox chan []byte
+    done   chan struct{}
+    once   sync.Once
 }
 
 // NewPeer creates a new peer instance
@@ -16,12 +26,25 @@ func NewPeer(address, port string) *Peer {
     return &Peer{
         Address: address,
         Port:    port,
+        outbox:  make(chan []byte, sendQueueSize),
+        done:    make(chan struct{}),
     }
 }
 
+// newPeerFromConn wraps an accepted connection
+func newPeerFromConn(conn net.Conn) *Peer {
+    host, port, err := net.SplitHostPort(conn.RemoteAddr().String())
+    if err != nil {
+        host = conn.RemoteAddr().String()
+    }
+    peer := NewPeer(host, port)
+    peer.Conn = conn
+    return peer
+}
+
 // Connect establishes a connection with the peer
 func (p *Peer) Connect() error {
-    conn, err := net.Dial("tcp", p.Address+":"+p.Port)
+    conn, err := net.Dial("tcp", p.ID())
     if err != nil {
         return err
     }
@@ -31,8 +54,70 @@ func (p *Peer) Connect() error {
 
 // Disconnect closes the connection with the peer
 func (p *Peer) Disconnect() error {
-    if p.Conn != nil {
-        return p.Conn.Close()
+    var err error
+    p.once.Do(func() {
+        close(p.done)
+        if p.Conn != nil {
+            err = p.Conn.Close()
+        }
+    })
+    return err
+}
+
+// ID returns the host:port the peer is known by
+func (p *Peer) ID() string {
+    return net.JoinHostPort(p.Address, p.Port)
+}
+
+// Send queues a message for the peer. Writes happen on a separate goroutine
+// so that a slow peer can never block message handling.
+func (p *Peer) Send(messageType string, data interface{}) error {
+    raw, err := encodeMessage(messageType, data)
+    if err != nil {
+        return err
     }
-    return nil
-}
\ No newline at end of file
+    return p.enqueue(raw)
+}
+
+func (p *Peer) enqueue(raw []byte) error {
+    select {
+    case <-p.done:
+        return fmt.Errorf("peer %s disconnected", p.ID())
+    case p.outbox <- raw:
+        return nil
+    default:
+        return fmt.Errorf("send queue to peer %s is full", p.ID())
+    }
+}
+
+// writeLoop drains the outbox until the peer disconnects
+func (p *Peer) writeLoop() {
+    for {
+        select {
+        case <-p.done:
+            return
+        case raw := <-p.outbox:
+            if _, err := p.Conn.Write(raw); err != nil {
+                p.Disconnect()
+                return
+            }
+        }
+    }
+}
+
+// encodeMessage frames a message as one line of JSON
+func encodeMessage(messageType string, data interface{}) ([]byte, error) {
+    message := struct {
+        Type string      `json:"type"`
+        Data interface{} `json:"data"`
+    }{
+        Type: messageType,
+        Data: data,
+    }
+
+    raw, err := json.Marshal(message)
+    if err != nil {
+        return nil, err
+    }
+    return append(raw, '\n'), nil
+}
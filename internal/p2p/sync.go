@@ -0,0 +1,447 @@
+package p2p
+
+import (
+    "bytes"
+    "encoding/json"
+    "log"
+    "sync"
+    "time"
+    "virtual_ethiopia_dap/internal/blockchain"
+)
+
+// Message types of the chain synchronization protocol
+const (
+    // MsgStatus announces a node's genesis, height and head hash
+    MsgStatus = "status"
+    // MsgGetBlocks requests an inclusive range of blocks by height
+    MsgGetBlocks = "get_blocks"
+    // MsgBlocks answers MsgGetBlocks
+    MsgBlocks = "blocks"
+    // MsgBlock announces a single newly committed block
+    MsgBlock = "block"
+)
+
+const (
+    // maxBlocksPerRequest caps the size of a single block range
+    maxBlocksPerRequest = 100
+    // statusInterval is how often status is re-announced to all peers
+    statusInterval = 10 * time.Second
+    // requestTimeout is how long a block range request may stay unanswered
+    // before it is sent again
+    requestTimeout = 15 * time.Second
+    // maxForkDepth is how far below its head a node looks for the common
+    // ancestor of a peer's branch before giving up on it
+    maxForkDepth = 10 * maxBlocksPerRequest
+)
+
+// Status describes the chain of the sending node
+type Status struct {
+    GenesisHash string `json:"genesisHash"`
+    Height      int64  `json:"height"`
+    HeadHash    string `json:"headHash"`
+}
+
+// GetBlocks requests the blocks from From to To inclusive
+type GetBlocks struct {
+    From int64 `json:"from"`
+    To   int64 `json:"to"`
+}
+
+// Blocks carries a contiguous range of blocks
+type Blocks struct {
+    Blocks []*blockchain.Block `json:"blocks"`
+}
+
+// syncRequest is a block range request waiting for its answer
+type syncRequest struct {
+    peer     *Peer
+    deadline time.Time
+    // branch holds the blocks of the peer's fork above the requested range
+    // while the common ancestor is searched for
+    branch []*blockchain.Block
+}
+
+// Syncer keeps the local chain in step with the network. Nodes exchange
+// status on connect and periodically afterwards; a node that learns of a
+// longer chain requests the missing blocks in ranges and appends them in
+// order, so a fresh node catches up from genesis. Newly committed blocks are
+// announced individually and relayed to the other peers.
+//
+// A peer whose blocks do not link to the local head is on another branch.
+// The node then walks back through the peer's blocks, a range at a time,
+// to the common ancestor and switches to the peer's branch if it is longer.
+// The heights peers claim only decide whom to ask; a peer's height is
+// recorded once its blocks pass verification, and a claim the peer fails to
+// back up is forgotten.
+type Syncer struct {
+    network     *Network
+    chain       *blockchain.Chain
+    appendBlock func(block *blockchain.Block) error
+    genesisHash string
+
+    heights  map[*Peer]int64 // highest block from each peer that passed verification
+    claims   map[*Peer]int64 // heights peers report in their status
+    inFlight *syncRequest
+    mu       sync.Mutex
+    stop     chan struct{}
+    wg       sync.WaitGroup
+}
+
+// NewSyncer creates a syncer for chain and registers its message handlers.
+// Received blocks are passed to appendBlock, normally the consensus engine's
+// HandleBlock, so that they are checked against the consensus rules.
+func NewSyncer(network *Network, chain *blockchain.Chain, appendBlock func(block *blockchain.Block) error) *Syncer {
+    genesis, _ := chain.GetBlock(0)
+    s := &Syncer{
+        network:     network,
+        chain:       chain,
+        appendBlock: appendBlock,
+        genesisHash: genesis.HashHex(),
+        heights:     make(map[*Peer]int64),
+        claims:      make(map[*Peer]int64),
+    }
+
+    network.OnConnect(s.sendStatus)
+    network.Handle(MsgStatus, s.handleStatus)
+    network.Handle(MsgGetBlocks, s.handleGetBlocks)
+    network.Handle(MsgBlocks, s.handleBlocks)
+    network.Handle(MsgBlock, s.handleBlock)
+    return s
+}
+
+// Start begins periodic status announcements
+func (s *Syncer) Start() {
+    s.stop = make(chan struct{})
+    s.wg.Add(1)
+    go s.run()
+}
+
+// Stop halts periodic status announcements
+func (s *Syncer) Stop() {
+    if s.stop == nil {
+        return
+    }
+    close(s.stop)
+    s.wg.Wait()
+    s.stop = nil
+}
+
+// Announce sends a block committed by this node to every peer
+func (s *Syncer) Announce(block *blockchain.Block) {
+    if err := s.network.Broadcast(MsgBlock, block); err != nil {
+        log.Printf("Failed to broadcast block %d: %v", block.Index, err)
+    }
+}
+
+func (s *Syncer) run() {
+    defer s.wg.Done()
+
+    ticker := time.NewTicker(statusInterval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-s.stop:
+            return
+        case <-ticker.C:
+            s.forgetDisconnected()
+            if err := s.network.Broadcast(MsgStatus, s.status()); err != nil {
+                log.Printf("Failed to broadcast status: %v", err)
+            }
+            s.requestMissing()
+        }
+    }
+}
+
+// status describes the local chain
+func (s *Syncer) status() Status {
+    head, _ := s.chain.GetLatestBlock()
+    return Status{
+        GenesisHash: s.genesisHash,
+        Height:      head.Index,
+        HeadHash:    head.HashHex(),
+    }
+}
+
+func (s *Syncer) sendStatus(peer *Peer) {
+    if err := peer.Send(MsgStatus, s.status()); err != nil {
+        log.Printf("Failed to send status to %s: %v", peer.ID(), err)
+    }
+}
+
+// forgetDisconnected drops the heights of peers that are gone
+func (s *Syncer) forgetDisconnected() {
+    connected := make(map[*Peer]bool)
+    for _, peer := range s.network.Peers() {
+        connected[peer] = true
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    for peer := range s.claims {
+        if !connected[peer] {
+            delete(s.claims, peer)
+        }
+    }
+    for peer := range s.heights {
+        if !connected[peer] {
+            delete(s.heights, peer)
+        }
+    }
+    if s.inFlight != nil && !connected[s.inFlight.peer] {
+        s.inFlight = nil
+    }
+}
+
+func (s *Syncer) handleStatus(peer *Peer, data json.RawMessage) {
+    var status Status
+    if err := json.Unmarshal(data, &status); err != nil {
+        log.Printf("Invalid status from %s: %v", peer.ID(), err)
+        return
+    }
+    if status.GenesisHash != s.genesisHash {
+        log.Printf("Disconnecting %s: peer uses a different genesis", peer.ID())
+        peer.Disconnect()
+        return
+    }
+
+    local := s.status()
+    if status.Height == local.Height && status.HeadHash != local.HeadHash {
+        log.Printf("Peer %s has a different block at height %d", peer.ID(), status.Height)
+    }
+
+    s.mu.Lock()
+    s.claims[peer] = status.Height
+    s.mu.Unlock()
+    s.requestMissing()
+}
+
+// notePeerHeight records that peer sent a verified block at height
+func (s *Syncer) notePeerHeight(peer *Peer, height int64) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if height > s.heights[peer] {
+        s.heights[peer] = height
+    }
+}
+
+// forgetClaim drops the height peer claimed after it failed to back it up
+func (s *Syncer) forgetClaim(peer *Peer) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    delete(s.claims, peer)
+}
+
+// requestMissing asks the peer claiming the highest chain for the next
+// range of blocks, preferring peers that already sent verified blocks
+func (s *Syncer) requestMissing() {
+    height := s.chain.Height()
+
+    s.mu.Lock()
+    var best *Peer
+    bestHeight := height
+    for peer, claim := range s.claims {
+        if claim > bestHeight || (best != nil && claim == bestHeight && s.heights[peer] > s.heights[best]) {
+            best, bestHeight = peer, claim
+        }
+    }
+    s.mu.Unlock()
+
+    if best != nil {
+        s.request(best, height+1, bestHeight, nil)
+    }
+}
+
+// request asks peer for the blocks from from to to unless another request is
+// still outstanding. The claim of a peer that let its request time out is
+// forgotten. branch is kept with the request while searching for the common
+// ancestor of a fork.
+func (s *Syncer) request(peer *Peer, from, to int64, branch []*blockchain.Block) {
+    if to-from+1 > maxBlocksPerRequest {
+        to = from + maxBlocksPerRequest - 1
+    }
+
+    s.mu.Lock()
+    if s.inFlight != nil {
+        if time.Now().Before(s.inFlight.deadline) {
+            s.mu.Unlock()
+            return
+        }
+        delete(s.claims, s.inFlight.peer)
+    }
+    s.inFlight = &syncRequest{peer: peer, deadline: time.Now().Add(requestTimeout), branch: branch}
+    s.mu.Unlock()
+
+    if err := peer.Send(MsgGetBlocks, GetBlocks{From: from, To: to}); err != nil {
+        log.Printf("Failed to request blocks from %s: %v", peer.ID(), err)
+        s.mu.Lock()
+        s.inFlight = nil
+        s.mu.Unlock()
+    }
+}
+
+func (s *Syncer) handleGetBlocks(peer *Peer, data json.RawMessage) {
+    var request GetBlocks
+    if err := json.Unmarshal(data, &request); err != nil {
+        log.Printf("Invalid block request from %s: %v", peer.ID(), err)
+        return
+    }
+    if request.To-request.From+1 > maxBlocksPerRequest {
+        request.To = request.From + maxBlocksPerRequest - 1
+    }
+
+    response := Blocks{Blocks: make([]*blockchain.Block, 0)}
+    for index := request.From; index <= request.To; index++ {
+        block, ok := s.chain.GetBlock(index)
+        if !ok {
+            break
+        }
+        response.Blocks = append(response.Blocks, block)
+    }
+
+    if err := peer.Send(MsgBlocks, response); err != nil {
+        log.Printf("Failed to send blocks to %s: %v", peer.ID(), err)
+    }
+}
+
+func (s *Syncer) handleBlocks(peer *Peer, data json.RawMessage) {
+    var response Blocks
+    if err := json.Unmarshal(data, &response); err != nil {
+        log.Printf("Invalid blocks from %s: %v", peer.ID(), err)
+        return
+    }
+
+    s.mu.Lock()
+    request := s.inFlight
+    if request == nil || request.peer != peer {
+        s.mu.Unlock()
+        return
+    }
+    s.inFlight = nil
+    s.mu.Unlock()
+
+    blocks := response.Blocks
+    if len(request.branch) > 0 {
+        blocks = append(blocks, request.branch...)
+    }
+    if !linked(blocks) {
+        log.Printf("Blocks from %s do not form a chain", peer.ID())
+        s.forgetClaim(peer)
+        return
+    }
+
+    first := blocks[0]
+    parent, ok := s.chain.GetBlock(first.Index - 1)
+    switch {
+    case !ok:
+        log.Printf("Blocks from %s start above the local head", peer.ID())
+        s.forgetClaim(peer)
+        return
+    case !bytes.Equal(parent.Hash, first.PrevHash):
+        s.walkBack(peer, blocks)
+        return
+    }
+
+    s.appendBranch(peer, blocks)
+    s.requestMissing()
+}
+
+// linked reports whether blocks is a non-empty run of consecutive blocks
+func linked(blocks []*blockchain.Block) bool {
+    if len(blocks) == 0 {
+        return false
+    }
+    for i := 1; i < len(blocks); i++ {
+        if blocks[i].Index != blocks[i-1].Index+1 || !bytes.Equal(blocks[i].PrevHash, blocks[i-1].Hash) {
+            return false
+        }
+    }
+    return true
+}
+
+// walkBack requests the range below branch, a run of peer's blocks whose
+// first block does not link to the local chain, to look for the common
+// ancestor
+func (s *Syncer) walkBack(peer *Peer, branch []*blockchain.Block) {
+    first := branch[0].Index
+    if first <= 1 || s.chain.Height()-first >= maxForkDepth {
+        log.Printf("Giving up on the branch of %s: no common ancestor within %d blocks", peer.ID(), maxForkDepth)
+        s.forgetClaim(peer)
+        return
+    }
+    log.Printf("Peer %s is on another branch, looking below block %d", peer.ID(), first)
+    s.request(peer, max(first-maxBlocksPerRequest, 1), first-1, branch)
+}
+
+// appendBranch adds blocks, a run of peer's blocks that extends a local
+// block, to the chain. Blocks already held are skipped; blocks that extend
+// the head go through the consensus engine one at a time, and a branch that
+// replaces local blocks is checked as a whole by Chain.Reorganize.
+func (s *Syncer) appendBranch(peer *Peer, blocks []*blockchain.Block) {
+    for len(blocks) > 0 {
+        known, ok := s.chain.GetBlock(blocks[0].Index)
+        if !ok || !bytes.Equal(known.Hash, blocks[0].Hash) {
+            break
+        }
+        s.notePeerHeight(peer, known.Index)
+        blocks = blocks[1:]
+    }
+    if len(blocks) == 0 {
+        return
+    }
+
+    if blocks[0].Index <= s.chain.Height() {
+        if err := s.chain.Reorganize(blocks); err != nil {
+            log.Printf("Rejected branch of %s: %v", peer.ID(), err)
+            s.forgetClaim(peer)
+            return
+        }
+        s.notePeerHeight(peer, blocks[len(blocks)-1].Index)
+        log.Printf("Switched to the branch of %s at height %d", peer.ID(), s.chain.Height())
+        return
+    }
+
+    for _, block := range blocks {
+        if err := s.appendBlock(block); err != nil {
+            log.Printf("Rejected block %d from %s: %v", block.Index, peer.ID(), err)
+            s.forgetClaim(peer)
+            return
+        }
+        s.notePeerHeight(peer, block.Index)
+    }
+    log.Printf("Synced to height %d from %s", s.chain.Height(), peer.ID())
+}
+
+func (s *Syncer) handleBlock(peer *Peer, data json.RawMessage) {
+    var block blockchain.Block
+    if err := json.Unmarshal(data, &block); err != nil {
+        log.Printf("Invalid block from %s: %v", peer.ID(), err)
+        return
+    }
+
+    head, _ := s.chain.GetLatestBlock()
+    switch {
+    case block.Index <= head.Index:
+        // Already known, typically relayed back by another peer
+        return
+    case block.Index > head.Index+1:
+        // The index is unverified, so ask for the blocks in between rather
+        // than recording it as the peer's height
+        s.request(peer, head.Index+1, block.Index, nil)
+        return
+    case !bytes.Equal(block.PrevHash, head.Hash):
+        s.walkBack(peer, []*blockchain.Block{&block})
+        return
+    }
+
+    if err := s.appendBlock(&block); err != nil {
+        log.Printf("Rejected block %d from %s: %v", block.Index, peer.ID(), err)
+        return
+    }
+    s.notePeerHeight(peer, block.Index)
+    log.Printf("Added block %d from %s", block.Index, peer.ID())
+
+    if err := s.network.BroadcastExcept(MsgBlock, &block, peer); err != nil {
+        log.Printf("Failed to relay block %d: %v", block.Index, err)
+    }
+}
@@ -0,0 +1,196 @@
+package p2p
+
+import (
+    "bytes"
+    "encoding/json"
+    "testing"
+    "time"
+    "virtual_ethiopia_dap/internal/blockchain"
+)
+
+// newTestSyncer returns a syncer for a fresh chain connected to one peer
+// whose send queue the test reads
+func newTestSyncer(t *testing.T, genesis *blockchain.Genesis) (*Syncer, *Peer) {
+    t.Helper()
+    network := NewNetwork()
+    peer := NewPeer("10.0.0.1", "3000")
+    network.peers[peer.ID()] = peer
+    chain := blockchain.NewChain(genesis)
+    return NewSyncer(network, chain, chain.AppendBlock), peer
+}
+
+// grow appends n blocks to chain, each registering a new citizen
+func grow(t *testing.T, chain *blockchain.Chain, n int) {
+    t.Helper()
+    for i := 0; i < n; i++ {
+        if err := chain.SubmitTransaction(testRegistration(t, "Citizen")); err != nil {
+            t.Fatal(err)
+        }
+        block, err := chain.ProposeBlock("proposer", 0, time.Now().Unix())
+        if err != nil {
+            t.Fatal(err)
+        }
+        if err := chain.AppendBlock(block); err != nil {
+            t.Fatal(err)
+        }
+    }
+}
+
+// answer has remote answer every block request the syncer sent to peer,
+// returning how many it answered
+func answer(t *testing.T, s *Syncer, peer *Peer, remote *blockchain.Chain) int {
+    t.Helper()
+    answered := 0
+    for {
+        select {
+        case raw := <-peer.outbox:
+            var message struct {
+                Type string          `json:"type"`
+                Data json.RawMessage `json:"data"`
+            }
+            if err := json.Unmarshal(raw, &message); err != nil {
+                t.Fatal(err)
+            }
+            if message.Type != MsgGetBlocks {
+                continue
+            }
+            var request GetBlocks
+            if err := json.Unmarshal(message.Data, &request); err != nil {
+                t.Fatal(err)
+            }
+            response := Blocks{Blocks: make([]*blockchain.Block, 0)}
+            for index := request.From; index <= request.To; index++ {
+                if block, ok := remote.GetBlock(index); ok {
+                    response.Blocks = append(response.Blocks, block)
+                }
+            }
+            data, err := json.Marshal(response)
+            if err != nil {
+                t.Fatal(err)
+            }
+            answered++
+            s.handleBlocks(peer, data)
+        default:
+            return answered
+        }
+    }
+}
+
+// announceStatus delivers the status of remote to the syncer as if peer sent it
+func announceStatus(t *testing.T, s *Syncer, peer *Peer, remote *blockchain.Chain) {
+    t.Helper()
+    head, _ := remote.GetLatestBlock()
+    data, err := json.Marshal(Status{GenesisHash: s.genesisHash, Height: head.Index, HeadHash: head.HashHex()})
+    if err != nil {
+        t.Fatal(err)
+    }
+    s.handleStatus(peer, data)
+}
+
+func TestSync(t *testing.T) {
+    tests := []struct {
+        name   string
+        shared int  // blocks both chains hold
+        local  int  // blocks only the local chain holds
+        remote int  // blocks only the peer holds
+        adopt  bool // whether the node moves to the peer's chain
+    }{
+        {"catch up from genesis", 0, 0, 5, true},
+        {"catch up", 3, 0, 4, true},
+        {"longer branch", 2, 3, 4, true},
+        {"longer branch from genesis", 0, 2, 3, true},
+        {"shorter branch", 2, 3, 2, false},
+        {"equal branch", 2, 3, 3, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            genesis := &blockchain.Genesis{Timestamp: time.Now().Unix()}
+            s, peer := newTestSyncer(t, genesis)
+            remote := blockchain.NewChain(genesis)
+            grow(t, s.chain, tt.shared)
+            for height := int64(1); height <= int64(tt.shared); height++ {
+                block, _ := s.chain.GetBlock(height)
+                if err := remote.AppendBlock(block); err != nil {
+                    t.Fatal(err)
+                }
+            }
+            grow(t, s.chain, tt.local)
+            grow(t, remote, tt.remote)
+            localHead, _ := s.chain.GetLatestBlock()
+
+            announceStatus(t, s, peer, remote)
+            answer(t, s, peer, remote)
+
+            head, _ := s.chain.GetLatestBlock()
+            remoteHead, _ := remote.GetLatestBlock()
+            if !tt.adopt {
+                if head != localHead {
+                    t.Fatalf("chain moved to height %d", head.Index)
+                }
+                return
+            }
+            if !bytes.Equal(head.Hash, remoteHead.Hash) {
+                t.Fatalf("head is block %d, want the peer's block %d", head.Index, remoteHead.Index)
+            }
+            if got := s.heights[peer]; got != remoteHead.Index {
+                t.Errorf("recorded peer height %d, want %d", got, remoteHead.Index)
+            }
+            // Registrations only the replaced blocks held return to the pool
+            if got := s.chain.PendingCount(); got != tt.local {
+                t.Errorf("%d transactions pending, want %d", got, tt.local)
+            }
+        })
+    }
+}
+
+func TestSyncDeepFork(t *testing.T) {
+    genesis := &blockchain.Genesis{Timestamp: time.Now().Unix()}
+    s, peer := newTestSyncer(t, genesis)
+    remote := blockchain.NewChain(genesis)
+    grow(t, s.chain, maxBlocksPerRequest+10)
+    grow(t, remote, maxBlocksPerRequest+20)
+
+    announceStatus(t, s, peer, remote)
+    if requests := answer(t, s, peer, remote); requests < 3 {
+        t.Errorf("answered %d requests, want the fork walked back over several", requests)
+    }
+    head, _ := s.chain.GetLatestBlock()
+    remoteHead, _ := remote.GetLatestBlock()
+    if !bytes.Equal(head.Hash, remoteHead.Hash) {
+        t.Fatalf("head is block %d, want the peer's block %d", head.Index, remoteHead.Index)
+    }
+}
+
+func TestSyncUnverifiedHeights(t *testing.T) {
+    genesis := &blockchain.Genesis{Timestamp: time.Now().Unix()}
+    s, peer := newTestSyncer(t, genesis)
+    remote := blockchain.NewChain(genesis)
+    grow(t, remote, 2)
+
+    // A block far ahead only makes the node ask for the blocks in between
+    bogus, _ := remote.GetBlock(2)
+    forged := *bogus
+    forged.Index = 1000
+    data, err := json.Marshal(&forged)
+    if err != nil {
+        t.Fatal(err)
+    }
+    s.handleBlock(peer, data)
+    if _, recorded := s.heights[peer]; recorded {
+        t.Fatal("height of an unverified block was recorded")
+    }
+
+    // The peer cannot back up the claim, which is forgotten
+    empty := blockchain.NewChain(genesis)
+    s.claims[peer] = 1000
+    answer(t, s, peer, empty)
+    if _, claimed := s.claims[peer]; claimed {
+        t.Error("claim survived an empty answer")
+    }
+    if _, recorded := s.heights[peer]; recorded {
+        t.Error("height recorded without a verified block")
+    }
+    if s.chain.Height() != 0 {
+        t.Errorf("chain moved to height %d", s.chain.Height())
+    }
+}
@@ -0,0 +1,225 @@
+package storage
+
+import (
+    "encoding/binary"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "io"
+    "log"
+    "os"
+    "path/filepath"
+    "sync"
+    "virtual_ethiopia_dap/internal/blockchain"
+)
+
+const (
+    // blockLogName is the file inside the data directory holding the block log
+    blockLogName = "blocks.log"
+    // recordHeaderSize is the length prefix plus the CRC32 checksum
+    recordHeaderSize = 8
+    // maxRecordSize guards against allocating memory for a garbage length prefix
+    maxRecordSize = 64 << 20
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// FileStore is an append-only, checksummed block log on disk.
+//
+// Each record is laid out as:
+//
+//    [4 bytes big-endian payload length][4 bytes CRC32-C of payload][payload]
+//
+// where the payload is the JSON encoding of a block. The log only ever
+// shrinks from the end, when the chain switches to another branch.
+type FileStore struct {
+    dir     string
+    file    *os.File
+    offsets []int64 // offset of each block's record, by height
+    size    int64   // length of the log
+    mu      sync.Mutex
+}
+
+// OpenFileStore opens (or creates) the block log inside dir
+func OpenFileStore(dir string) (*FileStore, error) {
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return nil, fmt.Errorf("failed to create data directory: %v", err)
+    }
+
+    file, err := os.OpenFile(filepath.Join(dir, blockLogName), os.O_RDWR|os.O_CREATE, 0o644)
+    if err != nil {
+        return nil, fmt.Errorf("failed to open block log: %v", err)
+    }
+
+    return &FileStore{
+        dir:  dir,
+        file: file,
+    }, nil
+}
+
+// LoadBlocks reads every record in the log. A torn or corrupt record at the
+// end of the file (e.g. from a crash mid-write) is truncated away; corruption
+// anywhere else is reported as an error.
+func (fs *FileStore) LoadBlocks() ([]*blockchain.Block, error) {
+    fs.mu.Lock()
+    defer fs.mu.Unlock()
+
+    info, err := fs.file.Stat()
+    if err != nil {
+        return nil, fmt.Errorf("failed to stat block log: %v", err)
+    }
+    size := info.Size()
+
+    if _, err := fs.file.Seek(0, io.SeekStart); err != nil {
+        return nil, err
+    }
+
+    blocks := make([]*blockchain.Block, 0)
+    fs.offsets = fs.offsets[:0]
+    var offset int64
+    header := make([]byte, recordHeaderSize)
+    for offset < size {
+        block, n, err := readRecord(fs.file, header, size-offset)
+        if err != nil {
+            if errors.Is(err, errTornRecord) {
+                log.Printf("Truncating torn record at offset %d of block log: %v", offset, err)
+                if err := fs.truncate(offset); err != nil {
+                    return nil, err
+                }
+                break
+            }
+            return nil, fmt.Errorf("block log corrupt at offset %d: %v", offset, err)
+        }
+        blocks = append(blocks, block)
+        fs.offsets = append(fs.offsets, offset)
+        offset += n
+    }
+    fs.size = offset
+
+    if _, err := fs.file.Seek(0, io.SeekEnd); err != nil {
+        return nil, err
+    }
+    return blocks, nil
+}
+
+// AppendBlock writes a block record and syncs it to disk
+func (fs *FileStore) AppendBlock(block *blockchain.Block) error {
+    payload, err := json.Marshal(block)
+    if err != nil {
+        return fmt.Errorf("failed to encode block: %v", err)
+    }
+
+    record := make([]byte, recordHeaderSize+len(payload))
+    binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+    binary.BigEndian.PutUint32(record[4:8], crc32.Checksum(payload, crcTable))
+    copy(record[recordHeaderSize:], payload)
+
+    fs.mu.Lock()
+    defer fs.mu.Unlock()
+
+    if _, err := fs.file.Write(record); err != nil {
+        return fs.rollback(fmt.Errorf("failed to write block record: %v", err))
+    }
+    if err := fs.file.Sync(); err != nil {
+        return fs.rollback(fmt.Errorf("failed to sync block log: %v", err))
+    }
+    fs.offsets = append(fs.offsets, fs.size)
+    fs.size += int64(len(record))
+    return nil
+}
+
+// TruncateBlocks cuts the log after the record of the block at height
+func (fs *FileStore) TruncateBlocks(height int64) error {
+    fs.mu.Lock()
+    defer fs.mu.Unlock()
+
+    if height < 0 || height+1 >= int64(len(fs.offsets)) {
+        return nil
+    }
+    offset := fs.offsets[height+1]
+    if err := fs.truncate(offset); err != nil {
+        return err
+    }
+    if _, err := fs.file.Seek(offset, io.SeekStart); err != nil {
+        return err
+    }
+    fs.offsets = fs.offsets[:height+1]
+    fs.size = offset
+    return nil
+}
+
+// Close closes the underlying block log
+func (fs *FileStore) Close() error {
+    fs.mu.Lock()
+    defer fs.mu.Unlock()
+    return fs.file.Close()
+}
+
+// rollback cuts the log back to its last complete record after an append
+// failed with cause, so that no later record lands behind a torn one
+func (fs *FileStore) rollback(cause error) error {
+    if err := fs.truncate(fs.size); err != nil {
+        return fmt.Errorf("%v; %v", cause, err)
+    }
+    if _, err := fs.file.Seek(fs.size, io.SeekStart); err != nil {
+        return fmt.Errorf("%v; %v", cause, err)
+    }
+    return cause
+}
+
+func (fs *FileStore) truncate(offset int64) error {
+    if err := fs.file.Truncate(offset); err != nil {
+        return fmt.Errorf("failed to truncate block log: %v", err)
+    }
+    return fs.file.Sync()
+}
+
+// errTornRecord marks a record that runs past the end of the file, i.e. the
+// last write never completed
+var errTornRecord = errors.New("torn record")
+
+// readRecord decodes one record from r; remaining is the number of bytes left
+// in the file from the current position
+func readRecord(r io.Reader, header []byte, remaining int64) (*blockchain.Block, int64, error) {
+    if remaining < recordHeaderSize {
+        return nil, 0, fmt.Errorf("%w: %d trailing bytes", errTornRecord, remaining)
+    }
+    if _, err := io.ReadFull(r, header); err != nil {
+        return nil, 0, err
+    }
+
+    length := int64(binary.BigEndian.Uint32(header[0:4]))
+    checksum := binary.BigEndian.Uint32(header[4:8])
+    recordSize := recordHeaderSize + length
+
+    if length > maxRecordSize {
+        if recordSize >= remaining {
+            return nil, 0, fmt.Errorf("%w: bad length %d", errTornRecord, length)
+        }
+        return nil, 0, fmt.Errorf("record length %d exceeds limit", length)
+    }
+    if recordSize > remaining {
+        return nil, 0, fmt.Errorf("%w: record needs %d bytes, %d left", errTornRecord, recordSize, remaining)
+    }
+
+    payload := make([]byte, length)
+    if _, err := io.ReadFull(r, payload); err != nil {
+        return nil, 0, err
+    }
+
+    // A bad checksum on the final record is a partially flushed write;
+    // anywhere else it means the log itself is damaged.
+    if crc32.Checksum(payload, crcTable) != checksum {
+        if recordSize == remaining {
+            return nil, 0, fmt.Errorf("%w: checksum mismatch", errTornRecord)
+        }
+        return nil, 0, errors.New("checksum mismatch")
+    }
+
+    var block blockchain.Block
+    if err := json.Unmarshal(payload, &block); err != nil {
+        return nil, 0, fmt.Errorf("failed to decode block: %v", err)
+    }
+    return &block, recordSize, nil
+}
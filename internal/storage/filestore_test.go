@@ -0,0 +1,298 @@
+package storage
+
+import (
+    "bytes"
+    "errors"
+    "os"
+    "path/filepath"
+    "testing"
+    "virtual_ethiopia_dap/internal/blockchain"
+)
+
+// testGenesis is the genesis every test chain starts from
+var testGenesis = &blockchain.Genesis{Timestamp: 1_700_000_000}
+
+// openTestChain opens the chain stored in dir
+func openTestChain(t *testing.T, dir string) (*blockchain.Chain, *FileStore) {
+    t.Helper()
+    store, err := OpenFileStore(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    chain, err := blockchain.OpenChain(store, testGenesis)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return chain, store
+}
+
+// appendEmpty appends n empty blocks to chain, each a second after the head
+// plus offset, so that chains grown with different offsets diverge
+func appendEmpty(t *testing.T, chain *blockchain.Chain, n int, offset int64) {
+    t.Helper()
+    for i := 0; i < n; i++ {
+        head, _ := chain.GetLatestBlock()
+        block, err := chain.ProposeBlock("proposer", 0, head.Timestamp+1+offset)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if err := chain.AppendBlock(block); err != nil {
+            t.Fatal(err)
+        }
+    }
+}
+
+func TestStateSurvivesRestart(t *testing.T) {
+    dir := t.TempDir()
+    chain, _ := openTestChain(t, dir)
+    public, private, err := blockchain.GenerateKeyPair()
+    if err != nil {
+        t.Fatal(err)
+    }
+    tx, err := blockchain.NewCitizenRegistrationTx("Abebe Bikila", "1932-08-07", "", public, testGenesis.Timestamp)
+    if err != nil {
+        t.Fatal(err)
+    }
+    tx.SetNonce("01")
+    if err := tx.Sign(private); err != nil {
+        t.Fatal(err)
+    }
+    if err := chain.SubmitTransaction(tx); err != nil {
+        t.Fatal(err)
+    }
+    appendEmpty(t, chain, 2, 0)
+    if err := chain.Close(); err != nil {
+        t.Fatal(err)
+    }
+
+    reopened, _ := openTestChain(t, dir)
+    defer reopened.Close()
+    if reopened.Height() != 2 {
+        t.Fatalf("reopened chain has height %d, want 2", reopened.Height())
+    }
+    if _, exists := reopened.GetCitizen(public); !exists {
+        t.Error("registration was lost on restart")
+    }
+    if err := reopened.SubmitTransaction(tx); err == nil {
+        t.Error("registration was accepted again after restart")
+    }
+}
+
+func TestReorganizePersists(t *testing.T) {
+    dir := t.TempDir()
+    chain, _ := openTestChain(t, dir)
+    appendEmpty(t, chain, 3, 0)
+
+    branch := blockchain.NewChain(testGenesis)
+    appendEmpty(t, branch, 4, 5)
+    blocks := branch.GetBlocks()[1:]
+    if err := chain.Reorganize(blocks); err != nil {
+        t.Fatal(err)
+    }
+    appendEmpty(t, chain, 1, 0)
+    if err := chain.Close(); err != nil {
+        t.Fatal(err)
+    }
+
+    reopened, _ := openTestChain(t, dir)
+    defer reopened.Close()
+    if reopened.Height() != 5 {
+        t.Fatalf("reopened chain has height %d, want 5", reopened.Height())
+    }
+    for i, block := range blocks {
+        stored, _ := reopened.GetBlock(block.Index)
+        if !bytes.Equal(stored.Hash, block.Hash) {
+            t.Errorf("stored block %d is not the branch block", i+1)
+        }
+    }
+}
+
+func TestLoadBlocksRecoversTornTail(t *testing.T) {
+    tests := []struct {
+        name   string
+        damage func(data []byte, last int64) []byte // last is the offset of the final record
+        blocks int                                  // of the three written
+        ok     bool
+    }{
+        {"intact", func(data []byte, last int64) []byte { return data }, 3, true},
+        {"partial header", func(data []byte, last int64) []byte { return append(data, 0, 0, 1) }, 3, true},
+        {"partial payload", func(data []byte, last int64) []byte { return data[:len(data)-10] }, 2, true},
+        {"header only", func(data []byte, last int64) []byte { return data[:last+recordHeaderSize] }, 2, true},
+        {"bad checksum on the last record", func(data []byte, last int64) []byte {
+            data[len(data)-2] ^= 0xff
+            return data
+        }, 2, true},
+        {"garbage length", func(data []byte, last int64) []byte {
+            return append(data, 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0)
+        }, 3, true},
+        {"bad checksum before the last record", func(data []byte, last int64) []byte {
+            data[recordHeaderSize+2] ^= 0xff
+            return data
+        }, 0, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            dir := t.TempDir()
+            store, err := OpenFileStore(dir)
+            if err != nil {
+                t.Fatal(err)
+            }
+            source := blockchain.NewChain(testGenesis)
+            appendEmpty(t, source, 2, 0)
+            for _, block := range source.GetBlocks() {
+                if err := store.AppendBlock(block); err != nil {
+                    t.Fatal(err)
+                }
+            }
+            last := store.offsets[len(store.offsets)-1]
+            store.Close()
+
+            path := filepath.Join(dir, blockLogName)
+            data, err := os.ReadFile(path)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if err := os.WriteFile(path, tt.damage(data, last), 0o644); err != nil {
+                t.Fatal(err)
+            }
+
+            store, err = OpenFileStore(dir)
+            if err != nil {
+                t.Fatal(err)
+            }
+            defer store.Close()
+            blocks, err := store.LoadBlocks()
+            if (err == nil) != tt.ok {
+                t.Fatalf("LoadBlocks error = %v, want ok = %v", err, tt.ok)
+            }
+            if !tt.ok {
+                return
+            }
+            if len(blocks) != tt.blocks {
+                t.Fatalf("loaded %d blocks, want %d", len(blocks), tt.blocks)
+            }
+
+            // The torn tail is gone, so a new record lands right after the
+            // last whole one and survives a reload
+            info, err := os.Stat(path)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if info.Size() != store.size {
+                t.Errorf("log is %d bytes after recovery, want %d", info.Size(), store.size)
+            }
+            if err := store.AppendBlock(blocks[len(blocks)-1]); err != nil {
+                t.Fatal(err)
+            }
+            reloaded, err := store.LoadBlocks()
+            if err != nil {
+                t.Fatal(err)
+            }
+            if len(reloaded) != tt.blocks+1 {
+                t.Errorf("reloaded %d blocks, want %d", len(reloaded), tt.blocks+1)
+            }
+        })
+    }
+}
+
+func TestFailedAppendLeavesNoTornRecord(t *testing.T) {
+    dir := t.TempDir()
+    store, err := OpenFileStore(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    source := blockchain.NewChain(testGenesis)
+    appendEmpty(t, source, 2, 0)
+    blocks := source.GetBlocks()
+    if err := store.AppendBlock(blocks[0]); err != nil {
+        t.Fatal(err)
+    }
+
+    // A write that fails partway leaves part of a record behind
+    cause := errors.New("no space left on device")
+    if _, err := store.file.Write([]byte{0, 0, 1, 0, 0xde, 0xad}); err != nil {
+        t.Fatal(err)
+    }
+    if err := store.rollback(cause); !errors.Is(err, cause) {
+        t.Fatalf("rollback error = %v, want the cause", err)
+    }
+    for _, block := range blocks[1:] {
+        if err := store.AppendBlock(block); err != nil {
+            t.Fatal(err)
+        }
+    }
+    store.Close()
+
+    store, err = OpenFileStore(dir)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer store.Close()
+    loaded, err := store.LoadBlocks()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(loaded) != len(blocks) {
+        t.Errorf("loaded %d blocks, want %d", len(loaded), len(blocks))
+    }
+}
+
+func TestOpenChainVerifiesStoredHashes(t *testing.T) {
+    tests := []struct {
+        name  string
+        forge func(block *blockchain.Block)
+        ok    bool
+    }{
+        {"intact", func(block *blockchain.Block) {}, true},
+        {"changed timestamp", func(block *blockchain.Block) { block.Timestamp++ }, false},
+        {"changed proposer", func(block *blockchain.Block) { block.Proposer = "forger" }, false},
+        {"dropped transaction", func(block *blockchain.Block) { block.Transactions = nil }, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            source := blockchain.NewChain(testGenesis)
+            public, private, err := blockchain.GenerateKeyPair()
+            if err != nil {
+                t.Fatal(err)
+            }
+            tx, err := blockchain.NewCitizenRegistrationTx("Abebe Bikila", "1932-08-07", "", public, testGenesis.Timestamp)
+            if err != nil {
+                t.Fatal(err)
+            }
+            tx.SetNonce("01")
+            if err := tx.Sign(private); err != nil {
+                t.Fatal(err)
+            }
+            if err := source.SubmitTransaction(tx); err != nil {
+                t.Fatal(err)
+            }
+            appendEmpty(t, source, 1, 0)
+
+            // The record of the forged block is written whole, so its
+            // checksum holds
+            dir := t.TempDir()
+            store, err := OpenFileStore(dir)
+            if err != nil {
+                t.Fatal(err)
+            }
+            blocks := source.GetBlocks()
+            forged := *blocks[1]
+            tt.forge(&forged)
+            for _, block := range []*blockchain.Block{blocks[0], &forged} {
+                if err := store.AppendBlock(block); err != nil {
+                    t.Fatal(err)
+                }
+            }
+            store.Close()
+
+            store, err = OpenFileStore(dir)
+            if err != nil {
+                t.Fatal(err)
+            }
+            defer store.Close()
+            if _, err := blockchain.OpenChain(store, testGenesis); (err == nil) != tt.ok {
+                t.Errorf("OpenChain error = %v, want ok = %v", err, tt.ok)
+            }
+        })
+    }
+}